@@ -1,43 +1,267 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// 标记 secret:"true" 的字段在打印配置时会被隐藏
 type Config struct {
+	Server struct {
+		Port int    `yaml:"port"`
+		Mode string `yaml:"mode"` // gin 运行模式: debug, release, test
+	} `yaml:"server"`
 	Mysql struct {
-		DSN string `yaml:"dsn"`
+		DSN string `yaml:"dsn" secret:"true"`
 	} `yaml:"mysql"`
 	Redis struct {
 		Addr     string `yaml:"addr"`
-		Password string `yaml:"password"`
+		Password string `yaml:"password" secret:"true"`
 		DB       int    `yaml:"db"`
 	} `yaml:"redis"`
 	OSS struct {
 		Endpoint  string `yaml:"endpoint"`
-		AccessKey string `yaml:"access_key"`
-		SecretKey string `yaml:"secret_key"`
+		AccessKey string `yaml:"access_key" secret:"true"`
+		SecretKey string `yaml:"secret_key" secret:"true"`
 		Bucket    string `yaml:"bucket"`
 		Domain    string `yaml:"domain"`
 	} `yaml:"oss"`
+	Storage struct {
+		Driver       string   `yaml:"driver"`        // local, s3, oss；oss 使用上面的 oss 配置
+		MaxSize      int64    `yaml:"max_size"`      // 单个文件大小上限，单位字节
+		AllowedTypes []string `yaml:"allowed_types"` // 允许上传的 MIME 类型，支持 image/* 这样的前缀
+		Local        struct {
+			Dir     string `yaml:"dir"`
+			BaseURL string `yaml:"base_url"`
+			SignKey string `yaml:"sign_key" secret:"true"` // 本地直传地址的签名密钥
+		} `yaml:"local"`
+		S3 struct {
+			Endpoint  string `yaml:"endpoint"`
+			Region    string `yaml:"region"`
+			Bucket    string `yaml:"bucket"`
+			AccessKey string `yaml:"access_key" secret:"true"`
+			SecretKey string `yaml:"secret_key" secret:"true"`
+			PathStyle bool   `yaml:"path_style"`
+			PublicURL string `yaml:"public_url"`
+		} `yaml:"s3"`
+	} `yaml:"storage"`
+	Image struct {
+		MaxPixels      int      `yaml:"max_pixels"`       // 图片像素数上限，超过视为过大
+		ThumbnailSizes []int    `yaml:"thumbnail_sizes"`  // 缩略图最长边，单位像素
+		MaxAspectRatio float64  `yaml:"max_aspect_ratio"` // 本地审核规则：长宽比上限，0 表示不限制
+		BlockedHashes  []string `yaml:"blocked_hashes"`   // 本地审核规则：禁止的图片 sha256
+	} `yaml:"image"`
 	Yunxin struct {
-		AppKey    string `yaml:"app_key"`
-		AppSecret string `yaml:"app_secret"`
+		AppKey      string `yaml:"app_key"`
+		AppSecret   string `yaml:"app_secret" secret:"true"`
+		APIBase     string `yaml:"api_base"`
+		SystemAccId string `yaml:"system_accid"` // 系统通知的发送方账号
 	} `yaml:"yunxin"`
+	JWT struct {
+		ActiveKid  string            `yaml:"active_kid"`         // 签发新 token 使用的密钥
+		Keys       map[string]string `yaml:"keys" secret:"true"` // kid -> secret，轮换期间保留旧密钥用于校验
+		AccessTTL  int               `yaml:"access_ttl"`         // 访问令牌有效期，单位秒
+		RefreshTTL int               `yaml:"refresh_ttl"`        // 刷新令牌有效期，单位秒
+	} `yaml:"jwt"`
+	OAuth struct {
+		Fake      bool                           `yaml:"fake"` // 启用本地模拟的身份提供方，仅用于开发和测试
+		Providers map[string]OAuthProviderConfig `yaml:"providers"`
+	} `yaml:"oauth"`
+	Account struct {
+		ExportDir           string `yaml:"export_dir"`            // 个人数据导出文件目录
+		DeletionCoolingDays int    `yaml:"deletion_cooling_days"` // 注销冷静期，单位天
+	} `yaml:"account"`
+	Chat struct {
+		RecallWindow int `yaml:"recall_window"` // 消息撤回和编辑时限，单位秒
+	} `yaml:"chat"`
+	SMS struct {
+		CodeTTL         int `yaml:"code_ttl"`          // 验证码有效期，单位秒
+		Cooldown        int `yaml:"cooldown"`          // 同一手机号两次发送的最小间隔，单位秒
+		MaxAttempts     int `yaml:"max_attempts"`      // 验证码最多输错次数，超过后锁定
+		PhoneDailyLimit int `yaml:"phone_daily_limit"` // 同一手机号每天最多发送次数
+		IPHourlyLimit   int `yaml:"ip_hourly_limit"`   // 同一 IP 每小时最多发送次数
+	} `yaml:"sms"`
+	Login struct {
+		MaxFailures  int `yaml:"max_failures"`  // 连续输错密码次数上限，超过后锁定账号
+		LockDuration int `yaml:"lock_duration"` // 账号锁定时长，单位秒
+		PasswordCost int `yaml:"password_cost"` // 密码哈希的 bcrypt cost，修改后在用户下次登录时重新哈希
+	} `yaml:"login"`
+	Call struct {
+		RingTimeout int `yaml:"ring_timeout"` // 无人接听的超时时间，单位秒
+		MaxDuration int `yaml:"max_duration"` // 单次通话最长时长，超过后由服务端结束，单位秒
+	} `yaml:"call"`
+	Profile struct {
+		NicknameInterval int      `yaml:"nickname_interval"` // 两次修改昵称的最小间隔，单位秒
+		MinAge           int      `yaml:"min_age"`
+		MaxAge           int      `yaml:"max_age"`
+		BannedWords      []string `yaml:"banned_words"` // 昵称和简介中不允许出现的词
+	} `yaml:"profile"`
+	Moment struct {
+		EditWindow int `yaml:"edit_window"` // 发布后可以编辑的时限，单位秒
+		MaxImages  int `yaml:"max_images"`  // 一条动态最多几张图片
+	} `yaml:"moment"`
+	Topic struct {
+		MaxPerMoment     int `yaml:"max_per_moment"`     // 一条动态最多关联几个话题
+		TrendingWindow   int `yaml:"trending_window"`    // 热门话题统计最近多久的互动，单位小时
+		TrendingHalfLife int `yaml:"trending_half_life"` // 互动的热度每过多久减半，单位小时
+	} `yaml:"topic"`
+	Moderation struct {
+		DictFile       string `yaml:"dict_file"`       // 敏感词词典，修改后自动重新加载
+		ReloadInterval int    `yaml:"reload_interval"` // 检查词典是否修改的间隔，单位秒
+	} `yaml:"moderation"`
+	Push struct {
+		Driver        string `yaml:"driver"` // expo 或 fake，为空时不发送推送
+		ExpoURL       string `yaml:"expo_url"`
+		AccessToken   string `yaml:"access_token" secret:"true"` // Expo 开启推送安全校验时需要
+		BatchSize     int    `yaml:"batch_size"`                 // 攒够多少条推送发送一次
+		BatchInterval int    `yaml:"batch_interval"`             // 未攒够时最长等待时间，单位秒
+	} `yaml:"push"`
+	Event struct {
+		Transport   string `yaml:"transport"`    // local 或 redis，多实例部署时使用 redis
+		Stream      string `yaml:"stream"`       // redis 传输使用的 Stream 名称
+		MaxAttempts int    `yaml:"max_attempts"` // 处理失败的最多重试次数，超过后标记为失败
+		Retention   int    `yaml:"retention"`    // 已投递事件的保留天数
+	} `yaml:"event"`
+}
+
+// OAuthProviderConfig 标准 OAuth2/OIDC 身份提供方
+type OAuthProviderConfig struct {
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" secret:"true"`
+	AuthURL      string   `yaml:"auth_url"`
+	TokenURL     string   `yaml:"token_url"`
+	UserInfoURL  string   `yaml:"userinfo_url"`
+	Scopes       []string `yaml:"scopes"`
 }
 
 var conf Config
 
-func InitConfig() {
-	// f, err := os.Open("config/config.yaml")
-	// if err != nil {
-	// 	log.Fatal("config/config.yaml read error: ", err)
-	// }
-	// defer f.Close()
+const DefaultConfigPath = "config/config.yaml"
 
-	// d := yaml.NewDecoder(f)
-	// err = d.Decode(&conf)
-	// if err != nil {
-	// 	log.Fatal("config.yaml decode error: ", err)
-	// }
+// InitConfig 读取 YAML 配置，再用环境变量覆盖，最后校验必填项
+func InitConfig(path string) {
+	if err := Load(path); err != nil {
+		log.Fatal("config load error: ", err)
+	}
 }
 
-// func GetConf() *Config {
-// 	return &conf
-// }
+func Load(path string) error {
+	var c Config
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s read error: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := yaml.NewDecoder(f).Decode(&c); err != nil {
+		return fmt.Errorf("%s decode error: %w", path, err)
+	}
+	if err := applyEnv(&c); err != nil {
+		return err
+	}
+	c.setDefaults()
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	conf = c
+	return nil
+}
+
+func (c *Config) setDefaults() {
+	if c.Server.Port == 0 {
+		c.Server.Port = 15151
+	}
+	if c.Server.Mode == "" {
+		c.Server.Mode = "debug"
+	}
+	if c.Yunxin.APIBase == "" {
+		c.Yunxin.APIBase = "https://api.netease.im/nimserver"
+	}
+	if c.Yunxin.SystemAccId == "" {
+		c.Yunxin.SystemAccId = "worldcity_system"
+	}
+	if c.Push.ExpoURL == "" {
+		c.Push.ExpoURL = "https://exp.host/--/api/v2/push/send"
+	}
+}
+
+const minJWTKeyLen = 32
+
+// 配置示例中的占位值
+func isPlaceholderSecret(s string) bool {
+	s = strings.ToLower(s)
+	return strings.Contains(s, "change-me") || strings.Contains(s, "your-") || strings.Contains(s, "secret-key")
+}
+
+// Validate 校验必填项，返回全部错误
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Mysql.DSN == "" {
+		errs = append(errs, errors.New("mysql.dsn is required"))
+	}
+	if c.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required"))
+	}
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port %d is out of range", c.Server.Port))
+	}
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		errs = append(errs, fmt.Errorf("server.mode %q must be debug, release or test", c.Server.Mode))
+	}
+	if len(c.JWT.Keys) > 0 {
+		if _, ok := c.JWT.Keys[c.JWT.ActiveKid]; !ok {
+			errs = append(errs, fmt.Errorf("jwt.active_kid %q not found in jwt.keys", c.JWT.ActiveKid))
+		}
+	}
+	// release 模式必须配置足够长的签名密钥，不能使用示例值
+	if c.Server.Mode == "release" {
+		for kid, secret := range c.JWT.Keys {
+			if len(secret) < minJWTKeyLen || isPlaceholderSecret(secret) {
+				errs = append(errs, fmt.Errorf("jwt.keys.%s must be a random secret of at least %d characters", kid, minJWTKeyLen))
+			}
+		}
+		if len(c.JWT.Keys) == 0 {
+			errs = append(errs, errors.New("jwt.keys is required in release mode"))
+		}
+	}
+	switch c.Storage.Driver {
+	case "", "local":
+	case "s3":
+		if c.Storage.S3.Endpoint == "" || c.Storage.S3.Bucket == "" {
+			errs = append(errs, errors.New("storage.s3.endpoint and storage.s3.bucket are required"))
+		}
+	case "oss":
+		if c.OSS.Endpoint == "" || c.OSS.Bucket == "" {
+			errs = append(errs, errors.New("oss.endpoint and oss.bucket are required"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.driver %q must be local, s3 or oss", c.Storage.Driver))
+	}
+	switch c.Push.Driver {
+	case "", "expo", "fake":
+	default:
+		errs = append(errs, fmt.Errorf("push.driver %q must be expo or fake", c.Push.Driver))
+	}
+	switch c.Event.Transport {
+	case "", "local", "redis":
+	default:
+		errs = append(errs, fmt.Errorf("event.transport %q must be local or redis", c.Event.Transport))
+	}
+	for name, p := range c.OAuth.Providers {
+		if p.ClientID == "" || p.TokenURL == "" || p.AuthURL == "" || p.UserInfoURL == "" {
+			errs = append(errs, fmt.Errorf("oauth.providers.%s requires client_id, auth_url, token_url and userinfo_url", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func GetConf() *Config {
+	return &conf
+}
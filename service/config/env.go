@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 环境变量前缀，变量名由 yaml 路径生成，例如 mysql.dsn -> WORLDCITY_MYSQL_DSN
+// map 类型使用 k1=v1,k2=v2 的格式，切片使用逗号分隔
+const EnvPrefix = "WORLDCITY"
+
+func applyEnv(c *Config) error {
+	return applyEnvValue(reflect.ValueOf(c).Elem(), EnvPrefix)
+}
+
+func applyEnvValue(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnvValue(fv, key); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(fv, raw); err != nil {
+			return fmt.Errorf("env %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setFromEnv(fv reflect.Value, raw string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Slice:
+		items := splitList(raw)
+		list := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFromEnv(list.Index(i), item); err != nil {
+				return err
+			}
+		}
+		fv.Set(list)
+	case reflect.Map:
+		if fv.Type().Key().Kind() != reflect.String || fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map type %s", fv.Type())
+		}
+		m := map[string]string{}
+		for _, pair := range splitList(raw) {
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid map entry %q, want key=value", pair)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		fv.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func splitList(raw string) []string {
+	var list []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
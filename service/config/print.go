@@ -0,0 +1,52 @@
+package config
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+const redacted = "******"
+
+// Redacted 返回隐藏了密钥等敏感字段的 YAML，用于 --print-config
+func (c *Config) Redacted() (string, error) {
+	cp := *c
+	redactValue(reflect.ValueOf(&cp).Elem())
+	data, err := yaml.Marshal(&cp)
+	return string(data), err
+}
+
+func redactValue(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fv := v.Field(i)
+		secret := t.Field(i).Tag.Get("secret") == "true"
+		switch fv.Kind() {
+		case reflect.Struct:
+			redactValue(fv)
+		case reflect.String:
+			if secret && fv.String() != "" {
+				fv.SetString(redacted)
+			}
+		case reflect.Map:
+			if fv.IsNil() {
+				continue
+			}
+			// 复制一份，避免修改原配置
+			m := reflect.MakeMapWithSize(fv.Type(), fv.Len())
+			iter := fv.MapRange()
+			for iter.Next() {
+				val := reflect.New(fv.Type().Elem()).Elem()
+				val.Set(iter.Value())
+				switch {
+				case val.Kind() == reflect.Struct:
+					redactValue(val)
+				case secret && val.Kind() == reflect.String:
+					val.SetString(redacted)
+				}
+				m.SetMapIndex(iter.Key(), val)
+			}
+			fv.Set(m)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
+	"worldCity/middleware"
 	"worldCity/service"
 	"worldCity/utils"
 
@@ -14,9 +16,23 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
 		return
 	}
-	res, err := service.Login(req.Name, req.Password)
+	res, err := service.Login(req.Name, req.Password, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		c.JSON(authErrorStatus(err), utils.BuildFailResp(authErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+func SmsLogin(c *gin.Context) {
+	var req SmsLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	res, err := service.SmsLogin(req.Phone, req.Code, clientInfo(c))
+	if err != nil {
+		c.JSON(authErrorStatus(err), utils.BuildFailResp(authErrorCode(err), err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, utils.BuildOkResp(res))
@@ -29,9 +45,8 @@ func SendCode(c *gin.Context) {
 		return
 	}
 
-	_, err := service.SendCode(req.Phone)
-	if err != nil {
-		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+	if err := service.SendCode(req.Scene, req.Phone, c.ClientIP()); err != nil {
+		c.JSON(authErrorStatus(err), utils.BuildFailResp(authErrorCode(err), err.Error()))
 		return
 	}
 
@@ -45,9 +60,9 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	res, err := service.Register(req.Phone, req.Password)
+	res, err := service.Register(req.Phone, req.Password, req.Ticket, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		c.JSON(authErrorStatus(err), utils.BuildFailResp(authErrorCode(err), err.Error()))
 		return
 	}
 
@@ -62,10 +77,109 @@ func VerifyCode(c *gin.Context) {
 	}
 
 	res, err := service.VerifyCode(req.Phone, req.Code)
+	if err != nil {
+		c.JSON(authErrorStatus(err), utils.BuildFailResp(authErrorCode(err), err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+func Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	res, err := service.RefreshToken(req.RefreshToken)
+	if err != nil {
+		c.JSON(authErrorStatus(err), utils.BuildFailResp(authErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+// 注销当前设备
+func Logout(c *gin.Context) {
+	err := service.Logout(middleware.GetUserIdFromToken(c), middleware.GetSessionIdFromToken(c))
 	if err != nil {
 		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
 	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
 
+// 注销所有设备
+func LogoutAll(c *gin.Context) {
+	if err := service.LogoutAll(middleware.GetUserIdFromToken(c)); err != nil {
+		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// 已登录的设备列表
+func GetSessions(c *gin.Context) {
+	res, err := service.GetSessions(middleware.GetUserIdFromToken(c), middleware.GetSessionIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
 	c.JSON(http.StatusOK, utils.BuildOkResp(res))
 }
+
+// 注销指定设备
+func RevokeSession(c *gin.Context) {
+	err := service.Logout(middleware.GetUserIdFromToken(c), c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		DeviceID:   c.GetHeader("X-Device-Id"),
+		DeviceName: c.GetHeader("X-Device-Name"),
+	}
+}
+
+func authErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAccountBanned):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrRefreshTokenInvalid):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrCodeTooFrequent),
+		errors.Is(err, service.ErrCodeLimitReached),
+		errors.Is(err, service.ErrCodeLocked),
+		errors.Is(err, service.ErrAccountLocked):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusOK
+	}
+}
+
+func authErrorCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserExists),
+		errors.Is(err, service.ErrPasswordTooLong),
+		errors.Is(err, service.ErrCodeInvalid),
+		errors.Is(err, service.ErrRefreshTokenInvalid),
+		errors.Is(err, service.ErrTicketInvalid),
+		errors.Is(err, service.ErrCodeTooFrequent),
+		errors.Is(err, service.ErrCodeLimitReached),
+		errors.Is(err, service.ErrCodeLocked),
+		errors.Is(err, service.ErrInvalidSmsScene),
+		errors.Is(err, service.ErrUserNotRegistered),
+		errors.Is(err, service.ErrLoginFailed),
+		errors.Is(err, service.ErrAccountLocked),
+		errors.Is(err, service.ErrAccountBanned):
+		return utils.ErrBadRequest
+	default:
+		return utils.ErrInternal
+	}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// GET /api/auth/oauth/:provider/authorize?redirect_uri=
+func OAuthAuthorize(c *gin.Context) {
+	oauthAuthorize(c, 0)
+}
+
+// GET /api/auth/oauth/:provider/link/authorize?redirect_uri= 绑定第三方账号
+func OAuthLinkAuthorize(c *gin.Context) {
+	oauthAuthorize(c, middleware.GetUserIdFromToken(c))
+}
+
+func oauthAuthorize(c *gin.Context, linkUserID uint) {
+	redirectURI := c.Query("redirect_uri")
+	if redirectURI == "" {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "redirect_uri 不能为空"))
+		return
+	}
+	res, err := service.OAuthAuthorize(c.Param("provider"), redirectURI, linkUserID)
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), utils.BuildFailResp(oauthErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+// POST /api/auth/oauth/:provider/callback
+func OAuthCallback(c *gin.Context) {
+	var req OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	res, err := service.OAuthLogin(c.Param("provider"), req.Code, req.State, clientInfo(c))
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), utils.BuildFailResp(oauthErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+// POST /api/auth/oauth/:provider/link
+func OAuthLink(c *gin.Context) {
+	var req OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	identity, err := service.LinkOAuthIdentity(middleware.GetUserIdFromToken(c), c.Param("provider"), req.Code, req.State)
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), utils.BuildFailResp(oauthErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(identity))
+}
+
+// GET /api/auth/identities
+func GetIdentities(c *gin.Context) {
+	identities, err := service.GetUserIdentities(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(identities))
+}
+
+// DELETE /api/auth/identities/:provider
+func UnlinkIdentity(c *gin.Context) {
+	if err := service.UnlinkOAuthIdentity(middleware.GetUserIdFromToken(c), c.Param("provider")); err != nil {
+		c.JSON(oauthErrorStatus(err), utils.BuildFailResp(oauthErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+func oauthErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAccountBanned):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrOAuthProviderNotFound),
+		errors.Is(err, service.ErrIdentityNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrIdentityLinked),
+		errors.Is(err, service.ErrProviderAlreadyLinked),
+		errors.Is(err, service.ErrLastLoginMethod):
+		return http.StatusConflict
+	default:
+		return http.StatusOK
+	}
+}
+
+func oauthErrorCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrOAuthProviderNotFound),
+		errors.Is(err, service.ErrOAuthStateInvalid),
+		errors.Is(err, service.ErrIdentityLinked),
+		errors.Is(err, service.ErrProviderAlreadyLinked),
+		errors.Is(err, service.ErrIdentityNotFound),
+		errors.Is(err, service.ErrLastLoginMethod),
+		errors.Is(err, service.ErrAccountBanned):
+		return utils.ErrBadRequest
+	default:
+		return utils.ErrInternal
+	}
+}
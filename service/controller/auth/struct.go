@@ -6,12 +6,21 @@ type LoginRequest struct {
 }
 type SendCodeRequest struct {
 	Phone string `json:"phone" binding:"required"`
+	Scene string `json:"scene"` // register, login，默认 register
+}
+type SmsLoginRequest struct {
+	Phone string `json:"phone" binding:"required"`
+	Code  string `json:"code" binding:"required"`
 }
 type RegisterRequest struct {
 	Phone    string `json:"phone" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Ticket   string `json:"ticket" binding:"required"` // 验证码校验通过后返回的注册凭证
 }
 type VerifyCodeRequest struct {
 	Phone string `json:"phone" binding:"required"`
 	Code  string `json:"code" binding:"required"`
 }
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,138 @@
+package call
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StartCallRequest struct {
+	CalleeID  uint            `json:"callee_id" binding:"required"`
+	MediaType string          `json:"media_type" binding:"required"` // audio, video
+	Offer     json.RawMessage `json:"offer"`                         // 主叫 SDP，服务端不解析
+}
+
+// POST /api/call/start
+func StartCall(c *gin.Context) {
+	var req StartCallRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	call, err := service.StartCall(middleware.GetUserIdFromToken(c), req.CalleeID, req.MediaType, req.Offer)
+	if err != nil {
+		c.JSON(callErrorStatus(err), utils.BuildFailResp(callErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(call))
+}
+
+type AcceptCallRequest struct {
+	Answer json.RawMessage `json:"answer"` // 被叫 SDP
+}
+
+// POST /api/call/:call_id/accept
+func AcceptCall(c *gin.Context) {
+	var req AcceptCallRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	res, err := service.AcceptCall(middleware.GetUserIdFromToken(c), c.Param("call_id"), req.Answer)
+	if err != nil {
+		c.JSON(callErrorStatus(err), utils.BuildFailResp(callErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+// POST /api/call/:call_id/reject
+func RejectCall(c *gin.Context) {
+	if err := service.RejectCall(middleware.GetUserIdFromToken(c), c.Param("call_id")); err != nil {
+		c.JSON(callErrorStatus(err), utils.BuildFailResp(callErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// POST /api/call/:call_id/cancel
+func CancelCall(c *gin.Context) {
+	if err := service.CancelCall(middleware.GetUserIdFromToken(c), c.Param("call_id")); err != nil {
+		c.JSON(callErrorStatus(err), utils.BuildFailResp(callErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// POST /api/call/:call_id/hangup
+func HangupCall(c *gin.Context) {
+	call, err := service.HangupCall(middleware.GetUserIdFromToken(c), c.Param("call_id"))
+	if err != nil {
+		c.JSON(callErrorStatus(err), utils.BuildFailResp(callErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(call))
+}
+
+type SignalRequest struct {
+	Type    string          `json:"type" binding:"required"` // offer, answer, candidate
+	Payload json.RawMessage `json:"payload" binding:"required"`
+}
+
+// POST /api/call/:call_id/signal 转发 SDP/ICE
+func RelayCallSignal(c *gin.Context) {
+	var req SignalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	err := service.RelayCallSignal(middleware.GetUserIdFromToken(c), c.Param("call_id"), req.Type, req.Payload)
+	if err != nil {
+		c.JSON(callErrorStatus(err), utils.BuildFailResp(callErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// GET /api/call/:call_id
+func GetCall(c *gin.Context) {
+	call, err := service.GetCallSession(middleware.GetUserIdFromToken(c), c.Param("call_id"))
+	if err != nil {
+		c.JSON(callErrorStatus(err), utils.BuildFailResp(callErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(call))
+}
+
+func callErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrCallNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrCallPermission),
+		errors.Is(err, service.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrCallBusy),
+		errors.Is(err, service.ErrCallInvalidState):
+		return http.StatusConflict
+	default:
+		return http.StatusOK
+	}
+}
+
+func callErrorCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrCallSelf),
+		errors.Is(err, service.ErrCallInvalidMedia),
+		errors.Is(err, service.ErrCallInvalidSignal),
+		errors.Is(err, service.ErrCallInsufficientCoins),
+		errors.Is(err, service.ErrBlocked):
+		return utils.ErrBadRequest
+	default:
+		return utils.ErrInternal
+	}
+}
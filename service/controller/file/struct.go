@@ -1 +1,16 @@
 package file
+
+type PresignUploadRequest struct {
+	Filename    string `json:"filename"`
+	Category    string `json:"category"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required"`
+}
+
+type CompleteUploadRequest struct {
+	Key string `json:"key" binding:"required"`
+}
+
+type ImageVariantsRequest struct {
+	URLs []string `json:"urls" binding:"required,max=100"`
+}
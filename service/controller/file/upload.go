@@ -1,28 +1,138 @@
 package file
 
 import (
+	"errors"
+	"net/http"
+	"strconv"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/storage"
+	"worldCity/utils"
+
 	"github.com/gin-gonic/gin"
 )
 
+// POST /api/file/upload，multipart 表单字段 file、category
 func UploadFile(c *gin.Context) {
-	// file, header, err := c.Request.FormFile("file")
-	// if err != nil {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
-	// 	return
-	// }
-	// defer file.Close()
-
-	// // 生成唯一文件名
-	// now := time.Now()
-	// fileName := now.Format("20060102/150405") + "_" + header.Filename
-
-	// url, err := utils.UploadToOSS(file, fileName)
-	// if err != nil {
-	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	// 	return
-	// }
-
-	// c.JSON(http.StatusOK, gin.H{
-	// 	"url": url,
-	// })
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "no file uploaded"))
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	defer f.Close()
+
+	file, err := service.UploadFile(middleware.GetUserIdFromToken(c), f, fh.Filename, c.PostForm("category"))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), utils.BuildFailResp(fileErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(file))
+}
+
+// POST /api/file/presign
+func PresignUpload(c *gin.Context) {
+	var req PresignUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	res, err := service.RequestDirectUpload(middleware.GetUserIdFromToken(c), req.Filename, req.Category, req.ContentType, req.Size)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), utils.BuildFailResp(fileErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+// POST /api/file/complete
+func CompleteUpload(c *gin.Context) {
+	var req CompleteUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	file, err := service.CompleteDirectUpload(middleware.GetUserIdFromToken(c), req.Key)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), utils.BuildFailResp(fileErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(file))
+}
+
+// GET /api/file/:id，查询自己上传的文件的处理状态和缩略图
+func GetFile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "invalid file id"))
+		return
+	}
+	file, err := service.GetStoredFile(middleware.GetUserIdFromToken(c), uint(id))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), utils.BuildFailResp(fileErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(file))
+}
+
+// POST /api/file/variants，按原图地址批量查询缩略图
+func GetImageVariants(c *gin.Context) {
+	var req ImageVariantsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	res, err := service.GetImageVariants(req.URLs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+// PUT /api/file/direct，本地存储的直传地址，凭签名上传，不需要登录
+func DirectUpload(c *gin.Context) {
+	err := service.ReceiveLocalDirectUpload(c.Query("key"), c.GetHeader("Content-Type"), c.Query("expires"), c.Query("signature"), c.Request.Body)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), utils.BuildFailResp(fileErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+func isFileClientError(err error) bool {
+	return errors.Is(err, service.ErrFileTooLarge) ||
+		errors.Is(err, service.ErrFileTypeNotAllow) ||
+		errors.Is(err, service.ErrFileCategory) ||
+		errors.Is(err, service.ErrUploadTicketInval) ||
+		errors.Is(err, service.ErrUploadMissing) ||
+		errors.Is(err, service.ErrImageCorrupt) ||
+		errors.Is(err, service.ErrImageRejected) ||
+		errors.Is(err, storage.ErrInvalidKey)
+}
+
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrFileTooLarge), errors.Is(err, service.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, storage.ErrInvalidSignature):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrFileNotFound):
+		return http.StatusNotFound
+	case isFileClientError(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func fileErrorCode(err error) int {
+	if isFileClientError(err) || errors.Is(err, storage.ErrInvalidSignature) ||
+		errors.Is(err, service.ErrImageTooLarge) || errors.Is(err, service.ErrFileNotFound) {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
 }
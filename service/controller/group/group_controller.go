@@ -1,16 +1,20 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"worldCity/controller/message"
+	"worldCity/middleware"
 	"worldCity/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type CreateGroupRequest struct {
-	GroupID   string `json:"group_id"`
-	Name      string `json:"name"`
-	CreatorID string `json:"creator_id"`
+	Name       string `json:"name" binding:"required"`
+	JoinMode   uint   `json:"join_mode"`   // 0 直接加入, 1 需要审批, 2 仅邀请
+	MaxMembers uint   `json:"max_members"` // 0 使用默认上限
 }
 
 func CreateGroup(c *gin.Context) {
@@ -19,9 +23,243 @@ func CreateGroup(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
-	if err := service.CreateGroup(req.GroupID, req.Name, req.CreatorID); err != nil {
+	group, err := service.CreateGroup(middleware.GetUserIdFromToken(c), req.Name, req.JoinMode, req.MaxMembers)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "创建成功"})
+	c.JSON(http.StatusOK, gin.H{"message": "创建成功", "data": group})
+}
+
+// GET /api/group/:group_id
+func GetGroupInfo(c *gin.Context) {
+	res, err := service.GetGroupInfo(middleware.GetUserIdFromToken(c), c.Param("group_id"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": res})
+}
+
+type CreateInviteRequest struct {
+	ExpireHours uint `json:"expire_hours"` // 0 表示永久有效
+	MaxUses     uint `json:"max_uses"`     // 0 表示不限次数
+}
+
+// POST /api/group/:group_id/invite
+func CreateGroupInvite(c *gin.Context) {
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	invite, err := service.CreateGroupInvite(middleware.GetUserIdFromToken(c), c.Param("group_id"), req.ExpireHours, req.MaxUses)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": invite})
+}
+
+type JoinByInviteRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// POST /api/group/join
+func JoinGroupByInvite(c *gin.Context) {
+	var req JoinByInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	group, err := service.JoinGroupByInvite(middleware.GetUserIdFromToken(c), req.Code)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "加入成功", "data": group})
+}
+
+type ApplyJoinRequest struct {
+	Message string `json:"message"`
+}
+
+// POST /api/group/:group_id/join-requests
+func ApplyJoinGroup(c *gin.Context) {
+	var req ApplyJoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	res, err := service.ApplyJoinGroup(middleware.GetUserIdFromToken(c), c.Param("group_id"), req.Message)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": res})
+}
+
+// GET /api/group/:group_id/join-requests
+func GetGroupJoinRequests(c *gin.Context) {
+	reqs, err := service.GetGroupJoinRequests(middleware.GetUserIdFromToken(c), c.Param("group_id"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": reqs})
+}
+
+type HandleJoinRequest struct {
+	Approve bool `json:"approve"`
+}
+
+// POST /api/group/:group_id/join-requests/:request_id
+func HandleGroupJoinRequest(c *gin.Context) {
+	requestID, err := strconv.ParseUint(c.Param("request_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	var req HandleJoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	err = service.HandleGroupJoinRequest(middleware.GetUserIdFromToken(c), c.Param("group_id"), uint(requestID), req.Approve)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功"})
+}
+
+// POST /api/group/:group_id/leave
+func LeaveGroup(c *gin.Context) {
+	if err := service.LeaveGroup(middleware.GetUserIdFromToken(c), c.Param("group_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已退出"})
+}
+
+type GroupMemberRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// POST /api/group/:group_id/kick
+func KickGroupMember(c *gin.Context) {
+	var req GroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := service.KickGroupMember(middleware.GetUserIdFromToken(c), c.Param("group_id"), req.UserID); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功"})
+}
+
+type SetRoleRequest struct {
+	UserID uint   `json:"user_id" binding:"required"`
+	Role   string `json:"role" binding:"required"` // admin, member
+}
+
+// POST /api/group/:group_id/role
+func SetGroupMemberRole(c *gin.Context) {
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	err := service.SetGroupMemberRole(middleware.GetUserIdFromToken(c), c.Param("group_id"), req.UserID, req.Role)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功"})
+}
+
+// POST /api/group/:group_id/transfer
+func TransferGroup(c *gin.Context) {
+	var req GroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := service.TransferGroup(middleware.GetUserIdFromToken(c), c.Param("group_id"), req.UserID); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功"})
+}
+
+type MuteMemberRequest struct {
+	UserID  uint `json:"user_id" binding:"required"`
+	Minutes uint `json:"minutes"` // 0 表示解除禁言
+}
+
+// POST /api/group/:group_id/mute
+func MuteGroupMember(c *gin.Context) {
+	var req MuteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	err := service.MuteGroupMember(middleware.GetUserIdFromToken(c), c.Param("group_id"), req.UserID, req.Minutes)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功"})
+}
+
+type MuteAllRequest struct {
+	Mute bool `json:"mute"`
+}
+
+// POST /api/group/:group_id/mute-all
+func MuteAllGroupMembers(c *gin.Context) {
+	var req MuteAllRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := service.MuteAllGroupMembers(middleware.GetUserIdFromToken(c), c.Param("group_id"), req.Mute); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "操作成功"})
+}
+
+// DELETE /api/group/:group_id 解散群
+func DissolveGroup(c *gin.Context) {
+	if err := service.DissolveGroup(middleware.GetUserIdFromToken(c), c.Param("group_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "群已解散"})
+}
+
+func groupErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrGroupNotFound),
+		errors.Is(err, service.ErrJoinRequestNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrNotGroupMember),
+		errors.Is(err, service.ErrGroupPermission),
+		errors.Is(err, service.ErrInviteOnlyGroup),
+		errors.Is(err, service.ErrGroupMuted),
+		errors.Is(err, service.ErrMentionAllDenied):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrGroupFull),
+		errors.Is(err, service.ErrAlreadyInGroup),
+		errors.Is(err, service.ErrInviteInvalid),
+		errors.Is(err, service.ErrOwnerCannotLeave),
+		errors.Is(err, service.ErrInvalidGroupRole),
+		errors.Is(err, service.ErrMentionNotMember):
+		return http.StatusBadRequest
+	default:
+		return message.ErrorStatus(err)
+	}
 }
@@ -1,29 +1,126 @@
 package controller
 
 import (
+	"encoding/json"
 	"net/http"
+	"strconv"
+	"worldCity/controller/message"
+	"worldCity/middleware"
 	"worldCity/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-type SendGroupMsgRequest struct {
-	GroupID  string `json:"group_id"`
-	SenderID string `json:"sender_id"`
-	Content  string `json:"content"`
-	Type     string `json:"type"` // text, image, audio
+func SendGroupMessage(c *gin.Context) {
+	var req service.SendGroupMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	msg, err := service.SendGroupMessage(middleware.GetUserIdFromToken(c), req)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "发送成功", "data": msg})
 }
 
-func SendGroupMessage(c *gin.Context) {
-	var req SendGroupMsgRequest
+// GET /api/chat/group/history?group_id=xxx&limit=20
+func GetGroupHistoryMessages(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	messages, err := service.GetGroupMessages(c.Query("group_id"), middleware.GetUserIdFromToken(c), limit)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// GET /api/chat/group/unread 各群的未读数和@数
+func GetGroupUnreadCounts(c *gin.Context) {
+	res, err := service.GetGroupUnreadCounts(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": res})
+}
+
+type MarkGroupReadRequest struct {
+	GroupID string `json:"group_id" binding:"required"`
+}
+
+// POST /api/chat/group/read
+func MarkGroupRead(c *gin.Context) {
+	var req MarkGroupReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := service.MarkGroupRead(middleware.GetUserIdFromToken(c), req.GroupID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Success"})
+}
+
+type EditGroupMsgRequest struct {
+	Content json.RawMessage `json:"content" binding:"required"`
+}
+
+// POST /api/chat/group/message/:id/recall
+func RecallGroupMessage(c *gin.Context) {
+	msgID, ok := getGroupMessageId(c)
+	if !ok {
+		return
+	}
+	if err := service.RecallGroupMessage(middleware.GetUserIdFromToken(c), msgID); err != nil {
+		c.JSON(message.ErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "recalled"})
+}
+
+// POST /api/chat/group/message/:id/edit
+func EditGroupMessage(c *gin.Context) {
+	msgID, ok := getGroupMessageId(c)
+	if !ok {
+		return
+	}
+	var req EditGroupMsgRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
-	err := service.SendGroupMessage(req.GroupID, req.SenderID, req.Content, req.Type)
+	msg, err := service.EditGroupMessage(middleware.GetUserIdFromToken(c), msgID, req.Content)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送失败"})
+		c.JSON(message.ErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "edited", "data": msg})
+}
+
+// DELETE /api/chat/group/message/:id 仅对自己删除
+func DeleteGroupMessage(c *gin.Context) {
+	msgID, ok := getGroupMessageId(c)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "发送成功"})
+	if err := service.DeleteGroupMessageForMe(middleware.GetUserIdFromToken(c), msgID); err != nil {
+		c.JSON(message.ErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+func getGroupMessageId(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return 0, false
+	}
+	return uint(id), true
 }
@@ -1,6 +1,8 @@
 package message
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"worldCity/middleware"
@@ -19,21 +21,27 @@ func SendPrivateMessage(c *gin.Context) {
 
 	fromID := middleware.GetUserIdFromToken(c)
 
-	err := service.SendMessage(fromID, req)
+	msg, err := service.SendMessage(fromID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "sent"})
+	c.JSON(http.StatusOK, gin.H{"message": "sent", "data": msg})
 }
 
 func GetHistoryMessages(c *gin.Context) {
 	// 参数获取
-	sessionID := c.Query("session_id")
+	peerIDStr := c.Query("peer_id")
 	beforeIDStr := c.Query("before_id") // optional
 	limitStr := c.DefaultQuery("limit", "20")
 
+	peerID, err := strconv.ParseUint(peerIDStr, 10, 64)
+	if err != nil || peerID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid peer_id"})
+		return
+	}
+
 	limit, _ := strconv.Atoi(limitStr)
 	if limit <= 0 {
 		limit = 20
@@ -45,7 +53,9 @@ func GetHistoryMessages(c *gin.Context) {
 	}
 
 	// 查询
-	messages, err := model.FetchMessages(sessionID, beforeID, limit)
+	userID := middleware.GetUserIdFromToken(c)
+	sessionID := model.BuildSessionID(userID, uint(peerID))
+	messages, err := model.FetchMessages(sessionID, userID, beforeID, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch messages failed"})
 		return
@@ -53,3 +63,96 @@ func GetHistoryMessages(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"messages": messages})
 }
+
+type EditMessageRequest struct {
+	Content json.RawMessage `json:"content" binding:"required"`
+}
+
+// POST /api/chat/private/message/:id/recall
+func RecallMessage(c *gin.Context) {
+	msgID, ok := getMessageId(c)
+	if !ok {
+		return
+	}
+	if err := service.RecallMessage(middleware.GetUserIdFromToken(c), msgID); err != nil {
+		c.JSON(ErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "recalled"})
+}
+
+// POST /api/chat/private/message/:id/edit
+func EditMessage(c *gin.Context) {
+	msgID, ok := getMessageId(c)
+	if !ok {
+		return
+	}
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	msg, err := service.EditMessage(middleware.GetUserIdFromToken(c), msgID, req.Content)
+	if err != nil {
+		c.JSON(ErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "edited", "data": msg})
+}
+
+// DELETE /api/chat/private/message/:id 仅对自己删除
+func DeleteMessage(c *gin.Context) {
+	msgID, ok := getMessageId(c)
+	if !ok {
+		return
+	}
+	if err := service.DeleteMessageForMe(middleware.GetUserIdFromToken(c), msgID); err != nil {
+		c.JSON(ErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// GET /api/chat/private/message/:id/revisions
+func GetMessageRevisions(c *gin.Context) {
+	msgID, ok := getMessageId(c)
+	if !ok {
+		return
+	}
+	revisions, err := service.GetMessageRevisions(middleware.GetUserIdFromToken(c), msgID)
+	if err != nil {
+		c.JSON(ErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+func getMessageId(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ErrorStatus 聊天相关错误对应的 HTTP 状态码
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidMessage),
+		errors.Is(err, service.ErrContentRejected),
+		errors.Is(err, service.ErrRecallExpired),
+		errors.Is(err, service.ErrEditExpired),
+		errors.Is(err, service.ErrNotRevisable),
+		errors.Is(err, service.ErrMessageRecalled):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNotMessageSender),
+		errors.Is(err, service.ErrNotMessageMember),
+		errors.Is(err, service.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrMessageNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,84 @@
+package moderation
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"worldCity/middleware"
+	"worldCity/model"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/admin/moderation/records?scene=moment&verdict=review&review_status=pending&user_id=1&page=1&page_size=20
+func ListRecords(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	uid, _ := strconv.Atoi(c.Query("user_id"))
+	res, err := service.ListModerationRecords(model.ModerationFilter{
+		Scene:        c.Query("scene"),
+		Verdict:      c.Query("verdict"),
+		ReviewStatus: c.Query("review_status"),
+		UserID:       uint(max(uid, 0)),
+	}, page, size)
+	if err != nil {
+		c.JSON(moderationErrorStatus(err), utils.BuildFailResp(moderationErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+type ReviewRequest struct {
+	Approve bool   `json:"approve"`
+	Note    string `json:"note" binding:"max=1000"`
+}
+
+// POST /api/admin/moderation/records/:id/review
+func ReviewRecord(c *gin.Context) {
+	recordID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || recordID <= 0 {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "invalid record id"))
+		return
+	}
+	var req ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	record, err := service.ReviewModeration(middleware.GetUserIdFromToken(c), uint(recordID), req.Approve, req.Note)
+	if err != nil {
+		c.JSON(moderationErrorStatus(err), utils.BuildFailResp(moderationErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(record))
+}
+
+// POST /api/admin/moderation/reload 立即重新加载敏感词词典
+func ReloadDict(c *gin.Context) {
+	if err := service.LoadTextDict(); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+func moderationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrModerationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrModerationReviewed):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidModerationOp):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func moderationErrorCode(err error) int {
+	if moderationErrorStatus(err) == http.StatusInternalServerError {
+		return utils.ErrInternal
+	}
+	return utils.ErrBadRequest
+}
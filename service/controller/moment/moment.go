@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"worldCity/middleware"
@@ -29,6 +30,21 @@ func GetMoments(c *gin.Context) {
 	}
 }
 
+// GET /api/moments/timeline?page=1&page_size=20，关注的用户和关注的话题下的动态
+func GetTimeline(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	moments, err := service.GetTimeline(middleware.GetUserIdFromToken(c), page, size)
+	if err != nil {
+		c.JSON(momentErrorStatus(err), utils.BuildFailResp(momentErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(map[string]interface{}{
+		"count":   len(moments),
+		"moments": moments,
+	}))
+}
+
 type UserResponse struct {
 	ID       uint   `json:"id"`
 	Nickname string `json:"nickname"`
@@ -57,18 +73,15 @@ func PostMoment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "先登录"))
 		return
 	}
-	if len(moment.Images) == 0 && len(moment.Content) == 0 {
-		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "动态内容不能为空"))
-		return
-	}
 	newMoment, err := service.PostMoment(&service.MomentInfo{
 		UserId:     uint(UserId),
 		Content:    moment.Content,
 		Images:     moment.Images,
+		Video:      moment.Video,
 		Visibility: moment.Visibility,
 	})
 	if err != nil {
-		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		c.JSON(momentErrorStatus(err), utils.BuildFailResp(momentErrorCode(err), err.Error()))
 		return
 	}
 
@@ -90,7 +103,7 @@ func LikeMoment(c *gin.Context) {
 	UserId := middleware.GetUserIdFromToken(c)
 	newLike, err := service.LikeMoment(UserId, like.MomentId, like.Status)
 	if err != nil {
-		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		c.JSON(momentErrorStatus(err), utils.BuildFailResp(momentErrorCode(err), err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, utils.BuildOkResp(newLike))
@@ -109,7 +122,7 @@ func CommentMoment(c *gin.Context) {
 	}
 	newComment, err := service.CommentMoment(middleware.GetUserIdFromToken(c), comment.MomentId, comment.Content)
 	if err != nil {
-		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		c.JSON(momentErrorStatus(err), utils.BuildFailResp(momentErrorCode(err), err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, utils.BuildOkResp(newComment))
@@ -118,9 +131,9 @@ func CommentMoment(c *gin.Context) {
 func GetMomentComments(c *gin.Context) {
 	idStr := c.Param("id")
 	momentId, _ := strconv.Atoi(idStr)
-	comments, err := service.GetMomentComments(uint(momentId))
+	comments, err := service.GetMomentComments(middleware.GetUserIdFromToken(c), uint(momentId))
 	if err != nil {
-		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		c.JSON(momentErrorStatus(err), utils.BuildFailResp(momentErrorCode(err), err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, utils.BuildOkResp(map[string]interface{}{
@@ -128,3 +141,80 @@ func GetMomentComments(c *gin.Context) {
 		"comments": comments,
 	}))
 }
+
+// PATCH /api/moments/:id，只能在发布后一段时间内编辑
+func EditMoment(c *gin.Context) {
+	momentId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "invalid moment id"))
+		return
+	}
+	var patch service.MomentPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	moment, err := service.EditMoment(middleware.GetUserIdFromToken(c), uint(momentId), &patch)
+	if err != nil {
+		c.JSON(momentErrorStatus(err), utils.BuildFailResp(momentErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(moment))
+}
+
+// DELETE /api/moments/:id
+func DeleteMoment(c *gin.Context) {
+	momentId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "invalid moment id"))
+		return
+	}
+	if err := service.DeleteMoment(middleware.GetUserIdFromToken(c), uint(momentId)); err != nil {
+		c.JSON(momentErrorStatus(err), utils.BuildFailResp(momentErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+type TakedownMomentRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// POST /api/admin/moments/:id/takedown
+func TakedownMoment(c *gin.Context) {
+	momentId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "invalid moment id"))
+		return
+	}
+	var req TakedownMomentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	if err := service.TakedownMoment(middleware.GetUserIdFromToken(c), uint(momentId), req.Reason); err != nil {
+		c.JSON(momentErrorStatus(err), utils.BuildFailResp(momentErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+func momentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrMomentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrBlocked), errors.Is(err, service.ErrNotMomentOwner):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrContentRejected), errors.Is(err, service.ErrInvalidMoment),
+		errors.Is(err, service.ErrMomentNotEditable):
+		return http.StatusBadRequest
+	}
+	return http.StatusOK
+}
+
+func momentErrorCode(err error) int {
+	if momentErrorStatus(err) != http.StatusOK {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
+}
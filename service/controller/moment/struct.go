@@ -3,5 +3,6 @@ package controller
 type MomentRequest struct {
 	Content    string   `json:"content"`
 	Images     []string `json:"images"`
+	Video      string   `json:"video"`
 	Visibility uint     `json:"visibility"`
 }
@@ -0,0 +1,94 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/notifications?type=like&unread_only=true&page=1&page_size=20
+func GetNotifications(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	unreadOnly, _ := strconv.ParseBool(c.DefaultQuery("unread_only", "false"))
+	res, err := service.GetNotifications(middleware.GetUserIdFromToken(c), c.Query("type"), unreadOnly, page, size)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err), utils.BuildFailResp(notificationErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+// GET /api/notifications/unread_count
+func GetUnreadCount(c *gin.Context) {
+	res, err := service.GetUnreadNotificationCount(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+type MarkReadRequest struct {
+	IDs  []uint `json:"ids"`  // 为空时标记 type 类型的全部通知
+	Type string `json:"type"` // 为空时标记全部通知
+}
+
+// POST /api/notifications/read
+func MarkRead(c *gin.Context) {
+	var req MarkReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	n, err := service.MarkNotificationsRead(middleware.GetUserIdFromToken(c), req.IDs, req.Type)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err), utils.BuildFailResp(notificationErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(map[string]interface{}{"updated": n}))
+}
+
+// GET /api/notifications/preferences
+func GetPreferences(c *gin.Context) {
+	prefs, err := service.GetNotificationPreferences(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(prefs))
+}
+
+// PUT /api/notifications/preferences，请求体为 {"like": false, "order": true}
+func UpdatePreferences(c *gin.Context) {
+	var req map[string]bool
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	prefs, err := service.UpdateNotificationPreferences(middleware.GetUserIdFromToken(c), req)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err), utils.BuildFailResp(notificationErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(prefs))
+}
+
+func notificationErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidNotifyType) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func notificationErrorCode(err error) int {
+	if errors.Is(err, service.ErrInvalidNotifyType) {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
+}
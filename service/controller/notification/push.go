@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RegisterDeviceRequest struct {
+	Platform string `json:"platform" binding:"required"` // ios, android, web
+	Token    string `json:"token" binding:"required"`    // Expo 推送令牌
+	DeviceID string `json:"device_id"`
+}
+
+// GET /api/notifications/devices
+func GetDevices(c *gin.Context) {
+	tokens, err := service.GetDeviceTokens(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(tokens))
+}
+
+// POST /api/notifications/devices，未传 device_id 时使用请求头 X-Device-Id
+func RegisterDevice(c *gin.Context) {
+	var req RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	if req.DeviceID == "" {
+		req.DeviceID = c.GetHeader("X-Device-Id")
+	}
+	token, err := service.RegisterDeviceToken(middleware.GetUserIdFromToken(c), req.Platform, req.Token, req.DeviceID)
+	if err != nil {
+		c.JSON(pushErrorStatus(err), utils.BuildFailResp(pushErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(token))
+}
+
+type UnregisterDeviceRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// DELETE /api/notifications/devices，退出登录时调用
+func UnregisterDevice(c *gin.Context) {
+	var req UnregisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	if err := service.UnregisterDeviceToken(middleware.GetUserIdFromToken(c), req.Token); err != nil {
+		c.JSON(pushErrorStatus(err), utils.BuildFailResp(pushErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// GET /api/notifications/push_settings
+func GetPushSetting(c *gin.Context) {
+	setting, err := service.GetPushSetting(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(setting))
+}
+
+type PushSettingRequest struct {
+	QuietStart string `json:"quiet_start"` // HH:MM，和 quiet_end 都为空时关闭免打扰
+	QuietEnd   string `json:"quiet_end"`
+	Timezone   string `json:"timezone"` // 例如 Asia/Shanghai
+}
+
+// PUT /api/notifications/push_settings
+func UpdatePushSetting(c *gin.Context) {
+	var req PushSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	setting, err := service.UpdatePushSetting(middleware.GetUserIdFromToken(c), req.QuietStart, req.QuietEnd, req.Timezone)
+	if err != nil {
+		c.JSON(pushErrorStatus(err), utils.BuildFailResp(pushErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(setting))
+}
+
+func pushErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidPushPlatform),
+		errors.Is(err, service.ErrInvalidPushToken),
+		errors.Is(err, service.ErrInvalidQuietHours):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrDeviceTokenNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func pushErrorCode(err error) int {
+	if pushErrorStatus(err) != http.StatusInternalServerError {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
+}
@@ -40,13 +40,7 @@ func (ctrl *OrderController) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// --- 获取用户 ID (需要认证中间件支持) ---
-	userID := middleware.GetUserIdFromToken(c) // 实现这个辅助函数
-	if userID == 0 {
-		response.Error(c, http.StatusUnauthorized, errors.New("user not authenticated"))
-		return
-	}
-	req.UserID = userID
+	req.UserID = middleware.GetUserIdFromToken(c)
 
 	orderResp, err := ctrl.orderService.CreateOrder(req)
 	if err != nil {
@@ -82,14 +76,7 @@ func (ctrl *OrderController) GetOrder(c *gin.Context) {
 		return
 	}
 
-	// --- 获取用户 ID (需要认证中间件支持) ---
 	userID := middleware.GetUserIdFromToken(c)
-	if userID == 0 {
-		response.Error(c, http.StatusUnauthorized, errors.New("user not authenticated"))
-		return
-	}
-	// --- End Auth ---
-
 	orderResp, err := ctrl.orderService.GetOrderByOrderNo(orderNo, userID)
 	if err != nil {
 		errMsg := err.Error()
@@ -135,14 +122,7 @@ func (ctrl *OrderController) GetOrders(c *gin.Context) {
 		}
 	}
 
-	// --- 获取用户 ID (需要认证中间件支持) ---
-	userID := middleware.GetUserIdFromToken(c)
-	if userID == 0 {
-		response.Error(c, http.StatusUnauthorized, errors.New("user not authenticated"))
-		return
-	}
-	req.UserID = userID
-	// --- End Auth ---
+	req.UserID = middleware.GetUserIdFromToken(c)
 
 	// 校验分页参数
 	if req.Page < 1 {
@@ -190,14 +170,7 @@ func (ctrl *OrderController) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	// --- 获取用户 ID (需要认证中间件支持) ---
 	userID := middleware.GetUserIdFromToken(c)
-	if userID == 0 {
-		response.Error(c, http.StatusUnauthorized, errors.New("user not authenticated"))
-		return
-	}
-	// --- End Auth ---
-
 	err := ctrl.orderService.CancelOrder(orderNo, userID, req)
 	if err != nil {
 		errMsg := err.Error()
@@ -223,14 +196,7 @@ func (ctrl *OrderController) OrderReview(c *gin.Context) {
 		return
 	}
 
-	// --- 获取用户 ID (需要认证中间件支持) ---
 	userID := middleware.GetUserIdFromToken(c)
-	if userID == 0 {
-		response.Error(c, http.StatusUnauthorized, errors.New("user not authenticated"))
-		return
-	}
-	// --- End Auth ---
-
 	err := ctrl.orderService.OrderReview(req.OrderNo, userID, req.Score, req.Tags)
 	if err != nil {
 		errMsg := err.Error()
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateReportRequest struct {
+	TargetType string `json:"target_type" binding:"required"` // user, moment, comment, message
+	TargetID   uint   `json:"target_id" binding:"required"`
+	Reason     string `json:"reason" binding:"required"`
+	Detail     string `json:"detail" binding:"max=1000"`
+}
+
+// POST /api/report
+func CreateReport(c *gin.Context) {
+	var req CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	report, err := service.CreateReport(middleware.GetUserIdFromToken(c), req.TargetType, req.TargetID, req.Reason, req.Detail)
+	if err != nil {
+		c.JSON(reportErrorStatus(err), utils.BuildFailResp(reportErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(map[string]interface{}{
+		"id":     report.ID,
+		"status": report.Status,
+	}))
+}
+
+// GET /api/admin/reports?status=pending&page=1&page_size=20
+func ListReports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	res, err := service.ListReports(c.Query("status"), page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+type ResolveReportRequest struct {
+	Action  string `json:"action" binding:"required"` // dismiss, warn, takedown, temp_ban, perm_ban
+	Note    string `json:"note" binding:"max=1000"`
+	BanDays int    `json:"ban_days"` // temp_ban 时必填
+}
+
+// POST /api/admin/reports/:id/resolve
+func ResolveReport(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || reportID <= 0 {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "invalid report id"))
+		return
+	}
+	var req ResolveReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	report, err := service.ResolveReport(middleware.GetUserIdFromToken(c), uint(reportID), req.Action, req.Note, req.BanDays)
+	if err != nil {
+		c.JSON(reportErrorStatus(err), utils.BuildFailResp(reportErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(report))
+}
+
+// GET /api/admin/users/:id/sanctions
+func GetUserSanctions(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	sanctions, err := service.GetUserSanctions(UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(sanctions))
+}
+
+func isReportClientError(err error) bool {
+	return errors.Is(err, service.ErrInvalidReport) ||
+		errors.Is(err, service.ErrReportSelf) ||
+		errors.Is(err, service.ErrInvalidReportAct) ||
+		errors.Is(err, service.ErrInvalidBanDuration)
+}
+
+func reportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrReportNotFound),
+		errors.Is(err, service.ErrReportTargetGone):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrReportDuplicate),
+		errors.Is(err, service.ErrReportHandled):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrNotMessageMember):
+		return http.StatusForbidden
+	case isReportClientError(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func reportErrorCode(err error) int {
+	if reportErrorStatus(err) == http.StatusInternalServerError {
+		return utils.ErrInternal
+	}
+	return utils.ErrBadRequest
+}
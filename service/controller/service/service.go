@@ -1,8 +1,10 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"worldCity/middleware"
 	"worldCity/service"
 	"worldCity/utils"
 
@@ -23,7 +25,7 @@ func GetProvidersByCategory(c *gin.Context) {
 	CategoryIDStr := c.Param("category_id") // e.g., /categories/:categoryID/providers
 	categoryID, _ := strconv.Atoi(CategoryIDStr)
 
-	providers, err := service.GetProvidersByCategoryID(uint(categoryID))
+	providers, err := service.GetProvidersByCategoryID(middleware.GetUserIdFromToken(c), uint(categoryID))
 	if err != nil {
 		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
@@ -41,7 +43,11 @@ func GetProviderById(c *gin.Context) {
 		return
 	}
 
-	provider, err := service.GetProviderById(uint(ProviderId))
+	provider, err := service.GetProviderById(middleware.GetUserIdFromToken(c), uint(ProviderId))
+	if errors.Is(err, service.ErrBlocked) {
+		c.JSON(http.StatusForbidden, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
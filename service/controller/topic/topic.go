@@ -0,0 +1,93 @@
+package topic
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/topics/trending?limit=20
+func GetTrendingTopics(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	topics, err := service.GetTrendingTopics(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(topics))
+}
+
+// GET /api/topics/following
+func GetFollowedTopics(c *gin.Context) {
+	topics, err := service.GetFollowedTopics(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(topics))
+}
+
+// GET /api/topics/:name
+func GetTopic(c *gin.Context) {
+	topic, err := service.GetTopic(middleware.GetUserIdFromToken(c), c.Param("name"))
+	if err != nil {
+		c.JSON(topicErrorStatus(err), utils.BuildFailResp(topicErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(topic))
+}
+
+// GET /api/topics/:name/moments?page=1&page_size=20
+func GetTopicMoments(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	moments, err := service.GetTopicMoments(middleware.GetUserIdFromToken(c), c.Param("name"), page, size)
+	if err != nil {
+		c.JSON(topicErrorStatus(err), utils.BuildFailResp(topicErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(map[string]interface{}{
+		"count":   len(moments),
+		"moments": moments,
+	}))
+}
+
+// POST /api/topics/:name/follow
+func FollowTopic(c *gin.Context) {
+	if err := service.FollowTopic(middleware.GetUserIdFromToken(c), c.Param("name")); err != nil {
+		c.JSON(topicErrorStatus(err), utils.BuildFailResp(topicErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// DELETE /api/topics/:name/follow
+func UnfollowTopic(c *gin.Context) {
+	if err := service.UnfollowTopic(middleware.GetUserIdFromToken(c), c.Param("name")); err != nil {
+		c.JSON(topicErrorStatus(err), utils.BuildFailResp(topicErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+func topicErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrTopicNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidTopic):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func topicErrorCode(err error) int {
+	if topicErrorStatus(err) != http.StatusInternalServerError {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
+}
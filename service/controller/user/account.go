@@ -0,0 +1,107 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/user/me/export 申请导出个人数据
+func RequestDataExport(c *gin.Context) {
+	export, err := service.RequestDataExport(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(accountErrorStatus(err), utils.BuildFailResp(accountErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(export))
+}
+
+// GET /api/user/me/export
+func GetDataExports(c *gin.Context) {
+	exports, err := service.GetDataExports(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(exports))
+}
+
+// GET /api/user/me/export/:export_id/download
+func DownloadDataExport(c *gin.Context) {
+	exportID, err := strconv.ParseUint(c.Param("export_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	path, err := service.GetDataExportFile(middleware.GetUserIdFromToken(c), uint(exportID))
+	if err != nil {
+		c.JSON(accountErrorStatus(err), utils.BuildFailResp(accountErrorCode(err), err.Error()))
+		return
+	}
+	c.FileAttachment(path, "worldcity-data-export.zip")
+}
+
+type DeleteAccountRequest struct {
+	Reason string `json:"reason"`
+}
+
+// POST /api/user/me/delete 申请注销账号
+func RequestAccountDeletion(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	deletion, err := service.RequestAccountDeletion(middleware.GetUserIdFromToken(c), req.Reason)
+	if err != nil {
+		c.JSON(accountErrorStatus(err), utils.BuildFailResp(accountErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(deletion))
+}
+
+// GET /api/user/me/delete 查看注销申请
+func GetAccountDeletion(c *gin.Context) {
+	deletion, err := service.GetAccountDeletion(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(accountErrorStatus(err), utils.BuildFailResp(accountErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(deletion))
+}
+
+// POST /api/user/me/delete/cancel 冷静期内撤销注销
+func CancelAccountDeletion(c *gin.Context) {
+	if err := service.CancelAccountDeletion(middleware.GetUserIdFromToken(c)); err != nil {
+		c.JSON(accountErrorStatus(err), utils.BuildFailResp(accountErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+func accountErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrExportNotFound),
+		errors.Is(err, service.ErrDeletionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrExportInProgress),
+		errors.Is(err, service.ErrExportNotReady),
+		errors.Is(err, service.ErrDeletionPending),
+		errors.Is(err, service.ErrOwnsGroups):
+		return http.StatusConflict
+	default:
+		return http.StatusOK
+	}
+}
+
+func accountErrorCode(err error) int {
+	if accountErrorStatus(err) != http.StatusOK {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
+}
@@ -0,0 +1,238 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AddPhotoRequest struct {
+	FileID uint `json:"file_id" binding:"required"` // 上传接口返回的文件 ID
+}
+
+type ReorderPhotosRequest struct {
+	URLs []string `json:"urls" binding:"required"`
+}
+
+type AddVideoRequest struct {
+	FileID      uint   `json:"file_id" binding:"required"`
+	CoverFileID uint   `json:"cover_file_id"`
+	Title       string `json:"title" binding:"max=100"`
+	Access      uint   `json:"access"` // 0:public 1:followers 2:paid
+	Price       uint   `json:"price"`  // 付费解锁的金币数
+}
+
+type UpdateVideoRequest struct {
+	Title  string `json:"title" binding:"max=100"`
+	Access uint   `json:"access"`
+	Price  uint   `json:"price"`
+}
+
+type ReorderVideosRequest struct {
+	IDs []string `json:"ids" binding:"required"`
+}
+
+// POST /api/user/:id/photos
+func AddPhoto(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	var req AddPhotoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	photos, err := service.AddProfilePhoto(UserId, req.FileID)
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(photos))
+}
+
+// PUT /api/user/:id/photos/order
+func ReorderPhotos(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	var req ReorderPhotosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	photos, err := service.ReorderProfilePhotos(UserId, req.URLs)
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(photos))
+}
+
+// DELETE /api/user/:id/photos?url=
+func RemovePhoto(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	photos, err := service.RemoveProfilePhoto(UserId, c.Query("url"))
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(photos))
+}
+
+// POST /api/user/:id/videos
+func AddVideo(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	var req AddVideoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	videos, err := service.AddProfileVideo(UserId, &service.ProfileVideoInfo{
+		FileID:      req.FileID,
+		CoverFileID: req.CoverFileID,
+		Title:       req.Title,
+		Access:      req.Access,
+		Price:       req.Price,
+	})
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(videos))
+}
+
+// PUT /api/user/:id/videos/:video_id
+func UpdateVideo(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	var req UpdateVideoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	videos, err := service.UpdateProfileVideo(UserId, c.Param("video_id"), req.Title, req.Access, req.Price)
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(videos))
+}
+
+// PUT /api/user/:id/videos/order
+func ReorderVideos(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	var req ReorderVideosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	videos, err := service.ReorderProfileVideos(UserId, req.IDs)
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(videos))
+}
+
+// DELETE /api/user/:id/videos/:video_id
+func RemoveVideo(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	videos, err := service.RemoveProfileVideo(UserId, c.Param("video_id"))
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(videos))
+}
+
+// POST /api/user/:id/videos/:video_id/unlock 使用金币解锁其他用户的付费视频
+func UnlockVideo(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	video, err := service.UnlockProfileVideo(middleware.GetUserIdFromToken(c), UserId, c.Param("video_id"))
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(video))
+}
+
+// POST /api/user/:id/follow
+func FollowUser(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	if err := service.FollowUser(middleware.GetUserIdFromToken(c), UserId); err != nil {
+		c.JSON(mediaErrorStatus(err), utils.BuildFailResp(mediaErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// DELETE /api/user/:id/follow
+func UnfollowUser(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	if err := service.UnfollowUser(middleware.GetUserIdFromToken(c), UserId); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+func isMediaClientError(err error) bool {
+	return errors.Is(err, service.ErrMediaLimit) ||
+		errors.Is(err, service.ErrInvalidMediaFile) ||
+		errors.Is(err, service.ErrInvalidMediaOrder) ||
+		errors.Is(err, service.ErrInvalidVideoAccess) ||
+		errors.Is(err, service.ErrVideoNotPaid) ||
+		errors.Is(err, service.ErrCannotFollowSelf) ||
+		errors.Is(err, service.ErrCannotUnlockOwnItem) ||
+		errors.Is(err, service.ErrInsufficientCoins)
+}
+
+func mediaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrMediaNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInsufficientCoins):
+		return http.StatusPaymentRequired
+	case errors.Is(err, service.ErrBlocked):
+		return http.StatusForbidden
+	case isMediaClientError(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func mediaErrorCode(err error) int {
+	if isMediaClientError(err) || errors.Is(err, service.ErrMediaNotFound) || errors.Is(err, service.ErrBlocked) {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
+}
@@ -0,0 +1,100 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"worldCity/middleware"
+	"worldCity/service"
+	"worldCity/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/user/:id/block
+func BlockUser(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	if err := service.BlockUser(middleware.GetUserIdFromToken(c), UserId); err != nil {
+		c.JSON(safetyErrorStatus(err), utils.BuildFailResp(safetyErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// DELETE /api/user/:id/block
+func UnblockUser(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	if err := service.UnblockUser(middleware.GetUserIdFromToken(c), UserId); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// POST /api/user/:id/mute
+func MuteUser(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	if err := service.MuteUser(middleware.GetUserIdFromToken(c), UserId); err != nil {
+		c.JSON(safetyErrorStatus(err), utils.BuildFailResp(safetyErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// DELETE /api/user/:id/mute
+func UnmuteUser(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	if err := service.UnmuteUser(middleware.GetUserIdFromToken(c), UserId); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
+
+// GET /api/user/me/blocks
+func GetBlockedUsers(c *gin.Context) {
+	blocks, err := service.GetBlockedUsers(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(blocks))
+}
+
+// GET /api/user/me/mutes
+func GetMutedUsers(c *gin.Context) {
+	mutes, err := service.GetMutedUsers(middleware.GetUserIdFromToken(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(mutes))
+}
+
+func safetyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrCannotBlockSelf):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func safetyErrorCode(err error) int {
+	if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrCannotBlockSelf) {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
+}
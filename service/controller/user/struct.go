@@ -14,3 +14,15 @@ type ProfileUpdateRequest struct {
 	Birthday string `json:"birthday"`
 	Avatar   string `json:"avatar"`
 }
+
+type DiscoverRequest struct {
+	Gender      *uint  `form:"gender"`
+	MinAge      int    `form:"min_age"`
+	MaxAge      int    `form:"max_age"`
+	Tags        string `form:"tags"` // 逗号分隔，匹配任一标签
+	Online      bool   `form:"online"`
+	HasServices bool   `form:"has_services"`
+	Sort        string `form:"sort"` // recommend, newest
+	Page        int    `form:"page"`
+	PageSize    int    `form:"page_size"`
+}
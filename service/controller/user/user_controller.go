@@ -1,8 +1,10 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"worldCity/middleware"
 	"worldCity/service"
 	"worldCity/utils"
@@ -17,7 +19,7 @@ func CheckLogin(c *gin.Context) {
 		return
 	}
 
-	res, err := service.GetUserProfile(UserId)
+	res, err := service.GetUserProfile(UserId, middleware.GetRoleFromToken(c), UserId)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
@@ -32,7 +34,11 @@ func GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	res, err := service.GetUserProfile(UserId)
+	res, err := service.GetUserProfile(middleware.GetUserIdFromToken(c), middleware.GetRoleFromToken(c), UserId)
+	if errors.Is(err, service.ErrBlocked) {
+		c.JSON(http.StatusForbidden, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
@@ -40,10 +46,10 @@ func GetUserProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, utils.BuildOkResp(res))
 }
 
-// 更新自己的profile，只允许自己修改
+// 更新profile，路由上限制只允许自己修改
+// 兼容旧接口：只更新非零值字段，需要清空或写入零值请使用 PATCH
 func UpdateProfile(c *gin.Context) {
-	// 从token中获取用户ID
-	UserId := middleware.GetUserIdFromToken(c)
+	UserId := utils.GetUserIdFromUrl(c, "id")
 	if UserId == 0 {
 		return
 	}
@@ -54,15 +60,73 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	res, err := service.UpdateProfile(UserId, req.Avatar, req.Nickname, req.Gender)
+	var patch service.ProfilePatch
+	if req.Nickname != "" {
+		patch.Nickname = utils.NewOptional(req.Nickname)
+	}
+	if req.Avatar != "" {
+		patch.Avatar = utils.NewOptional(req.Avatar)
+	}
+	if req.Gender != 0 {
+		patch.Gender = utils.NewOptional(req.Gender)
+	}
+	if req.Birthday != "" {
+		patch.Birthday = utils.NewOptional(req.Birthday)
+	}
+	res, err := service.PatchProfile(UserId, &patch)
 	if err != nil {
-		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		c.JSON(profileErrorStatus(err), utils.BuildFailResp(profileErrorCode(err), err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, utils.BuildOkResp(res))
 }
 
+// PATCH /api/user/:id 部分更新资料，未提供的字段不变，传 null 清空
+func PatchProfile(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+
+	var patch service.ProfilePatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	res, err := service.PatchProfile(UserId, &patch)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), utils.BuildFailResp(profileErrorCode(err), err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
+func isProfileClientError(err error) bool {
+	return errors.Is(err, service.ErrInvalidProfile) ||
+		errors.Is(err, service.ErrProfileNothingToSave) ||
+		errors.Is(err, service.ErrMediaLimit) ||
+		errors.Is(err, service.ErrInvalidMediaFile) ||
+		errors.Is(err, service.ErrContentRejected)
+}
+
+func profileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNicknameTooFrequent):
+		return http.StatusTooManyRequests
+	case isProfileClientError(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func profileErrorCode(err error) int {
+	if isProfileClientError(err) || errors.Is(err, service.ErrNicknameTooFrequent) {
+		return utils.ErrBadRequest
+	}
+	return utils.ErrInternal
+}
+
 func GetUserList(c *gin.Context) {
 	PageNumStr := c.DefaultQuery("page_num", "1")
 	PageNum, err := strconv.Atoi(PageNumStr)
@@ -78,7 +142,7 @@ func GetUserList(c *gin.Context) {
 		return
 	}
 
-	res, err := service.GetUserList(uint(PageNum), uint(PageSize))
+	res, err := service.GetUserList(middleware.GetUserIdFromToken(c), middleware.GetRoleFromToken(c), uint(PageNum), uint(PageSize))
 	if err != nil {
 		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
@@ -87,9 +151,43 @@ func GetUserList(c *gin.Context) {
 	c.JSON(http.StatusOK, utils.BuildOkResp(res))
 }
 
+// GET /api/user/discover 发现用户，支持筛选和推荐排序
+func DiscoverUsers(c *gin.Context) {
+	var req DiscoverRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	var tags []string
+	for _, tag := range strings.Split(req.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	res, err := service.DiscoverUsers(middleware.GetUserIdFromToken(c), &service.DiscoverQuery{
+		Gender:      req.Gender,
+		MinAge:      req.MinAge,
+		MaxAge:      req.MaxAge,
+		Tags:        tags,
+		Online:      req.Online,
+		HasServices: req.HasServices,
+		Sort:        req.Sort,
+		Page:        req.Page,
+		PageSize:    req.PageSize,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDiscoverQuery) {
+			c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, utils.BuildFailResp(utils.ErrInternal, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(res))
+}
+
 type UserTagRequest struct {
-	UserId uint   `json:"user_id"`
-	Tag    string `json:"tag"`
+	Tag string `json:"tag" binding:"required"`
 }
 
 type UserTagResponse struct {
@@ -116,12 +214,20 @@ func GetTags(c *gin.Context) {
 }
 
 func CreateTag(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
 	var req UserTagRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
 		return
 	}
-	res, err := service.CreateTag(req.UserId, req.Tag)
+	res, err := service.CreateTag(UserId, req.Tag)
+	if errors.Is(err, service.ErrContentRejected) {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
@@ -130,6 +236,10 @@ func CreateTag(c *gin.Context) {
 }
 
 func DeleteTag(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
 	idStr := c.Param("tag_id")
 	TagId, err := strconv.Atoi(idStr)
 	if err != nil || TagId == 0 {
@@ -137,7 +247,7 @@ func DeleteTag(c *gin.Context) {
 		return
 	}
 
-	err = service.DeleteTag(uint(TagId))
+	err = service.DeleteTag(UserId, uint(TagId))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
@@ -151,8 +261,12 @@ func GetUserMoments(c *gin.Context) {
 		return
 	}
 
-	moments, err := service.GetUserMoments(UserId)
+	moments, err := service.GetUserMoments(middleware.GetUserIdFromToken(c), UserId)
 	if err != nil {
+		if errors.Is(err, service.ErrBlocked) {
+			c.JSON(http.StatusForbidden, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+			return
+		}
 		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
 	}
@@ -243,11 +357,15 @@ func GetUserAddresses(c *gin.Context) {
 }
 
 func DeleteAddress(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
 	AddressId := utils.GetUserIdFromUrl(c, "address_id")
 	if AddressId == 0 {
 		return
 	}
-	err := service.DeleteAddress(AddressId)
+	err := service.DeleteAddress(UserId, AddressId)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrInternal, err.Error()))
 		return
@@ -255,3 +373,25 @@ func DeleteAddress(c *gin.Context) {
 
 	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
 }
+
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// 调整用户角色，仅超级管理员
+func SetUserRole(c *gin.Context) {
+	UserId := utils.GetUserIdFromUrl(c, "id")
+	if UserId == 0 {
+		return
+	}
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.BuildFailResp(utils.ErrBadRequest, "参数有误"))
+		return
+	}
+	if err := service.SetUserRole(UserId, req.Role); err != nil {
+		c.JSON(http.StatusOK, utils.BuildFailResp(utils.ErrBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, utils.BuildOkResp(nil))
+}
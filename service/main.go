@@ -1,30 +1,81 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
 	"time"
 	"worldCity/config"
 	"worldCity/model"
 	"worldCity/router"
+	"worldCity/service"
+	"worldCity/storage"
+	"worldCity/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	config.InitConfig()
+	configPath := flag.String("config", config.DefaultConfigPath, "path to config file")
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	flag.Parse()
+
+	config.InitConfig(*configPath)
+	conf := config.GetConf()
+	if *printConfig {
+		out, err := conf.Redacted()
+		if err != nil {
+			log.Fatal("print config error: ", err)
+		}
+		fmt.Print(out)
+		return
+	}
+
+	gin.SetMode(conf.Server.Mode)
+	jwtConf := conf.JWT
+	utils.InitJWTKeys(jwtConf.ActiveKid, jwtConf.Keys)
+	utils.InitPasswordCost(conf.Login.PasswordCost)
+	service.InitOAuthProviders()
+	service.InitPushDispatcher()
+	service.InitEventBus()
 	model.Init()
+	if err := storage.Init(); err != nil {
+		log.Fatal("storage init error: ", err)
+	}
+	if err := service.LoadTextDict(); err != nil {
+		log.Println("text dict load error: ", err)
+	}
 
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Device-Id", "X-Device-Name"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	router.InitRoutes(r)
+	// 本地存储的文件由 API 服务直接提供访问
+	if local, ok := storage.Get().(*storage.Local); ok {
+		r.StaticFS("/uploads", storage.PublicFS(gin.Dir(local.Dir, false)))
+	}
+
+	// 未接来电超时检查
+	go service.RunCallWatcher(5 * time.Second)
+	// 图片缩略图和审核
+	go service.RunImageWorker(time.Minute)
+	// 敏感词词典热更新
+	go service.RunTextDictWatcher(service.TextDictReloadInterval())
+	// 领域事件的投递
+	go service.RunEventRelay(time.Second)
+	go service.RunEventConsumer()
+	// 通知的手机推送
+	go service.RunPushWorker(service.PushBatchInterval())
+	// 冷静期结束的账号注销
+	go service.RunAccountDeletionWatcher(time.Hour)
 
-	r.Run(":15151")
+	r.Run(fmt.Sprintf(":%d", conf.Server.Port))
 }
@@ -2,40 +2,98 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
+	"worldCity/model"
 	"worldCity/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 const (
-	UID_KEY = "userID"
+	UID_KEY  = "userID"
+	SID_KEY  = "sessionID"
+	ROLE_KEY = "role"
 )
 
 func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Authorization")
 		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "No token"})
-			// c.Abort()
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No token"})
 			return
 		}
 
 		token = strings.TrimPrefix(token, "Bearer ")
 
 		claims, err := utils.ParseToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		if err != nil || claims.UserID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		// 会话被注销后，未过期的访问令牌也立即失效
+		if ok, err := model.SessionExists(claims.SessionID); err != nil || !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
 			return
 		}
 
-		c.Set(UID_KEY, claims.UserID)
+		role := claims.Role
+		if role == "" {
+			role = model.RoleUser
+		}
+		c.Set(UID_KEY, uint(claims.UserID))
+		c.Set(SID_KEY, claims.SessionID)
+		c.Set(ROLE_KEY, role)
 		c.Set("accid", claims.Accid)
+		// 刷新在线状态
+		model.TouchOnline(uint(claims.UserID))
+		c.Next()
+	}
+}
+
+// RequireRole 只允许指定角色访问，超级管理员不受限制，需要放在 JWTAuth 之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := GetRoleFromToken(c)
+		if role == model.RoleSuperAdmin {
+			c.Next()
+			return
+		}
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+	}
+}
+
+// RequireSelf 路径参数中的用户ID必须是当前用户，有用户管理权限的角色除外
+func RequireSelf(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, err := strconv.ParseUint(c.Param(param), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+			return
+		}
+		if uint(uid) != GetUserIdFromToken(c) && !model.HasPermission(GetRoleFromToken(c), model.PermManageUsers) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			return
+		}
 		c.Next()
 	}
 }
 
 func GetUserIdFromToken(c *gin.Context) uint {
-	return uint(c.GetUint64(UID_KEY))
+	return c.GetUint(UID_KEY)
+}
+
+func GetSessionIdFromToken(c *gin.Context) string {
+	return c.GetString(SID_KEY)
+}
+
+func GetRoleFromToken(c *gin.Context) string {
+	return c.GetString(ROLE_KEY)
 }
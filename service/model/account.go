@@ -0,0 +1,308 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 数据导出状态
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+// DataExport 用户个人数据导出任务
+type DataExport struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	Status    string     `gorm:"size:16" json:"status"`
+	FilePath  string     `json:"-"`
+	Size      int64      `json:"size"`
+	Error     string     `gorm:"size:255" json:"error,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// 账号注销状态
+const (
+	DeletionStatusPending   = "pending"
+	DeletionStatusCancelled = "cancelled"
+	DeletionStatusCompleted = "completed"
+)
+
+// AccountDeletion 账号注销申请，冷静期结束后执行
+type AccountDeletion struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index" json:"user_id"`
+	Status      string     `gorm:"size:16;index" json:"status"`
+	Reason      string     `gorm:"size:255" json:"reason"`
+	ScheduledAt time.Time  `gorm:"index" json:"scheduled_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func CreateDataExport(export *DataExport) error {
+	return GetDB().Create(export).Error
+}
+
+func GetDataExport(id uint) (*DataExport, error) {
+	var export DataExport
+	if err := GetDB().First(&export, id).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func GetUserDataExports(userID uint) ([]DataExport, error) {
+	var exports []DataExport
+	err := GetDB().Where("user_id = ?", userID).Order("id desc").Limit(20).Find(&exports).Error
+	return exports, err
+}
+
+func UpdateDataExport(id uint, updates map[string]interface{}) error {
+	return GetDB().Model(&DataExport{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// 已过期的导出，文件和记录一起清理
+func GetExpiredDataExports(now time.Time) ([]DataExport, error) {
+	var exports []DataExport
+	err := GetDB().Where("expires_at IS NOT NULL AND expires_at <= ?", now).Limit(100).Find(&exports).Error
+	return exports, err
+}
+
+func DeleteDataExport(id uint) error {
+	return GetDB().Delete(&DataExport{}, id).Error
+}
+
+// 用户发送的消息的修改记录，包括被下架的
+func userRevisionsQuery(db *gorm.DB, userID uint) *gorm.DB {
+	uid := strconv.FormatUint(uint64(userID), 10)
+	return db.Where("(scope = ? AND message_id IN (?)) OR (scope = ? AND message_id IN (?))",
+		MsgScopeP2P, GetDB().Model(&Message{}).Select("id").Where("sender_id = ?", userID),
+		MsgScopeGroup, GetDB().Model(&GroupMessage{}).Select("id").Where("sender_id = ?", uid))
+}
+
+func GetPendingDeletion(userID uint) (*AccountDeletion, error) {
+	var deletion AccountDeletion
+	err := GetDB().Where("user_id = ? AND status = ?", userID, DeletionStatusPending).First(&deletion).Error
+	if err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+func CreateAccountDeletion(deletion *AccountDeletion) error {
+	return GetDB().Create(deletion).Error
+}
+
+func UpdateAccountDeletion(id uint, updates map[string]interface{}) error {
+	return GetDB().Model(&AccountDeletion{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// 冷静期已结束、等待执行的注销申请
+func GetDueDeletions(now time.Time) ([]AccountDeletion, error) {
+	var deletions []AccountDeletion
+	err := GetDB().Where("status = ? AND scheduled_at <= ?", DeletionStatusPending, now).
+		Limit(100).Find(&deletions).Error
+	return deletions, err
+}
+
+// 收集用户的全部个人数据，key 为导出文件名
+// 新增保存个人数据的表时，需要同时加到这里和 AnonymizeUser
+func CollectUserData(userID uint) (map[string]interface{}, error) {
+	db := GetDB()
+	uid := strconv.FormatUint(uint64(userID), 10)
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	user.Password = ""
+
+	var (
+		tags           []Tags
+		addresses      []Address
+		moments        []Moment
+		likes          []MomentLike
+		comments       []MomentComment
+		messages       []Message
+		groupMessages  []GroupMessage
+		groupMembers   []GroupMember
+		orders         []Order
+		calls          []CallSession
+		identities     []UserIdentity
+		loginEvents    []LoginEvent
+		messageDeletes []MessageDeletion
+		revisions      []MessageRevision
+		follows        []UserFollow
+		blocks         []UserBlock
+		mutes          []UserMute
+		videoUnlocks   []VideoUnlock
+		files          []StoredFile
+		reports        []Report
+		sanctions      []UserSanction
+		moderations    []ModerationRecord
+		notifications  []Notification
+		notifyPrefs    []NotificationPreference
+		deviceTokens   []DeviceToken
+		pushSettings   []PushSetting
+		topicFollows   []TopicFollow
+	)
+	queries := []struct {
+		dest  interface{}
+		query string
+		args  []interface{}
+	}{
+		{&tags, "user_id = ?", []interface{}{userID}},
+		{&addresses, "user_id = ?", []interface{}{userID}},
+		{&moments, "user_id = ?", []interface{}{userID}},
+		{&likes, "user_id = ?", []interface{}{userID}},
+		{&comments, "user_id = ?", []interface{}{userID}},
+		{&messages, "sender_id = ? OR receiver_id = ?", []interface{}{userID, userID}},
+		{&groupMessages, "sender_id = ?", []interface{}{uid}},
+		{&groupMembers, "user_id = ?", []interface{}{uid}},
+		{&orders, "user_id = ?", []interface{}{userID}},
+		{&calls, "caller_id = ? OR callee_id = ?", []interface{}{userID, userID}},
+		{&identities, "user_id = ?", []interface{}{userID}},
+		{&loginEvents, "user_id = ?", []interface{}{userID}},
+		{&messageDeletes, "user_id = ?", []interface{}{userID}},
+		{&follows, "follower_id = ? OR followee_id = ?", []interface{}{userID, userID}},
+		{&blocks, "user_id = ?", []interface{}{userID}},
+		{&mutes, "user_id = ?", []interface{}{userID}},
+		{&videoUnlocks, "user_id = ? OR owner_id = ?", []interface{}{userID, userID}},
+		{&files, "uploader_id = ? OR id IN (?)", []interface{}{userID, db.Model(&FileOwner{}).Select("file_id").Where("user_id = ?", userID)}},
+		{&reports, "reporter_id = ?", []interface{}{userID}},
+		{&sanctions, "user_id = ?", []interface{}{userID}},
+		{&moderations, "user_id = ?", []interface{}{userID}},
+		{&notifications, "user_id = ?", []interface{}{userID}},
+		{&notifyPrefs, "user_id = ?", []interface{}{userID}},
+		{&deviceTokens, "user_id = ?", []interface{}{userID}},
+		{&pushSettings, "user_id = ?", []interface{}{userID}},
+		{&topicFollows, "user_id = ?", []interface{}{userID}},
+	}
+	for _, q := range queries {
+		if err := db.Where(q.query, q.args...).Find(q.dest).Error; err != nil {
+			return nil, fmt.Errorf("collect %T: %w", q.dest, err)
+		}
+	}
+	if err := userRevisionsQuery(db, userID).Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("collect %T: %w", &revisions, err)
+	}
+
+	return map[string]interface{}{
+		"user":                     user,
+		"tags":                     tags,
+		"addresses":                addresses,
+		"moments":                  moments,
+		"moment_likes":             likes,
+		"moment_comments":          comments,
+		"messages":                 messages,
+		"group_messages":           groupMessages,
+		"group_members":            groupMembers,
+		"orders":                   orders,
+		"calls":                    calls,
+		"identities":               identities,
+		"login_events":             loginEvents,
+		"message_deletions":        messageDeletes,
+		"message_revisions":        revisions,
+		"follows":                  follows,
+		"blocks":                   blocks,
+		"mutes":                    mutes,
+		"video_unlocks":            videoUnlocks,
+		"files":                    files,
+		"reports":                  reports,
+		"sanctions":                sanctions,
+		"moderation_records":       moderations,
+		"notifications":            notifications,
+		"notification_preferences": notifyPrefs,
+		"device_tokens":            deviceTokens,
+		"push_settings":            pushSettings,
+		"topic_follows":            topicFollows,
+	}, nil
+}
+
+// 匿名化用户：清空个人资料，删除标签、地址、动态、绑定关系、审核原文等个人数据
+// 订单保留用于对账，聊天记录保留给对方，发送者显示为已注销用户
+// 只属于该用户的文件由调用方在匿名化之后删除，其他用户也上传过的文件去掉上传者
+func AnonymizeUser(userID uint, now time.Time) error {
+	uid := strconv.FormatUint(uint64(userID), 10)
+	placeholder := fmt.Sprintf("deleted_%d", userID)
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"name":         placeholder,
+			"acc_id":       placeholder,
+			"nickname":     "已注销用户",
+			"password":     "",
+			"avatar":       "",
+			"photos":       nil,
+			"videos":       nil,
+			"desc":         "",
+			"gender":       0,
+			"birthday":     "1970-01-01",
+			"height":       0,
+			"weight":       0,
+			"coins":        0,
+			"call_price":   0,
+			"merchant_id":  0,
+			"banned_until": nil,
+			"deleted_at":   now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		var momentIDs []uint
+		if err := tx.Unscoped().Model(&Moment{}).Where("user_id = ?", userID).Pluck("id", &momentIDs).Error; err != nil {
+			return err
+		}
+		deletes := []struct {
+			model interface{}
+			query string
+			args  []interface{}
+		}{
+			{&Tags{}, "user_id = ?", []interface{}{userID}},
+			{&Address{}, "user_id = ?", []interface{}{userID}},
+			{&MomentLike{}, "user_id = ? OR moment_id IN ?", []interface{}{userID, momentIDs}},
+			{&MomentComment{}, "user_id = ? OR moment_id IN ?", []interface{}{userID, momentIDs}},
+			{&MomentTopic{}, "moment_id IN ?", []interface{}{momentIDs}},
+			{&Moment{}, "user_id = ?", []interface{}{userID}},
+			{&GroupMember{}, "user_id = ?", []interface{}{uid}},
+			{&GroupJoinRequest{}, "user_id = ?", []interface{}{uid}},
+			{&UserIdentity{}, "user_id = ?", []interface{}{userID}},
+			{&LoginEvent{}, "user_id = ?", []interface{}{userID}},
+			{&MessageDeletion{}, "user_id = ?", []interface{}{userID}},
+			{&UserFollow{}, "follower_id = ? OR followee_id = ?", []interface{}{userID, userID}},
+			{&UserBlock{}, "user_id = ? OR blocked_id = ?", []interface{}{userID, userID}},
+			{&UserMute{}, "user_id = ? OR muted_id = ?", []interface{}{userID, userID}},
+			{&TopicFollow{}, "user_id = ?", []interface{}{userID}},
+			{&Notification{}, "user_id = ?", []interface{}{userID}},
+			{&NotificationPreference{}, "user_id = ?", []interface{}{userID}},
+			{&DeviceToken{}, "user_id = ?", []interface{}{userID}},
+			{&PushSetting{}, "user_id = ?", []interface{}{userID}},
+			{&FileOwner{}, "user_id = ?", []interface{}{userID}},
+			{&VideoUnlock{}, "user_id = ? OR owner_id = ?", []interface{}{userID, userID}},
+			{&ModerationRecord{}, "user_id = ?", []interface{}{userID}},
+		}
+		// 包括已软删除的记录，个人数据需要真正删除
+		for _, d := range deletes {
+			if err := tx.Unscoped().Where(d.query, d.args...).Delete(d.model).Error; err != nil {
+				return err
+			}
+		}
+		// 修改记录保留给对方看到的操作记录，去掉原文
+		if err := userRevisionsQuery(tx.Model(&MessageRevision{}), userID).Update("content", "").Error; err != nil {
+			return err
+		}
+		// 举报记录保留处理结果，去掉被举报内容的快照
+		if err := tx.Model(&Report{}).Where("target_user_id = ?", userID).Update("evidence", nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(&StoredFile{}).Where("uploader_id = ?", userID).Update("uploader_id", 0).Error
+	})
+}
@@ -54,7 +54,7 @@ func UpdateAddress(id uint, updates map[string]interface{}) error {
 	return db.Model(&Address{}).Where("id=?", id).Updates(updates).Error
 }
 
-func DeleteAddress(AddressId uint) error {
+func DeleteAddress(UserId, AddressId uint) error {
 	db := GetDB()
-	return db.Delete(&Address{}, AddressId).Error
+	return db.Where("user_id = ?", UserId).Delete(&Address{}, AddressId).Error
 }
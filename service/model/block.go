@@ -0,0 +1,90 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserBlock 拉黑关系，被拉黑的用户互相不可见
+type UserBlock struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_blocked" json:"user_id"`
+	BlockedID uint      `gorm:"not null;uniqueIndex:idx_user_blocked;index" json:"blocked_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// UserMute 屏蔽关系，只是不看对方的动态，对方不受影响
+type UserMute struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_muted" json:"user_id"`
+	MutedID   uint      `gorm:"not null;uniqueIndex:idx_user_muted" json:"muted_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 拉黑时同时解除双方的关注关系
+func CreateBlock(userID, blockedID uint) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&UserBlock{UserID: userID, BlockedID: blockedID}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("(follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?)",
+			userID, blockedID, blockedID, userID).Delete(&UserFollow{}).Error
+	})
+}
+
+func DeleteBlock(userID, blockedID uint) error {
+	return GetDB().Where("user_id = ? AND blocked_id = ?", userID, blockedID).Delete(&UserBlock{}).Error
+}
+
+func GetBlocks(userID uint) ([]UserBlock, error) {
+	var blocks []UserBlock
+	err := GetDB().Where("user_id = ?", userID).Order("id desc").Find(&blocks).Error
+	return blocks, err
+}
+
+// 任意一方拉黑了另一方
+func IsBlockedBetween(uid1, uid2 uint) (bool, error) {
+	var count int64
+	err := GetDB().Model(&UserBlock{}).
+		Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", uid1, uid2, uid2, uid1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 与该用户存在拉黑关系的所有用户，包括拉黑对方和被对方拉黑
+func GetBlockRelatedUserIDs(uid uint) ([]uint, error) {
+	var blocked, blockedBy []uint
+	db := GetDB()
+	if err := db.Model(&UserBlock{}).Where("user_id = ?", uid).Pluck("blocked_id", &blocked).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&UserBlock{}).Where("blocked_id = ?", uid).Pluck("user_id", &blockedBy).Error; err != nil {
+		return nil, err
+	}
+	return append(blocked, blockedBy...), nil
+}
+
+func CreateMute(userID, mutedID uint) error {
+	return GetDB().Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UserMute{UserID: userID, MutedID: mutedID}).Error
+}
+
+func DeleteMute(userID, mutedID uint) error {
+	return GetDB().Where("user_id = ? AND muted_id = ?", userID, mutedID).Delete(&UserMute{}).Error
+}
+
+func GetMutes(userID uint) ([]UserMute, error) {
+	var mutes []UserMute
+	err := GetDB().Where("user_id = ?", userID).Order("id desc").Find(&mutes).Error
+	return mutes, err
+}
+
+func GetMutedUserIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := GetDB().Model(&UserMute{}).Where("user_id = ?", userID).Pluck("muted_id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 通话状态
+const (
+	CallStatusRinging   = "ringing"
+	CallStatusAccepted  = "accepted"
+	CallStatusRejected  = "rejected"
+	CallStatusCancelled = "cancelled"
+	CallStatusMissed    = "missed"
+	CallStatusEnded     = "ended"
+)
+
+// 通话类型
+const (
+	CallMediaAudio = "audio"
+	CallMediaVideo = "video"
+)
+
+var ErrCallStateChanged = errors.New("call state has changed")
+
+// CallSession 一对一音视频通话记录
+type CallSession struct {
+	ID         uint       `gorm:"primaryKey" json:"-"`
+	CallID     string     `gorm:"type:varchar(32);uniqueIndex;not null" json:"call_id"`
+	CallerID   uint       `gorm:"not null;index" json:"caller_id"`
+	CalleeID   uint       `gorm:"not null;index" json:"callee_id"`
+	MediaType  string     `gorm:"type:varchar(16)" json:"media_type"`
+	Status     string     `gorm:"type:varchar(16);index" json:"status"`
+	Price      uint       `gorm:"default:0" json:"price"`       // 每分钟金币，0 表示免费
+	MaxSeconds uint       `gorm:"default:0" json:"max_seconds"` // 付费通话接通时按主叫余额计算的最长时长
+	AcceptedAt *time.Time `json:"accepted_at"`
+	EndedAt    *time.Time `json:"ended_at"`
+	Duration   uint       `gorm:"default:0" json:"duration"` // 通话时长，单位秒
+	Coins      uint       `gorm:"default:0" json:"coins"`    // 实际扣费金币
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (c *CallSession) IsActive() bool {
+	return c.Status == CallStatusRinging || c.Status == CallStatusAccepted
+}
+
+func CreateCallSession(call *CallSession) error {
+	return GetDB().Create(call).Error
+}
+
+func GetCallSession(callID string) (*CallSession, error) {
+	var call CallSession
+	if err := GetDB().Where("call_id = ?", callID).First(&call).Error; err != nil {
+		return nil, err
+	}
+	return &call, nil
+}
+
+// 用户是否有进行中的通话
+func HasActiveCall(uid uint) (bool, error) {
+	var count int64
+	err := GetDB().Model(&CallSession{}).
+		Where("(caller_id = ? OR callee_id = ?) AND status IN ?", uid, uid, []string{CallStatusRinging, CallStatusAccepted}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 按状态条件更新，避免并发操作覆盖彼此的结果
+func TransitCallSession(callID, fromStatus string, updates map[string]interface{}) error {
+	result := GetDB().Model(&CallSession{}).Where("call_id = ? AND status = ?", callID, fromStatus).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCallStateChanged
+	}
+	return nil
+}
+
+// 超时未接听的通话
+func GetExpiredRingingCalls(before time.Time) ([]CallSession, error) {
+	var calls []CallSession
+	err := GetDB().Where("status = ? AND created_at < ?", CallStatusRinging, before).Find(&calls).Error
+	return calls, err
+}
+
+// 已接通且超过最长时长的通话：付费通话超过接通时余额可支付的时长，或者超过 maxDuration
+func GetOverdueAcceptedCalls(now time.Time, maxDuration time.Duration) ([]CallSession, error) {
+	var calls []CallSession
+	err := GetDB().Where("status = ?", CallStatusAccepted).
+		Where("accepted_at < ? OR (price > 0 AND DATE_ADD(accepted_at, INTERVAL max_seconds SECOND) < ?)",
+			now.Add(-maxDuration), now).
+		Find(&calls).Error
+	return calls, err
+}
+
+// 结束通话并结算：主叫按分钟向被叫支付金币，余额不足时扣完为止
+func EndCallSession(call *CallSession, endedAt time.Time, duration, cost uint) (uint, error) {
+	var charged uint
+	err := GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&CallSession{}).
+			Where("call_id = ? AND status = ?", call.CallID, CallStatusAccepted).
+			Updates(map[string]interface{}{"status": CallStatusEnded, "ended_at": &endedAt, "duration": duration})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCallStateChanged
+		}
+		if cost == 0 {
+			return nil
+		}
+
+		var caller User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "coins").
+			Where("id = ?", call.CallerID).First(&caller).Error
+		if err != nil {
+			return err
+		}
+		charged = min(cost, caller.Coins)
+		if charged == 0 {
+			return nil
+		}
+		if err := tx.Model(&User{}).Where("id = ?", call.CallerID).
+			Update("coins", gorm.Expr("coins - ?", charged)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("id = ?", call.CalleeID).
+			Update("coins", gorm.Expr("coins + ?", charged)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&CallSession{}).Where("call_id = ?", call.CallID).Update("coins", charged).Error
+	})
+	return charged, err
+}
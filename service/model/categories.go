@@ -7,7 +7,7 @@ type Category struct {
 	Name      string    `gorm:"size:64;not null;unique" json:"name"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt time.Time `gorm:"default:NULL" json:"deleted_at"`
+	DeletedAt time.Time `gorm:"default:NULL" json:"-"` // 隐藏删除时间
 }
 
 func CreateCategory(obj *Category) error {
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"worldCity/config"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -21,14 +22,13 @@ func Retry(do RetryDo, times int) error {
 			return nil
 		}
 	}
-	return errors.New(fmt.Sprintf("retry %d times, still error: ", times, err))
+	return fmt.Errorf("retry %d times, still error: %v", times, err)
 }
 
 func InitDB() {
 	var err error
-	// conf := config.GetConf()
-	dsn := "worldCity:worldCity@tcp(localhost:3306)/world_city?charset=utf8mb4&parseTime=True&loc=Local"
-	db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	conf := config.GetConf()
+	db, err = gorm.Open(mysql.Open(conf.Mysql.DSN), &gorm.Config{})
 	if err != nil {
 		fmt.Println("failed")
 		log.Fatal("MySQL connection failed:", err)
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// 文件处理状态，非图片文件上传后即为 ready
+const (
+	FileStatusPending  = "pending"
+	FileStatusReady    = "ready"
+	FileStatusRejected = "rejected"
+)
+
+// StoredFile 已上传的文件，按内容哈希去重
+type StoredFile struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	Hash         string            `gorm:"size:64;uniqueIndex" json:"hash"` // sha256
+	Key          string            `gorm:"size:255" json:"key"`
+	URL          string            `gorm:"size:512;index" json:"url"`
+	Size         int64             `json:"size"`
+	MimeType     string            `gorm:"size:128" json:"mime_type"`
+	UploaderID   uint              `gorm:"index" json:"uploader_id"`
+	Width        int               `json:"width,omitempty"`
+	Height       int               `json:"height,omitempty"`
+	Status       string            `gorm:"size:16;default:ready;index" json:"status"`
+	RejectReason string            `gorm:"size:255" json:"reject_reason,omitempty"`
+	Variants     map[string]string `gorm:"type:json;serializer:json" json:"variants,omitempty"` // 缩略图最长边 -> 地址
+	CreatedAt    time.Time         `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// FileOwner 上传过文件的用户，相同内容只存一份，但每个上传者都有一条记录
+type FileOwner struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_owner_file" json:"user_id"`
+	FileID    uint      `gorm:"not null;uniqueIndex:idx_owner_file;index" json:"file_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 重复记录不报错
+func AddFileOwner(userID, fileID uint) error {
+	return GetDB().Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&FileOwner{UserID: userID, FileID: fileID}).Error
+}
+
+// 首次上传者或上传过相同内容的用户
+func IsFileOwner(userID uint, file *StoredFile) (bool, error) {
+	if file.UploaderID == userID {
+		return true, nil
+	}
+	var count int64
+	err := GetDB().Model(&FileOwner{}).Where("user_id = ? AND file_id = ?", userID, file.ID).Count(&count).Error
+	return count > 0, err
+}
+
+// 只属于该用户的文件：由该用户首次上传，或首次上传者已注销，且没有其他用户上传过相同内容
+func GetUserOnlyFiles(userID uint) ([]StoredFile, error) {
+	db := GetDB()
+	owned := db.Model(&FileOwner{}).Select("file_id").Where("user_id = ?", userID)
+	others := db.Model(&FileOwner{}).Select("file_id").Where("user_id <> ?", userID)
+	var files []StoredFile
+	err := db.Where("uploader_id = ? OR (uploader_id = 0 AND id IN (?))", userID, owned).
+		Where("id NOT IN (?)", others).Find(&files).Error
+	return files, err
+}
+
+func DeleteStoredFile(id uint) error {
+	return GetDB().Delete(&StoredFile{}, id).Error
+}
+
+// 用户上传过的文件 ID，和 IsFileOwner 的条件一致
+func GetOwnedFileIDs(userID uint, files []StoredFile) (map[uint]bool, error) {
+	owned := make(map[uint]bool, len(files))
+	ids := make([]uint, 0, len(files))
+	for _, f := range files {
+		if f.UploaderID == userID {
+			owned[f.ID] = true
+		} else {
+			ids = append(ids, f.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return owned, nil
+	}
+	var rows []uint
+	err := GetDB().Model(&FileOwner{}).Where("user_id = ? AND file_id IN ?", userID, ids).Pluck("file_id", &rows).Error
+	for _, id := range rows {
+		owned[id] = true
+	}
+	return owned, err
+}
+
+func GetStoredFileByHash(hash string) (*StoredFile, error) {
+	var file StoredFile
+	if err := GetDB().Where("hash = ?", hash).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func GetStoredFileById(id uint) (*StoredFile, error) {
+	var file StoredFile
+	if err := GetDB().First(&file, id).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func GetStoredFilesByURLs(urls []string) ([]StoredFile, error) {
+	var files []StoredFile
+	if len(urls) == 0 {
+		return files, nil
+	}
+	err := GetDB().Where("url IN ?", urls).Find(&files).Error
+	return files, err
+}
+
+// 等待处理的图片，按上传顺序
+func GetPendingStoredFiles(limit int) ([]StoredFile, error) {
+	var files []StoredFile
+	err := GetDB().Where("status = ?", FileStatusPending).Order("id").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+func CreateStoredFile(file *StoredFile) error {
+	return GetDB().Create(file).Error
+}
+
+func UpdateStoredFile(file *StoredFile) error {
+	return GetDB().Model(file).Select("status", "reject_reason", "variants", "width", "height").Updates(file).Error
+}
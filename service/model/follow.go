@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// UserFollow 关注关系
+type UserFollow struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	FollowerID uint      `gorm:"not null;uniqueIndex:idx_follower_followee" json:"follower_id"`
+	FolloweeID uint      `gorm:"not null;uniqueIndex:idx_follower_followee;index" json:"followee_id"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 重复关注不报错
+func CreateFollow(followerID, followeeID uint) error {
+	return GetDB().Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UserFollow{FollowerID: followerID, FolloweeID: followeeID}).Error
+}
+
+func DeleteFollow(followerID, followeeID uint) error {
+	return GetDB().Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&UserFollow{}).Error
+}
+
+func IsFollowing(followerID, followeeID uint) (bool, error) {
+	var count int64
+	err := GetDB().Model(&UserFollow{}).
+		Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Count(&count).Error
+	return count > 0, err
+}
+
+// 关注的所有用户
+func GetFolloweeIDs(followerID uint) ([]uint, error) {
+	var ids []uint
+	err := GetDB().Model(&UserFollow{}).Where("follower_id = ?", followerID).Pluck("followee_id", &ids).Error
+	return ids, err
+}
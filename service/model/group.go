@@ -1,13 +1,60 @@
 package model
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 入群方式
+const (
+	GroupJoinFree       uint = 0 // 申请即可加入
+	GroupJoinApproval   uint = 1 // 需要群主或管理员审批
+	GroupJoinInviteOnly uint = 2 // 只能通过邀请链接加入
+)
+
+const DefaultGroupMaxMembers = 200
+
+var ErrGroupFull = errors.New("group is full")
 
 type Group struct {
-	ID        uint      `gorm:"primary,unique" json:"id"`
-	GroupID   string    `gorm:"uniqueIndex;not null" json:"group_id"`
-	Name      string    `json:"name"`
-	CreatorID string    `json:"creater_id"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt time.Time `gorm:"default:NULL" json:"deleted_at"`
+	ID         uint           `gorm:"primary,unique" json:"id"`
+	GroupID    string         `gorm:"uniqueIndex;not null" json:"group_id"`
+	Name       string         `json:"name"`
+	CreatorID  string         `json:"creater_id"`
+	JoinMode   uint           `gorm:"default:0" json:"join_mode"`
+	MuteAll    bool           `gorm:"default:false" json:"mute_all"`
+	MaxMembers uint           `gorm:"default:200" json:"max_members"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"` // 解散的群
+}
+
+func GetGroupByGroupId(groupID string) (*Group, error) {
+	var group Group
+	if err := GetDB().Where("group_id = ?", groupID).First(&group).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func UpdateGroup(groupID string, updates map[string]interface{}) error {
+	return GetDB().Model(&Group{}).Where("group_id = ?", groupID).Updates(updates).Error
+}
+
+// 解散群，同时移除所有成员、邀请和入群申请
+func DissolveGroup(groupID string) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", groupID).Delete(&GroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", groupID).Delete(&GroupInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", groupID).Delete(&GroupJoinRequest{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("group_id = ?", groupID).Delete(&Group{}).Error
+	})
 }
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GroupInvite 入群邀请链接
+type GroupInvite struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Code      string     `gorm:"type:varchar(32);uniqueIndex;not null" json:"code"`
+	GroupID   string     `gorm:"index" json:"group_id"`
+	CreatorID string     `json:"creator_id"`
+	MaxUses   uint       `gorm:"default:0" json:"max_uses"` // 0 表示不限次数
+	Uses      uint       `gorm:"default:0" json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空表示永久有效
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (i *GroupInvite) Valid() bool {
+	if i.ExpiresAt != nil && i.ExpiresAt.Before(time.Now()) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+func CreateGroupInvite(invite *GroupInvite) error {
+	return GetDB().Create(invite).Error
+}
+
+func GetGroupInviteByCode(code string) (*GroupInvite, error) {
+	var invite GroupInvite
+	if err := GetDB().Where("code = ?", code).First(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// 占用一次邀请链接的使用次数，次数已用完时返回 false
+func ClaimGroupInviteUse(id uint) (bool, error) {
+	res := GetDB().Model(&GroupInvite{}).Where("id = ? AND (max_uses = 0 OR uses < max_uses)", id).
+		Update("uses", gorm.Expr("uses + 1"))
+	return res.RowsAffected == 1, res.Error
+}
+
+// 入群失败时归还占用的次数
+func ReleaseGroupInviteUse(id uint) error {
+	return GetDB().Model(&GroupInvite{}).Where("id = ? AND uses > 0", id).Update("uses", gorm.Expr("uses - 1")).Error
+}
+
+// 入群申请状态
+const (
+	JoinRequestPending  uint = 0
+	JoinRequestApproved uint = 1
+	JoinRequestRejected uint = 2
+)
+
+// GroupJoinRequest 入群申请
+type GroupJoinRequest struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	GroupID   string    `gorm:"index" json:"group_id"`
+	UserID    string    `gorm:"index" json:"user_id"`
+	Message   string    `gorm:"type:varchar(255)" json:"message"`
+	Status    uint      `gorm:"default:0" json:"status"`
+	HandlerID string    `json:"handler_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func CreateGroupJoinRequest(req *GroupJoinRequest) error {
+	return GetDB().Create(req).Error
+}
+
+func GetGroupJoinRequest(id uint) (*GroupJoinRequest, error) {
+	var req GroupJoinRequest
+	if err := GetDB().Where("id = ?", id).First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func GetPendingJoinRequest(groupID, userID string) (*GroupJoinRequest, error) {
+	var req GroupJoinRequest
+	err := GetDB().Where("group_id = ? AND user_id = ? AND status = ?", groupID, userID, JoinRequestPending).
+		First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func GetGroupJoinRequests(groupID string, status uint) ([]GroupJoinRequest, error) {
+	var reqs []GroupJoinRequest
+	err := GetDB().Where("group_id = ? AND status = ?", groupID, status).Order("id desc").Find(&reqs).Error
+	return reqs, err
+}
+
+func UpdateGroupJoinRequest(id uint, updates map[string]interface{}) error {
+	return GetDB().Model(&GroupJoinRequest{}).Where("id = ?", id).Updates(updates).Error
+}
@@ -1,14 +1,97 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 群成员角色
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
 
 type GroupMember struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	GroupID   string    `gorm:"index" json:"group_id"`
-	UserID    string    `gorm:"index" json:"user_id"`
-	Role      string    `gorm:"default:'member'" json:"role"` // member, admin, owner
-	JoinedAt  time.Time `gorm:"autoCreateTime" json:"joined_at"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt time.Time `gorm:"default:NULL" json:"deleted_at"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	GroupID    string     `gorm:"index;uniqueIndex:idx_group_user" json:"group_id"`
+	UserID     string     `gorm:"index;uniqueIndex:idx_group_user" json:"user_id"`
+	Role       string     `gorm:"default:'member'" json:"role"` // member, admin, owner
+	MutedUntil *time.Time `json:"muted_until"`                  // 禁言到期时间，为空表示未禁言
+	JoinedAt   time.Time  `gorm:"autoCreateTime" json:"joined_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt  time.Time  `gorm:"default:NULL" json:"deleted_at"`
+}
+
+func (m *GroupMember) IsMuted() bool {
+	return m.MutedUntil != nil && m.MutedUntil.After(time.Now())
+}
+
+func GetGroupMember(groupID, userID string) (*GroupMember, error) {
+	var member GroupMember
+	if err := GetDB().Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func GetGroupMembers(groupID string) ([]GroupMember, error) {
+	var members []GroupMember
+	err := GetDB().Where("group_id = ?", groupID).Order("id asc").Find(&members).Error
+	return members, err
+}
+
+func CountGroupMembers(groupID string) (int64, error) {
+	var count int64
+	err := GetDB().Model(&GroupMember{}).Where("group_id = ?", groupID).Count(&count).Error
+	return count, err
+}
+
+// 添加成员，在事务中锁住群记录后再检查人数上限
+func AddGroupMember(group *Group, member *GroupMember) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", group.ID).First(&Group{}).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&GroupMember{}).Where("group_id = ?", group.GroupID).Count(&count).Error; err != nil {
+			return err
+		}
+		if group.MaxMembers > 0 && uint(count) >= group.MaxMembers {
+			return ErrGroupFull
+		}
+		return tx.Create(member).Error
+	})
+}
+
+func RemoveGroupMember(groupID, userID string) error {
+	return GetDB().Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&GroupMember{}).Error
+}
+
+func UpdateGroupMember(groupID, userID string, updates map[string]interface{}) error {
+	return GetDB().Model(&GroupMember{}).Where("group_id = ? AND user_id = ?", groupID, userID).Updates(updates).Error
+}
+
+// 转让群主：原群主降为管理员
+func TransferGroupOwner(groupID, fromUserID, toUserID string) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&GroupMember{}).Where("group_id = ? AND user_id = ?", groupID, fromUserID).
+			Update("role", GroupRoleAdmin).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&GroupMember{}).Where("group_id = ? AND user_id = ?", groupID, toUserID).
+			Update("role", GroupRoleOwner).Error
+	})
+}
+
+// 用户作为群主的群
+func GetOwnedGroupIDs(userID string) ([]string, error) {
+	var groupIDs []string
+	err := GetDB().Model(&GroupMember{}).Where("user_id = ? AND role = ?", userID, GroupRoleOwner).
+		Pluck("group_id", &groupIDs).Error
+	return groupIDs, err
 }
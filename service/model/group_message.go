@@ -3,12 +3,37 @@ package model
 import "time"
 
 type GroupMessage struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	GroupID   string    `gorm:"index" json:"group_id"`
-	SenderID  string    `json:"sender_id"`
-	Content   string    `json:"content"`
-	Type      string    `json:"type"` // text, image, audio, video
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt time.Time `gorm:"default:NULL" json:"deleted_at"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	GroupID    string     `gorm:"index" json:"group_id"`
+	SenderID   string     `json:"sender_id"`
+	Content    string     `gorm:"type:text" json:"content"` // MessagePayload 的 JSON 编码
+	Type       MsgType    `gorm:"type:varchar(32)" json:"type"`
+	Mentions   []uint     `gorm:"type:json;serializer:json" json:"mentions"` // 被@的用户
+	MentionAll bool       `gorm:"default:false" json:"mention_all"`          // @所有人
+	Recalled   bool       `gorm:"default:false" json:"recalled"`
+	EditedAt   *time.Time `json:"edited_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt  time.Time  `gorm:"default:NULL" json:"deleted_at"`
+}
+
+func CreateGroupMessage(msg *GroupMessage) error {
+	return GetDB().Create(msg).Error
+}
+
+func GetGroupMessageById(id uint) (*GroupMessage, error) {
+	var msg GroupMessage
+	if err := GetDB().Where("id = ?", id).First(&msg).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// 查询群历史消息，不包含 userID 自己删除的消息
+func FetchGroupMessages(groupID string, userID uint, limit int) ([]GroupMessage, error) {
+	var messages []GroupMessage
+	query := GetDB().Where("group_id = ?", groupID)
+	query = excludeDeletedFor(query, userID, MsgScopeGroup)
+	err := query.Order("created_at desc").Limit(limit).Find(&messages).Error
+	return messages, err
 }
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentity 第三方账号与本地用户的绑定关系，一个用户可以绑定多个第三方账号
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Provider  string    `gorm:"size:32;uniqueIndex:idx_provider_subject" json:"provider"`
+	Subject   string    `gorm:"size:128;uniqueIndex:idx_provider_subject" json:"-"` // 第三方的用户唯一标识
+	Email     string    `gorm:"size:128" json:"email"`
+	Phone     string    `gorm:"size:32" json:"phone"`
+	Nickname  string    `gorm:"size:64" json:"nickname"`
+	Avatar    string    `json:"avatar"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func GetIdentity(provider, subject string) (*UserIdentity, error) {
+	var identity UserIdentity
+	err := GetDB().Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func GetUserIdentities(userID uint) ([]UserIdentity, error) {
+	var identities []UserIdentity
+	err := GetDB().Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+func CreateIdentity(identity *UserIdentity) error {
+	return GetDB().Create(identity).Error
+}
+
+func UpdateIdentity(id uint, updates map[string]interface{}) error {
+	return GetDB().Model(&UserIdentity{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func DeleteIdentity(userID uint, provider string) error {
+	return GetDB().Where("user_id = ? AND provider = ?", userID, provider).Delete(&UserIdentity{}).Error
+}
+
+// 第三方登录首次创建用户，用户和绑定关系在同一事务中写入，db 为外层事务时使用保存点
+func CreateUserWithIdentity(db *gorm.DB, user *User, identity *UserIdentity) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// 登录方式
+const (
+	LoginMethodPassword = "password"
+	LoginMethodSms      = "sms"
+	LoginMethodOAuth    = "oauth"
+)
+
+// 登录结果
+const (
+	LoginResultSuccess = "success"
+	LoginResultFailed  = "failed"
+	LoginResultLocked  = "locked"
+)
+
+// LoginEvent 登录审计记录
+type LoginEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"` // 账号不存在时为 0
+	Account   string    `gorm:"size:64;index" json:"account"`
+	Method    string    `gorm:"size:16" json:"method"`
+	Result    string    `gorm:"size:16" json:"result"`
+	Reason    string    `gorm:"size:255" json:"reason"`
+	IP        string    `gorm:"size:64" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+func CreateLoginEvent(event *LoginEvent) error {
+	return GetDB().Create(event).Error
+}
@@ -1,27 +1,54 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Message struct {
-	ID          uint      `gorm:"primary,unique" json:"id"`
-	SessionID   string    `json:"session_id"`
-	SenderID    uint      `json:"sender_id"`
-	ReceiverID  uint      `json:"receiver_id,omitempty"`
-	GroupID     uint      `json:"group_id,omitempty"`
-	ContentType string    `json:"content_type"`
-	Content     string    `json:"content"`
-	Timestamp   uint      `json:"timestamp"`
-	IsRead      bool      `json:"is_read"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt   time.Time `gorm:"default:NULL" json:"deleted_at"`
+	ID          uint       `gorm:"primary,unique" json:"id"`
+	SessionID   string     `gorm:"index" json:"session_id"`
+	SenderID    uint       `json:"sender_id"`
+	ReceiverID  uint       `json:"receiver_id,omitempty"`
+	GroupID     uint       `json:"group_id,omitempty"`
+	ContentType MsgType    `gorm:"type:varchar(32)" json:"content_type"`
+	Content     string     `gorm:"type:text" json:"content"` // MessagePayload 的 JSON 编码
+	Timestamp   uint       `json:"timestamp"`
+	IsRead      bool       `json:"is_read"`
+	Recalled    bool       `gorm:"default:false" json:"recalled"`
+	EditedAt    *time.Time `json:"edited_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt   time.Time  `gorm:"default:NULL" json:"deleted_at"`
 }
 
-func FetchMessages(sessionID string, beforeID int64, limit int) ([]Message, error) {
+// 私聊会话ID，与双方的先后顺序无关
+func BuildSessionID(uid1, uid2 uint) string {
+	if uid1 > uid2 {
+		uid1, uid2 = uid2, uid1
+	}
+	return fmt.Sprintf("p2p:%d:%d", uid1, uid2)
+}
+
+func CreateMessage(msg *Message) error {
+	return GetDB().Create(msg).Error
+}
+
+func GetMessageById(id uint) (*Message, error) {
+	var msg Message
+	if err := GetDB().Where("id = ?", id).First(&msg).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// 查询会话历史消息，不包含 userID 自己删除的消息
+func FetchMessages(sessionID string, userID uint, beforeID int64, limit int) ([]Message, error) {
 	db := GetDB()
 	var messages []Message
 
 	query := db.Where("session_id = ?", sessionID)
+	query = excludeDeletedFor(query, userID, MsgScopeP2P)
 
 	if beforeID > 0 {
 		query = query.Where("id < ?", beforeID)
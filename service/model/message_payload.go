@@ -0,0 +1,273 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MsgType 消息内容类型
+type MsgType string
+
+const (
+	MsgTypeText     MsgType = "text"
+	MsgTypeImage    MsgType = "image"
+	MsgTypeAudio    MsgType = "audio"
+	MsgTypeVideo    MsgType = "video"
+	MsgTypeLocation MsgType = "location"
+	MsgTypeProduct  MsgType = "product" // 商品分享卡片
+	MsgTypeOrder    MsgType = "order"   // 订单分享卡片
+	MsgTypeGift     MsgType = "gift"
+	MsgTypeCall     MsgType = "call" // 通话记录，由服务端生成
+)
+
+// ServerGenerated 由服务端生成的消息，用户不能撤回或编辑
+func (t MsgType) ServerGenerated() bool {
+	return t == MsgTypeCall
+}
+
+const (
+	MaxTextLength    = 5000
+	MaxAudioDuration = 60 * 1000     // 语音最长60秒，单位毫秒
+	MaxVideoDuration = 5 * 60 * 1000 // 视频最长5分钟，单位毫秒
+	MaxMediaSize     = 100 << 20     // 单个媒体文件最大100MB
+	MaxGiftCountOnce = 9999
+)
+
+// MessagePayload 消息内容，Message.Content 中保存的是它的 JSON 编码
+type MessagePayload interface {
+	Type() MsgType
+	Validate() error
+}
+
+type TextPayload struct {
+	Text string `json:"text"`
+}
+
+type ImagePayload struct {
+	URL    string `json:"url"`
+	Width  uint   `json:"width"`
+	Height uint   `json:"height"`
+	Size   uint64 `json:"size"`
+	Ext    string `json:"ext"`
+	Md5    string `json:"md5,omitempty"`
+}
+
+type AudioPayload struct {
+	URL      string `json:"url"`
+	Duration uint   `json:"duration"` // 毫秒
+	Size     uint64 `json:"size"`
+	Ext      string `json:"ext"`
+	Md5      string `json:"md5,omitempty"`
+}
+
+type VideoPayload struct {
+	URL      string `json:"url"`
+	Cover    string `json:"cover"`
+	Duration uint   `json:"duration"` // 毫秒
+	Width    uint   `json:"width"`
+	Height   uint   `json:"height"`
+	Size     uint64 `json:"size"`
+	Ext      string `json:"ext"`
+	Md5      string `json:"md5,omitempty"`
+}
+
+type LocationPayload struct {
+	Title string  `json:"title"`
+	Lat   float64 `json:"lat"`
+	Lng   float64 `json:"lng"`
+}
+
+// ProductCardPayload 商品分享卡片
+type ProductCardPayload struct {
+	ProductID uint    `json:"product_id"`
+	Title     string  `json:"title"`
+	Image     string  `json:"image"`
+	Price     float64 `json:"price"`
+}
+
+// OrderCardPayload 订单分享卡片
+type OrderCardPayload struct {
+	OrderNo     string      `json:"order_no"`
+	Title       string      `json:"title"`
+	Image       string      `json:"image"`
+	TotalAmount string      `json:"total_amount"`
+	Status      OrderStatus `json:"status"`
+}
+
+type GiftPayload struct {
+	GiftID uint   `json:"gift_id"`
+	Name   string `json:"name"`
+	Icon   string `json:"icon"`
+	Count  uint   `json:"count"`
+	Coins  uint   `json:"coins"` // 单价
+}
+
+// CallPayload 通话结束后写入聊天记录
+type CallPayload struct {
+	CallID    string `json:"call_id"`
+	MediaType string `json:"media_type"`
+	Status    string `json:"status"`
+	Duration  uint   `json:"duration"` // 秒
+	Coins     uint   `json:"coins"`
+}
+
+func (p *TextPayload) Type() MsgType        { return MsgTypeText }
+func (p *ImagePayload) Type() MsgType       { return MsgTypeImage }
+func (p *AudioPayload) Type() MsgType       { return MsgTypeAudio }
+func (p *VideoPayload) Type() MsgType       { return MsgTypeVideo }
+func (p *LocationPayload) Type() MsgType    { return MsgTypeLocation }
+func (p *ProductCardPayload) Type() MsgType { return MsgTypeProduct }
+func (p *OrderCardPayload) Type() MsgType   { return MsgTypeOrder }
+func (p *GiftPayload) Type() MsgType        { return MsgTypeGift }
+func (p *CallPayload) Type() MsgType        { return MsgTypeCall }
+
+func (p *TextPayload) Validate() error {
+	text := strings.TrimSpace(p.Text)
+	if text == "" {
+		return errors.New("text is empty")
+	}
+	if len([]rune(text)) > MaxTextLength {
+		return fmt.Errorf("text is longer than %d characters", MaxTextLength)
+	}
+	return nil
+}
+
+func (p *ImagePayload) Validate() error {
+	if err := validateMediaURL(p.URL); err != nil {
+		return err
+	}
+	if p.Size > MaxMediaSize {
+		return errors.New("image is too large")
+	}
+	return nil
+}
+
+func (p *AudioPayload) Validate() error {
+	if err := validateMediaURL(p.URL); err != nil {
+		return err
+	}
+	if p.Duration == 0 {
+		return errors.New("audio duration is required")
+	}
+	if p.Duration > MaxAudioDuration {
+		return errors.New("audio is too long")
+	}
+	if p.Size > MaxMediaSize {
+		return errors.New("audio is too large")
+	}
+	return nil
+}
+
+func (p *VideoPayload) Validate() error {
+	if err := validateMediaURL(p.URL); err != nil {
+		return err
+	}
+	if p.Duration == 0 {
+		return errors.New("video duration is required")
+	}
+	if p.Duration > MaxVideoDuration {
+		return errors.New("video is too long")
+	}
+	if p.Size > MaxMediaSize {
+		return errors.New("video is too large")
+	}
+	return nil
+}
+
+func (p *LocationPayload) Validate() error {
+	if p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 {
+		return errors.New("invalid coordinates")
+	}
+	return nil
+}
+
+func (p *ProductCardPayload) Validate() error {
+	if p.ProductID == 0 {
+		return errors.New("product_id is required")
+	}
+	return nil
+}
+
+func (p *OrderCardPayload) Validate() error {
+	if p.OrderNo == "" {
+		return errors.New("order_no is required")
+	}
+	return nil
+}
+
+func (p *GiftPayload) Validate() error {
+	if p.GiftID == 0 {
+		return errors.New("gift_id is required")
+	}
+	if p.Count == 0 || p.Count > MaxGiftCountOnce {
+		return errors.New("invalid gift count")
+	}
+	return nil
+}
+
+func (p *CallPayload) Validate() error {
+	if p.CallID == "" {
+		return errors.New("call_id is required")
+	}
+	return nil
+}
+
+func validateMediaURL(url string) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return errors.New("invalid media url")
+	}
+	return nil
+}
+
+// NewMessagePayload 根据消息类型创建对应的空 payload
+func NewMessagePayload(t MsgType) (MessagePayload, error) {
+	switch t {
+	case MsgTypeText:
+		return &TextPayload{}, nil
+	case MsgTypeImage:
+		return &ImagePayload{}, nil
+	case MsgTypeAudio:
+		return &AudioPayload{}, nil
+	case MsgTypeVideo:
+		return &VideoPayload{}, nil
+	case MsgTypeLocation:
+		return &LocationPayload{}, nil
+	case MsgTypeProduct:
+		return &ProductCardPayload{}, nil
+	case MsgTypeOrder:
+		return &OrderCardPayload{}, nil
+	case MsgTypeGift:
+		return &GiftPayload{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported message type: %s", t)
+	}
+}
+
+// ParseMessagePayload 解析并校验客户端提交的消息内容
+func ParseMessagePayload(t MsgType, raw []byte) (MessagePayload, error) {
+	payload, err := NewMessagePayload(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("message content is empty")
+	}
+	if err := json.Unmarshal(raw, payload); err != nil {
+		return nil, fmt.Errorf("invalid %s content: %v", t, err)
+	}
+	if err := payload.Validate(); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// EncodeMessagePayload 编码为 JSON，用于保存到 Message.Content
+func EncodeMessagePayload(payload MessagePayload) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
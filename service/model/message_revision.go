@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 消息所属的会话类型
+const (
+	MsgScopeP2P   = "p2p"
+	MsgScopeGroup = "group"
+)
+
+// 消息修改动作
+const (
+	MsgActionEdit     = "edit"
+	MsgActionRecall   = "recall"
+	MsgActionTakedown = "takedown" // 举报处理下架
+)
+
+// MessageRevision 消息的修改记录，保存修改/撤回前的内容
+type MessageRevision struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Scope       string    `gorm:"type:varchar(16);index:idx_scope_message" json:"scope"`
+	MessageID   uint      `gorm:"index:idx_scope_message" json:"message_id"`
+	Action      string    `gorm:"type:varchar(16)" json:"action"`
+	OperatorID  uint      `json:"operator_id"`
+	ContentType MsgType   `gorm:"type:varchar(32)" json:"content_type"`
+	Content     string    `gorm:"type:text" json:"content"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// MessageDeletion 仅对自己删除的消息
+type MessageDeletion struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_scope_message" json:"user_id"`
+	Scope     string    `gorm:"type:varchar(16);uniqueIndex:idx_user_scope_message" json:"scope"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_user_scope_message" json:"message_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 保存修改记录并更新消息，target 为 &Message{} 或 &GroupMessage{}
+func ReviseMessage(target interface{}, revision *MessageRevision, updates map[string]interface{}) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(revision).Error; err != nil {
+			return err
+		}
+		return tx.Model(target).Where("id = ?", revision.MessageID).Updates(updates).Error
+	})
+}
+
+func GetMessageRevisions(scope string, messageID uint) ([]MessageRevision, error) {
+	var revisions []MessageRevision
+	err := GetDB().Where("scope = ? AND message_id = ?", scope, messageID).Order("id asc").Find(&revisions).Error
+	return revisions, err
+}
+
+func CreateMessageDeletion(userID uint, scope string, messageID uint) error {
+	deletion := &MessageDeletion{UserID: userID, Scope: scope, MessageID: messageID}
+	return GetDB().Where(deletion).FirstOrCreate(deletion).Error
+}
+
+// 排除用户自己删除的消息
+func excludeDeletedFor(query *gorm.DB, userID uint, scope string) *gorm.DB {
+	sub := GetDB().Model(&MessageDeletion{}).Select("message_id").Where("user_id = ? AND scope = ?", userID, scope)
+	return query.Where("id NOT IN (?)", sub)
+}
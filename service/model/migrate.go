@@ -17,6 +17,19 @@ func Migrate(db *gorm.DB) error {
 		&Order{},
 		&Address{},
 		&Moment{}, &MomentLike{}, &MomentComment{},
+		&Message{}, &GroupMessage{}, &MessageRevision{}, &MessageDeletion{},
+		&Group{}, &GroupMember{}, &GroupInvite{}, &GroupJoinRequest{},
+		&CallSession{},
+		&LoginEvent{}, &UserIdentity{},
+		&DataExport{}, &AccountDeletion{},
+		&StoredFile{}, &FileOwner{},
+		&UserFollow{}, &VideoUnlock{}, &UserBlock{}, &UserMute{},
+		&Report{}, &UserSanction{},
+		&ModerationRecord{},
+		&Notification{}, &NotificationPreference{},
+		&DeviceToken{}, &PushSetting{},
+		&OutboxEvent{},
+		&Topic{}, &MomentTopic{}, &TopicFollow{},
 	)
 	if err != nil {
 		log.Printf("❌ AutoMigrate failed: %v", err)
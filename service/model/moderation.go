@@ -0,0 +1,125 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审核场景
+const (
+	ModerationSceneMoment   = "moment"
+	ModerationSceneComment  = "comment"
+	ModerationSceneMessage  = "message"
+	ModerationSceneTag      = "tag"
+	ModerationSceneNickname = "nickname"
+	ModerationSceneDesc     = "desc"
+)
+
+// 审核结论，按严重程度递增
+const (
+	ModerationPass   = "pass"
+	ModerationMask   = "mask"   // 敏感词替换为 * 后发布
+	ModerationReview = "review" // 先隐藏，人工审核通过后展示
+	ModerationReject = "reject"
+)
+
+// 动态和评论的人工审核状态，待审核和未通过的内容只有作者自己可见
+const (
+	ReviewStatusApproved = "approved"
+	ReviewStatusPending  = "pending"
+	ReviewStatusRejected = "rejected"
+)
+
+// ModerationRecord 文本审核记录，每次审核都会写入
+type ModerationRecord struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	Scene        string     `gorm:"size:16;index" json:"scene"`
+	TargetID     uint       `gorm:"index" json:"target_id"` // 审核后保存的动态或评论 ID
+	Checker      string     `gorm:"size:32" json:"checker"`
+	Verdict      string     `gorm:"size:16;index" json:"verdict"`
+	Hits         []string   `gorm:"type:json;serializer:json" json:"hits"`
+	Content      string     `gorm:"type:text" json:"content"` // 原文
+	Result       string     `gorm:"type:text" json:"result"`  // 替换敏感词后的内容
+	ReviewStatus string     `gorm:"size:16;index" json:"review_status,omitempty"`
+	ReviewerID   uint       `json:"reviewer_id,omitempty"`
+	ReviewNote   string     `gorm:"size:1000" json:"review_note,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func CreateModerationRecord(record *ModerationRecord) error {
+	return GetDB().Create(record).Error
+}
+
+func SetModerationTarget(recordID, targetID uint) error {
+	return GetDB().Model(&ModerationRecord{}).Where("id = ?", recordID).Update("target_id", targetID).Error
+}
+
+func GetModerationRecordById(id uint) (*ModerationRecord, error) {
+	var record ModerationRecord
+	if err := GetDB().First(&record, id).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ModerationFilter 审核记录查询条件，空值表示不限制
+type ModerationFilter struct {
+	Scene        string
+	Verdict      string
+	ReviewStatus string
+	UserID       uint
+	Offset       int
+	Limit        int
+}
+
+func GetModerationRecords(f ModerationFilter) ([]ModerationRecord, int64, error) {
+	query := GetDB().Model(&ModerationRecord{})
+	if f.Scene != "" {
+		query = query.Where("scene = ?", f.Scene)
+	}
+	if f.Verdict != "" {
+		query = query.Where("verdict = ?", f.Verdict)
+	}
+	if f.ReviewStatus != "" {
+		query = query.Where("review_status = ?", f.ReviewStatus)
+	}
+	if f.UserID != 0 {
+		query = query.Where("user_id = ?", f.UserID)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []ModerationRecord
+	err := query.Order("id desc").Offset(f.Offset).Limit(f.Limit).Find(&records).Error
+	return records, total, err
+}
+
+// 人工审核结果同时写回动态或评论
+func ReviewModerationRecord(record *ModerationRecord, status string, reviewerID uint, note string) error {
+	now := time.Now()
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&ModerationRecord{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"review_status": status,
+			"reviewer_id":   reviewerID,
+			"review_note":   note,
+			"reviewed_at":   &now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		var target interface{}
+		switch record.Scene {
+		case ModerationSceneMoment:
+			target = &Moment{}
+		case ModerationSceneComment:
+			target = &MomentComment{}
+		default:
+			return nil
+		}
+		return tx.Model(target).Where("id = ?", record.TargetID).Update("review_status", status).Error
+	})
+}
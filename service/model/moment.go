@@ -8,39 +8,55 @@ import (
 	"gorm.io/gorm"
 )
 
+// Moment 动态，文字、图片和视频至少有一项，图片和视频不能同时发布
+// 动态的可见范围，作者自己始终可见
+const (
+	MomentVisibilityPublic    uint = 0 // 所有人可见
+	MomentVisibilityFollowers uint = 1 // 关注了作者的用户可见
+	MomentVisibilityPrivate   uint = 2 // 仅作者可见
+)
+
 type Moment struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	UserID     uint      `gorm:"not null;index" json:"user_id"` // 外键字段
-	Content    string    `gorm:"type:text;not null" json:"content"`
-	Images     []string  `gorm:"type:json;serializer:json" json:"images"` // 推荐使用 GORM serializer
-	Location   string    `gorm:"type:varchar(255)" json:"location"`
-	Visibility uint      `gorm:"default:0" json:"visibility"` // 0:public 1:followers 2:private
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt  time.Time `gorm:"default:NULL" json:"deleted_at"`
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	UserID         uint           `gorm:"not null;index" json:"user_id"` // 外键字段
+	Content        string         `gorm:"type:text;not null" json:"content"`
+	Images         []string       `gorm:"type:json;serializer:json" json:"images"` // 推荐使用 GORM serializer
+	Video          string         `gorm:"size:512" json:"video,omitempty"`
+	Location       string         `gorm:"type:varchar(255)" json:"location"`
+	Visibility     uint           `gorm:"default:0" json:"visibility"` // 见 MomentVisibilityPublic 等
+	ReviewStatus   string         `gorm:"size:16;default:approved;index" json:"review_status"`
+	EditedAt       *time.Time     `json:"edited_at,omitempty"`
+	TakedownBy     uint           `json:"-"`                                         // 下架的运营，0 表示作者自己删除
+	TakedownReason string         `gorm:"size:255" json:"takedown_reason,omitempty"` // 下架原因，保留用于申诉
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type MomentLike struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null;index:idx_user_moment,unique" json:"user_id"`
-	MomentID  uint      `gorm:"not null;index:idx_user_moment,unique" json:"moment_id"`
-	Status    uint      `gorm:"default:0" json:"status"` // 1: liked, 0: canceled
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt time.Time `gorm:"default:NULL" json:"deleted_at"`
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	UserID    uint           `gorm:"not null;index:idx_user_moment,unique" json:"user_id"`
+	MomentID  uint           `gorm:"not null;index:idx_user_moment,unique" json:"moment_id"`
+	Status    uint           `gorm:"default:0" json:"status"` // 1: liked, 0: canceled
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type MomentComment struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	MomentID  uint      `gorm:"not null;index" json:"moment_id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	Content   string    `gorm:"type:text;not null" json:"content"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt time.Time `gorm:"default:NULL" json:"deleted_at"`
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	MomentID     uint           `gorm:"not null;index" json:"moment_id"`
+	UserID       uint           `gorm:"not null;index" json:"user_id"`
+	Content      string         `gorm:"type:text;not null" json:"content"`
+	ReviewStatus string         `gorm:"size:16;default:approved;index" json:"review_status"`
+	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
-func GetMoments(UserId uint) ([]Moment, error) {
+// 获取动态，UserId 为 0 时不限制发布者，excludeUserIds 中用户的动态不返回
+// 未通过审核的动态只有发布者自己（viewerId）可见
+func GetMoments(UserId, viewerId uint, excludeUserIds []uint) ([]Moment, error) {
 	var moments []Moment
 	db := GetDB()
 	sqlStr := ""
@@ -49,13 +65,41 @@ func GetMoments(UserId uint) ([]Moment, error) {
 	} else {
 		sqlStr = "1=1"
 	}
-	err := db.Model(&Moment{}).Where(sqlStr).Order("created_at desc").Find(&moments).Error
+	query := visibleMoments(db.Model(&Moment{}).Where(sqlStr), viewerId)
+	if len(excludeUserIds) > 0 {
+		query = query.Where("user_id NOT IN ?", excludeUserIds)
+	}
+	err := query.Order("created_at desc").Find(&moments).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
 	return moments, nil
 }
 
+// 查看者可见的动态：自己发布的，以及通过审核且公开或关注了作者的
+func visibleMoments(query *gorm.DB, viewerID uint) *gorm.DB {
+	followees := GetDB().Model(&UserFollow{}).Select("followee_id").Where("follower_id = ?", viewerID)
+	return query.Where("user_id = ? OR (review_status = ? AND (visibility = ? OR (visibility = ? AND user_id IN (?))))",
+		viewerID, ReviewStatusApproved, MomentVisibilityPublic, MomentVisibilityFollowers, followees)
+}
+
+// 和 visibleMoments 的条件一致，用于单条动态
+func CanViewMoment(viewerID uint, m *Moment) (bool, error) {
+	if m.UserID == viewerID {
+		return true, nil
+	}
+	if m.ReviewStatus != ReviewStatusApproved {
+		return false, nil
+	}
+	switch m.Visibility {
+	case MomentVisibilityPublic:
+		return true, nil
+	case MomentVisibilityFollowers:
+		return IsFollowing(viewerID, m.UserID)
+	}
+	return false, nil
+}
+
 func PostMoment(moment *Moment) (*Moment, error) {
 	db := GetDB()
 	err := db.Create(moment).Error
@@ -65,31 +109,30 @@ func PostMoment(moment *Moment) (*Moment, error) {
 	return moment, nil
 }
 
-func LikeMoment(UserId, MomentId uint, status bool) (*MomentLike, error) {
-	var like MomentLike
-
-	db := GetDB()
-	err := db.Model(&MomentLike{}).
+// db 可以是事务，点赞和领域事件一起提交
+// first 表示该用户第一次点赞这条动态，取消后再次点赞不算
+func LikeMoment(db *gorm.DB, UserId, MomentId uint, status bool) (like *MomentLike, first bool, err error) {
+	like = &MomentLike{}
+	err = db.Model(&MomentLike{}).
 		Unscoped(). // 包括软删除记录
 		Where("user_id = ? AND moment_id = ?", UserId, MomentId).
-		First(&like).Error
+		First(like).Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// 第一次点赞，插入记录
-			newLike := MomentLike{
-				UserID:   UserId,
-				MomentID: MomentId,
-				Status:   1,
+			// 没有点赞过时取消不写入记录
+			newLike := &MomentLike{UserID: UserId, MomentID: MomentId}
+			if !status {
+				return newLike, false, nil
 			}
-			err = db.Create(&newLike).Error
-			if err != nil {
-				return nil, err
-			} else {
-				return &newLike, nil
+			// 第一次点赞，插入记录
+			newLike.Status = 1
+			if err := db.Create(newLike).Error; err != nil {
+				return nil, false, err
 			}
+			return newLike, true, nil
 		}
-		return nil, err
+		return nil, false, err
 	}
 
 	newStatus := uint(0)
@@ -97,23 +140,23 @@ func LikeMoment(UserId, MomentId uint, status bool) (*MomentLike, error) {
 		newStatus = 1
 	}
 
-	err = db.Model(&like).Updates(map[string]interface{}{
+	err = db.Model(like).Updates(map[string]interface{}{
 		"status":     newStatus,
 		"deleted_at": gorm.DeletedAt{}, // 清除软删除
 	}).Error
 	if err != nil {
-		return nil, err
-	} else {
-		return &like, nil
+		return nil, false, err
 	}
+	return like, false, nil
 }
 
-func CommentMoment(UserId, MomentId uint, content string) (*MomentComment, error) {
+func CommentMoment(UserId, MomentId uint, content, reviewStatus string) (*MomentComment, error) {
 	db := GetDB()
 	comment := &MomentComment{
-		UserID:   UserId,
-		MomentID: MomentId,
-		Content:  content,
+		UserID:       UserId,
+		MomentID:     MomentId,
+		Content:      content,
+		ReviewStatus: reviewStatus,
 	}
 	err := db.Model(&MomentComment{}).Create(comment).Error
 	if err != nil {
@@ -122,11 +165,16 @@ func CommentMoment(UserId, MomentId uint, content string) (*MomentComment, error
 	return comment, nil
 }
 
-func GetMomentComments(MomentId uint) ([]MomentComment, error) {
+func GetMomentComments(MomentId, viewerId uint, excludeUserIds []uint) ([]MomentComment, error) {
 	db := GetDB()
 	var comments []MomentComment
 	sql := fmt.Sprintf("moment_id=%d", MomentId)
-	err := db.Model(&MomentComment{}).Where(sql).Limit(100).Order("created_at desc").Find(&comments).Error
+	query := db.Model(&MomentComment{}).Where(sql).
+		Where("review_status = ? OR user_id = ?", ReviewStatusApproved, viewerId)
+	if len(excludeUserIds) > 0 {
+		query = query.Where("user_id NOT IN ?", excludeUserIds)
+	}
+	err := query.Limit(100).Order("created_at desc").Find(&comments).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
@@ -157,3 +205,55 @@ func IsLiked(UserId, MomentId uint) (bool, error) {
 		return true, nil
 	}
 }
+
+// 获取未删除的动态
+func GetMomentById(id uint) (*Moment, error) {
+	var moment Moment
+	if err := GetDB().Where("id = ?", id).First(&moment).Error; err != nil {
+		return nil, err
+	}
+	return &moment, nil
+}
+
+func GetMomentCommentById(id uint) (*MomentComment, error) {
+	var comment MomentComment
+	if err := GetDB().Where("id = ?", id).First(&comment).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func UpdateMoment(id uint, updates map[string]interface{}) error {
+	return GetDB().Model(&Moment{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// 作者删除动态，点赞和评论一起删除
+func DeleteMoment(id uint) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("moment_id = ?", id).Delete(&MomentLike{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("moment_id = ?", id).Delete(&MomentComment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Moment{}, id).Error
+	})
+}
+
+// 下架动态，只软删除动态本身，保留内容、点赞和评论用于申诉
+func TakedownMoment(id, operatorID uint, reason string) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Moment{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"takedown_by":     operatorID,
+			"takedown_reason": reason,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&Moment{}, id).Error
+	})
+}
+
+func TakedownMomentComment(id uint) error {
+	return GetDB().Delete(&MomentComment{}, id).Error
+}
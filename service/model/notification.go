@@ -0,0 +1,162 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 通知类型，用户可以按类型关闭
+const (
+	NotifyTypeLike    = "like"
+	NotifyTypeComment = "comment"
+	NotifyTypeFollow  = "follow"
+	NotifyTypeOrder   = "order"
+	NotifyTypeSystem  = "system" // 系统通知不能关闭
+)
+
+// 通知关联的对象
+const (
+	NotifyTargetMoment = "moment"
+	NotifyTargetUser   = "user"
+	NotifyTargetOrder  = "order"
+)
+
+var NotifyTypes = []string{NotifyTypeLike, NotifyTypeComment, NotifyTypeFollow, NotifyTypeOrder, NotifyTypeSystem}
+
+// Notification 站内通知，同一动态的点赞在未读期间合并为一条
+type Notification struct {
+	ID         uint                   `gorm:"primaryKey" json:"id"`
+	UserID     uint                   `gorm:"not null;index:idx_notify_user_read" json:"user_id"` // 接收者
+	Type       string                 `gorm:"size:16;not null" json:"type"`
+	ActorID    uint                   `json:"actor_id"`    // 最近一次触发的用户，系统通知为 0
+	ActorCount uint                   `json:"actor_count"` // 合并的通知中触发的用户数
+	TargetType string                 `gorm:"size:16" json:"target_type"`
+	TargetID   uint                   `json:"target_id"`
+	GroupKey   string                 `gorm:"size:64;index" json:"-"`       // 合并的依据，为空时不合并
+	EventKey   *string                `gorm:"size:64;uniqueIndex" json:"-"` // 由事件创建的通知记录事件和处理器，重复处理时不再创建
+	Content    string                 `gorm:"size:1000" json:"content"`
+	Data       map[string]interface{} `gorm:"type:json;serializer:json" json:"data,omitempty"`
+	IsRead     bool                   `gorm:"default:false;index:idx_notify_user_read" json:"is_read"`
+	ReadAt     *time.Time             `json:"read_at,omitempty"`
+	CreatedAt  time.Time              `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time              `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// NotificationPreference 按类型关闭通知，没有记录表示开启
+type NotificationPreference struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_user_notify_type" json:"user_id"`
+	Type    string `gorm:"size:16;not null;uniqueIndex:idx_user_notify_type" json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+// 返回 false 表示相同 EventKey 的通知已经存在
+func CreateNotification(n *Notification) (bool, error) {
+	res := GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(n)
+	return res.RowsAffected == 1, res.Error
+}
+
+// 查找可以合并的未读通知
+func GetUnreadNotificationByGroup(userID uint, groupKey string) (*Notification, error) {
+	var n Notification
+	err := GetDB().Where("user_id = ? AND group_key = ? AND is_read = ?", userID, groupKey, false).
+		Order("id desc").First(&n).Error
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func UpdateNotificationActors(id, actorID, actorCount uint) error {
+	return GetDB().Model(&Notification{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"actor_id":    actorID,
+		"actor_count": actorCount,
+	}).Error
+}
+
+// 通知列表，按最近更新排序，合并的通知有新的点赞时排到前面
+func GetNotifications(userID uint, notifyType string, unreadOnly bool, offset, limit int) ([]Notification, int64, error) {
+	query := GetDB().Model(&Notification{}).Where("user_id = ?", userID)
+	if notifyType != "" {
+		query = query.Where("type = ?", notifyType)
+	}
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []Notification
+	err := query.Order("updated_at desc, id desc").Offset(offset).Limit(limit).Find(&list).Error
+	return list, total, err
+}
+
+// 按类型统计未读数
+func CountUnreadNotifications(userID uint) (map[string]int64, error) {
+	var rows []struct {
+		Type  string
+		Count int64
+	}
+	err := GetDB().Model(&Notification{}).Select("type, COUNT(*) AS count").
+		Where("user_id = ? AND is_read = ?", userID, false).Group("type").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		counts[r.Type] = r.Count
+	}
+	return counts, nil
+}
+
+// 标记已读，ids 为空时按类型标记全部，notifyType 也为空时标记所有通知
+func MarkNotificationsRead(userID uint, ids []uint, notifyType string) (int64, error) {
+	query := GetDB().Model(&Notification{}).Where("user_id = ? AND is_read = ?", userID, false)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	} else if notifyType != "" {
+		query = query.Where("type = ?", notifyType)
+	}
+	// 不更新 updated_at，已读不改变列表顺序
+	res := query.UpdateColumns(map[string]interface{}{"is_read": true, "read_at": time.Now()})
+	return res.RowsAffected, res.Error
+}
+
+func GetNotificationPreferences(userID uint) ([]NotificationPreference, error) {
+	var prefs []NotificationPreference
+	err := GetDB().Where("user_id = ?", userID).Find(&prefs).Error
+	return prefs, err
+}
+
+func IsNotificationEnabled(userID uint, notifyType string) (bool, error) {
+	var pref NotificationPreference
+	err := GetDB().Where("user_id = ? AND type = ?", userID, notifyType).Limit(1).Find(&pref).Error
+	if err != nil {
+		return false, err
+	}
+	return pref.ID == 0 || pref.Enabled, nil
+}
+
+func SaveNotificationPreferences(userID uint, prefs map[string]bool) error {
+	rows := make([]NotificationPreference, 0, len(prefs))
+	for t, enabled := range prefs {
+		rows = append(rows, NotificationPreference{UserID: userID, Type: t, Enabled: enabled})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return GetDB().Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"enabled"})}).
+		Create(&rows).Error
+}
+
+// 从 since 之后点赞动态的其他用户数，用于合并点赞通知
+func CountMomentLikersSince(momentID, excludeUserID uint, since time.Time) (uint, error) {
+	var count int64
+	err := GetDB().Model(&MomentLike{}).
+		Where("moment_id = ? AND status = 1 AND user_id <> ? AND updated_at >= ?", momentID, excludeUserID, since).
+		Count(&count).Error
+	return uint(count), err
+}
@@ -192,9 +192,12 @@ type orderRepository struct {
 	db *gorm.DB
 }
 
-// NewOrderRepository 创建一个新的 orderRepository 实例
+// NewOrderRepository 创建一个新的 orderRepository 实例，db 可以是事务，为 nil 时使用默认连接
 func NewOrderRepository(db *gorm.DB) OrderRepository {
-	return &orderRepository{db: GetDB()}
+	if db == nil {
+		db = GetDB()
+	}
+	return &orderRepository{db: db}
 }
 
 // Create 创建订单
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// outbox 事件状态
+const (
+	OutboxStatusPending    = "pending"    // 等待投递，包括等待重试的
+	OutboxStatusProcessing = "processing" // 已被某个实例认领，正在本地处理
+	OutboxStatusPublished  = "published"  // 已写入 Redis Stream，等待消费
+	OutboxStatusHandling   = "handling"   // 某个消费者正在执行处理器
+	OutboxStatusDispatched = "dispatched" // 所有处理器都已成功
+	OutboxStatusFailed     = "failed"     // 超过重试次数，需要人工处理
+)
+
+// OutboxEvent 领域事件，和业务数据在同一事务中写入，提交后由投递任务送到处理器
+type OutboxEvent struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Type          string         `gorm:"size:64;not null" json:"type"`
+	Payload       datatypes.JSON `json:"payload"`
+	Status        string         `gorm:"size:16;not null;index:idx_outbox_due" json:"status"`
+	NextAttemptAt time.Time      `gorm:"index:idx_outbox_due" json:"next_attempt_at"` // 等待重试的时间，或认领的租约到期时间
+	LeaseOwner    string         `gorm:"size:32" json:"-"`                            // 正在执行处理器的消费者
+	Attempts      int            `json:"attempts"`
+	Done          []string       `gorm:"type:json;serializer:json" json:"done"` // 已成功的处理器，重试时跳过
+	LastError     string         `gorm:"size:1000" json:"last_error,omitempty"`
+	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	DispatchedAt  *time.Time     `json:"dispatched_at,omitempty"`
+}
+
+// 在调用方的事务中写入事件
+func AddOutboxEvents(tx *gorm.DB, events []OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return tx.Create(&events).Error
+}
+
+func GetOutboxEventById(id uint) (*OutboxEvent, error) {
+	var e OutboxEvent
+	if err := GetDB().First(&e, id).Error; err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// 到期的事件：等待投递的，以及认领后租约到期仍未完成的
+func dueOutboxQuery(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("status IN ? AND next_attempt_at <= ?",
+		[]string{OutboxStatusPending, OutboxStatusProcessing, OutboxStatusPublished, OutboxStatusHandling}, now)
+}
+
+// 认领到期的事件，多个实例同时认领时每条只会被一个实例拿到
+func ClaimOutboxEvents(now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error) {
+	var candidates []OutboxEvent
+	err := dueOutboxQuery(GetDB().Model(&OutboxEvent{}), now).Order("id").Limit(limit).Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	claimed := candidates[:0]
+	for _, e := range candidates {
+		res := dueOutboxQuery(GetDB().Model(&OutboxEvent{}), now).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", e.ID, e.Status, e.NextAttemptAt).
+			Updates(map[string]interface{}{
+				"status":          OutboxStatusProcessing,
+				"next_attempt_at": now.Add(lease),
+			})
+		if res.Error != nil {
+			return claimed, res.Error
+		}
+		if res.RowsAffected == 1 {
+			e.Status = OutboxStatusProcessing
+			e.NextAttemptAt = now.Add(lease)
+			claimed = append(claimed, e)
+		}
+	}
+	return claimed, nil
+}
+
+func UpdateOutboxEvent(id uint, updates map[string]interface{}) error {
+	return GetDB().Model(&OutboxEvent{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// 执行处理器前认领事件：已交给传输层的，或其他消费者租约到期的
+// 同一时间只有一个消费者执行处理器，返回 false 表示已被其他消费者认领或已处理完
+func LeaseOutboxEvent(id uint, owner string, now time.Time, lease time.Duration) (bool, error) {
+	res := GetDB().Model(&OutboxEvent{}).
+		Where("id = ? AND (status IN ? OR (status = ? AND next_attempt_at <= ?))", id,
+			[]string{OutboxStatusProcessing, OutboxStatusPublished}, OutboxStatusHandling, now).
+		Updates(map[string]interface{}{
+			"status":          OutboxStatusHandling,
+			"lease_owner":     owner,
+			"next_attempt_at": now.Add(lease),
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// 只有仍持有租约时才更新，租约到期被接手后结果以接手的消费者为准
+func UpdateLeasedOutboxEvent(id uint, owner string, updates map[string]interface{}) (bool, error) {
+	updates["lease_owner"] = ""
+	res := GetDB().Model(&OutboxEvent{}).
+		Where("id = ? AND status = ? AND lease_owner = ?", id, OutboxStatusHandling, owner).Updates(updates)
+	return res.RowsAffected == 1, res.Error
+}
+
+// 清理早于 before 的已投递事件
+func DeleteDispatchedOutboxEvents(before time.Time) (int64, error) {
+	res := GetDB().Where("status = ? AND created_at < ?", OutboxStatusDispatched, before).Delete(&OutboxEvent{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 在线状态：用户最近一段时间内有请求即视为在线
+const OnlineTTL = 5 * time.Minute
+
+func buildOnlineKey(uid uint) string {
+	return fmt.Sprintf("online:%d", uid)
+}
+
+// 按最近活跃时间排序的在线用户集合，用于按在线状态筛选用户
+const onlineUsersKey = "online_users"
+
+// 更新在线状态，顺带清理集合中过期的成员
+func TouchOnline(uid uint) error {
+	now := time.Now()
+	pipe := GetRds().Pipeline()
+	pipe.Set(Ctx, buildOnlineKey(uid), now.Unix(), OnlineTTL)
+	pipe.ZAdd(Ctx, onlineUsersKey, redis.Z{Score: float64(now.Unix()), Member: uid})
+	pipe.ZRemRangeByScore(Ctx, onlineUsersKey, "-inf", fmt.Sprintf("(%d", now.Add(-OnlineTTL).Unix()))
+	_, err := pipe.Exec(Ctx)
+	return err
+}
+
+// 最近活跃的在线用户，最多 limit 个
+func GetOnlineUserIDs(limit int64) ([]uint, error) {
+	since := time.Now().Add(-OnlineTTL).Unix()
+	members, err := GetRds().ZRevRangeByScore(Ctx, onlineUsersKey, &redis.ZRangeBy{
+		Min:   strconv.FormatInt(since, 10),
+		Max:   "+inf",
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]uint, 0, len(members))
+	for _, m := range members {
+		if uid, err := strconv.ParseUint(m, 10, 64); err == nil {
+			uids = append(uids, uint(uid))
+		}
+	}
+	return uids, nil
+}
+
+func IsOnline(uid uint) bool {
+	n, err := GetRds().Exists(Ctx, buildOnlineKey(uid)).Result()
+	return err == nil && n > 0
+}
+
+// 过滤出在线的用户
+func FilterOnline(uids []uint) ([]uint, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	pipe := GetRds().Pipeline()
+	cmds := make([]*redis.IntCmd, len(uids))
+	for i, uid := range uids {
+		cmds[i] = pipe.Exists(Ctx, buildOnlineKey(uid))
+	}
+	if _, err := pipe.Exec(Ctx); err != nil {
+		return nil, err
+	}
+	online := make([]uint, 0, len(uids))
+	for i, cmd := range cmds {
+		if cmd.Val() > 0 {
+			online = append(online, uids[i])
+		}
+	}
+	return online, nil
+}
@@ -15,18 +15,22 @@ type Product struct {
 	Images     []string  `gorm:"type:json;serializer:json" json:"images"`
 	Price      float64   `gorm:"not null;default:0" json:"price"` // 当前价格
 	IsActive   bool      `gorm:"default:true" json:"is_active"`   //当前是否可以提供服务
-	User       User      `json:"user"`
+	User       User      `json:"-"`                               // 通过 service 层转换为公开信息后返回
 	Category   Category  `json:"category"`
 	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt  time.Time `gorm:"default:NULL" json:"deleted_at"`
+	DeletedAt  time.Time `gorm:"default:NULL" json:"-"` // 隐藏删除时间
 }
 
 // 获取该项目下的所有服务者
-func GetProvidersByCategory(CategoryId uint) ([]Product, error) {
+func GetProvidersByCategory(CategoryId uint, excludeUserIds []uint) ([]Product, error) {
 	db := GetDB()
 	var providers []Product
-	err := db.Model(&Product{}).Preload("User").Where("category_id=?", CategoryId).Find(&providers).Error
+	query := db.Model(&Product{}).Preload("User").Where("category_id=?", CategoryId)
+	if len(excludeUserIds) > 0 {
+		query = query.Where("user_id NOT IN ?", excludeUserIds)
+	}
+	err := query.Find(&providers).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return []Product{}, nil
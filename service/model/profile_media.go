@@ -0,0 +1,81 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInsufficientCoins = errors.New("insufficient coins")
+
+// VideoUnlock 付费视频解锁记录
+type VideoUnlock struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_unlock" json:"user_id"`
+	OwnerID   uint      `gorm:"not null;uniqueIndex:idx_unlock" json:"owner_id"`
+	VideoID   string    `gorm:"size:32;not null;uniqueIndex:idx_unlock" json:"video_id"`
+	Coins     uint      `json:"coins"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 在事务中锁住用户记录后修改照片和视频，避免并发修改互相覆盖
+func UpdateUserMedia(userID uint, fn func(user *User) error) (*User, error) {
+	var user User
+	err := GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "photos", "videos").
+			Where("id = ?", userID).First(&user).Error
+		if err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+		// 使用结构体更新，JSON 字段才会经过 serializer
+		return tx.Model(&user).Select("photos", "videos").Updates(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 已解锁的视频 ID
+func GetUnlockedVideoIDs(userID, ownerID uint) ([]string, error) {
+	var ids []string
+	err := GetDB().Model(&VideoUnlock{}).Where("user_id = ? AND owner_id = ?", userID, ownerID).Pluck("video_id", &ids).Error
+	return ids, err
+}
+
+// 扣除观看者金币转给视频所有者并记录解锁，已解锁时不重复扣费
+func UnlockVideo(userID, ownerID uint, videoID string, price uint) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		var viewer User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "coins").
+			Where("id = ?", userID).First(&viewer).Error
+		if err != nil {
+			return err
+		}
+		var count int64
+		err = tx.Model(&VideoUnlock{}).Where("user_id = ? AND owner_id = ? AND video_id = ?", userID, ownerID, videoID).
+			Count(&count).Error
+		if err != nil || count > 0 {
+			return err
+		}
+		if viewer.Coins < price {
+			return ErrInsufficientCoins
+		}
+		if price > 0 {
+			if err := tx.Model(&User{}).Where("id = ?", userID).
+				Update("coins", gorm.Expr("coins - ?", price)).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&User{}).Where("id = ?", ownerID).
+				Update("coins", gorm.Expr("coins + ?", price)).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&VideoUnlock{UserID: userID, OwnerID: ownerID, VideoID: videoID, Coins: price}).Error
+	})
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// 推送设备平台
+const (
+	PushPlatformIOS     = "ios"
+	PushPlatformAndroid = "android"
+	PushPlatformWeb     = "web"
+)
+
+// DeviceToken 推送设备令牌，同一令牌只属于最后登记它的用户
+type DeviceToken struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Platform   string    `gorm:"size:16;not null" json:"platform"`
+	Token      string    `gorm:"size:255;not null;uniqueIndex" json:"token"`
+	DeviceID   string    `gorm:"size:64" json:"device_id"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// PushSetting 推送免打扰时段，QuietStart 和 QuietEnd 为 HH:MM，可以跨过零点
+type PushSetting struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	UserID     uint   `gorm:"not null;uniqueIndex" json:"user_id"`
+	QuietStart string `gorm:"size:5" json:"quiet_start"`
+	QuietEnd   string `gorm:"size:5" json:"quiet_end"`
+	Timezone   string `gorm:"size:64" json:"timezone"` // IANA 时区，为空时使用服务器时区
+}
+
+// 登记设备令牌，令牌已存在时转到当前用户名下
+func SaveDeviceToken(t *DeviceToken) error {
+	t.LastSeenAt = time.Now()
+	return GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "device_id", "last_seen_at"}),
+	}).Create(t).Error
+}
+
+func DeleteDeviceToken(userID uint, token string) (int64, error) {
+	res := GetDB().Where("user_id = ? AND token = ?", userID, token).Delete(&DeviceToken{})
+	return res.RowsAffected, res.Error
+}
+
+// 会话注销时清理该设备的令牌
+func DeleteDeviceTokensByDevice(userID uint, deviceID string) error {
+	if deviceID == "" {
+		return nil
+	}
+	return GetDB().Where("user_id = ? AND device_id = ?", userID, deviceID).Delete(&DeviceToken{}).Error
+}
+
+func DeleteUserDeviceTokens(userID uint) error {
+	return GetDB().Where("user_id = ?", userID).Delete(&DeviceToken{}).Error
+}
+
+// 推送服务商返回令牌失效时清理
+func DeleteDeviceTokens(tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return GetDB().Where("token IN ?", tokens).Delete(&DeviceToken{}).Error
+}
+
+func GetDeviceTokens(userID uint) ([]DeviceToken, error) {
+	var tokens []DeviceToken
+	err := GetDB().Where("user_id = ?", userID).Order("last_seen_at desc").Find(&tokens).Error
+	return tokens, err
+}
+
+func GetDeviceTokensByUsers(userIDs []uint) ([]DeviceToken, error) {
+	var tokens []DeviceToken
+	if len(userIDs) == 0 {
+		return tokens, nil
+	}
+	err := GetDB().Where("user_id IN ?", userIDs).Find(&tokens).Error
+	return tokens, err
+}
+
+func GetPushSettings(userIDs []uint) ([]PushSetting, error) {
+	var settings []PushSetting
+	if len(userIDs) == 0 {
+		return settings, nil
+	}
+	err := GetDB().Where("user_id IN ?", userIDs).Find(&settings).Error
+	return settings, err
+}
+
+func SavePushSetting(s *PushSetting) error {
+	return GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quiet_start", "quiet_end", "timezone"}),
+	}).Create(s).Error
+}
@@ -3,6 +3,8 @@ package model
 import (
 	"context"
 	"log"
+	"time"
+	"worldCity/config"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -11,11 +13,11 @@ var rds *redis.Client
 var Ctx = context.Background()
 
 func InitRedis() {
-	// conf := config.GetConf()
+	conf := config.GetConf()
 	rds = redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379", //conf.Redis.Addr,
-		Password: "",               //conf.Redis.Password,
-		DB:       0,                //conf.Redis.DB,
+		Addr:     conf.Redis.Addr,
+		Password: conf.Redis.Password,
+		DB:       conf.Redis.DB,
 	})
 
 	_, err := rds.Ping(Ctx).Result()
@@ -27,3 +29,16 @@ func InitRedis() {
 func GetRds() *redis.Client {
 	return rds
 }
+
+// 标记不存在时才累加，标记和计数在同一个脚本中完成
+var incrOnceScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], 1, "NX", "PX", ARGV[1]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// IncrOnce 同一个 markKey 只累加一次 key，用于按事件去重的计数
+func IncrOnce(markKey, key string, ttl time.Duration) error {
+	return incrOnceScript.Run(Ctx, GetRds(), []string{markKey, key}, ttl.Milliseconds()).Err()
+}
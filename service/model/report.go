@@ -0,0 +1,137 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 举报对象类型
+const (
+	ReportTargetUser    = "user"
+	ReportTargetMoment  = "moment"
+	ReportTargetComment = "comment"
+	ReportTargetMessage = "message"
+)
+
+// 举报处理状态
+const (
+	ReportStatusPending   = "pending"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// 处理动作
+const (
+	ReportActionDismiss  = "dismiss"
+	ReportActionWarn     = "warn"
+	ReportActionTakedown = "takedown"
+	ReportActionTempBan  = "temp_ban"
+	ReportActionPermBan  = "perm_ban"
+)
+
+// Report 用户举报，Evidence 保存举报时的内容快照，内容被删除后仍可审核
+type Report struct {
+	ID           uint                   `gorm:"primaryKey" json:"id"`
+	ReporterID   uint                   `gorm:"not null;index" json:"reporter_id"`
+	TargetType   string                 `gorm:"size:16;not null;index:idx_report_target" json:"target_type"`
+	TargetID     uint                   `gorm:"not null;index:idx_report_target" json:"target_id"`
+	TargetUserID uint                   `gorm:"not null;index" json:"target_user_id"` // 被举报内容的作者
+	Reason       string                 `gorm:"size:32" json:"reason"`
+	Detail       string                 `gorm:"size:1000" json:"detail"`
+	Evidence     map[string]interface{} `gorm:"type:json;serializer:json" json:"evidence"`
+	Status       string                 `gorm:"size:16;default:pending;index" json:"status"`
+	Action       string                 `gorm:"size:16" json:"action,omitempty"`
+	HandlerID    uint                   `json:"handler_id,omitempty"`
+	HandlerNote  string                 `gorm:"size:1000" json:"handler_note,omitempty"`
+	HandledAt    *time.Time             `json:"handled_at,omitempty"`
+	CreatedAt    time.Time              `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// UserSanction 对用户的处罚记录
+type UserSanction struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Action     string     `gorm:"size:16" json:"action"` // warn, temp_ban, perm_ban
+	Reason     string     `gorm:"size:1000" json:"reason"`
+	ReportID   uint       `json:"report_id"`
+	OperatorID uint       `json:"operator_id"`
+	ExpiresAt  *time.Time `json:"expires_at"` // 封禁到期时间，永久封禁为空
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func CreateReport(report *Report) error {
+	return GetDB().Create(report).Error
+}
+
+func GetReportById(id uint) (*Report, error) {
+	var report Report
+	if err := GetDB().First(&report, id).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// 同一用户对同一对象只保留一条待处理的举报
+func HasPendingReport(reporterID uint, targetType string, targetID uint) (bool, error) {
+	var count int64
+	err := GetDB().Model(&Report{}).
+		Where("reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?", reporterID, targetType, targetID, ReportStatusPending).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 审核队列，按举报时间先后
+func GetReports(status string, offset, limit int) ([]Report, int64, error) {
+	query := GetDB().Model(&Report{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var reports []Report
+	err := query.Order("id asc").Offset(offset).Limit(limit).Find(&reports).Error
+	return reports, total, err
+}
+
+// 处理举报，同一对象的其他待处理举报一并处理，处罚记录在同一事务中写入
+func ResolveReports(report *Report, status, action string, handlerID uint, note string, sanction *UserSanction) error {
+	now := time.Now()
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Report{}).
+			Where("target_type = ? AND target_id = ? AND (id = ? OR status = ?)", report.TargetType, report.TargetID, report.ID, ReportStatusPending).
+			Updates(map[string]interface{}{
+				"status":       status,
+				"action":       action,
+				"handler_id":   handlerID,
+				"handler_note": note,
+				"handled_at":   &now,
+			}).Error
+		if err != nil {
+			return err
+		}
+		if sanction == nil {
+			return nil
+		}
+		if err := tx.Create(sanction).Error; err != nil {
+			return err
+		}
+		if sanction.Action == ReportActionWarn {
+			return nil
+		}
+		// 永久封禁使用一个足够远的时间
+		until := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+		if sanction.ExpiresAt != nil {
+			until = *sanction.ExpiresAt
+		}
+		return tx.Model(&User{}).Where("id = ?", sanction.UserID).Update("banned_until", until).Error
+	})
+}
+
+func GetUserSanctions(userID uint) ([]UserSanction, error) {
+	var sanctions []UserSanction
+	err := GetDB().Where("user_id = ?", userID).Order("id desc").Find(&sanctions).Error
+	return sanctions, err
+}
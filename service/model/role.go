@@ -0,0 +1,43 @@
+package model
+
+// 用户角色
+const (
+	RoleUser          = "user"           // 普通用户
+	RoleProvider      = "provider"       // 服务提供者
+	RoleMerchantAdmin = "merchant_admin" // 商家管理员
+	RoleOperator      = "operator"       // 运营
+	RoleSuperAdmin    = "super_admin"    // 超级管理员，拥有全部权限
+)
+
+// 权限
+const (
+	PermManageUsers     = "manage_users"     // 代替他人修改资料、地址等
+	PermManageRoles     = "manage_roles"     // 调整用户角色
+	PermModerateContent = "moderate_content" // 内容审核
+	PermManageMerchant  = "manage_merchant"  // 管理商家及商品
+	PermProvideService  = "provide_service"  // 发布和提供服务
+)
+
+var rolePermissions = map[string][]string{
+	RoleUser:          {},
+	RoleProvider:      {PermProvideService},
+	RoleMerchantAdmin: {PermManageMerchant},
+	RoleOperator:      {PermManageUsers, PermModerateContent},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok || role == RoleSuperAdmin
+}
+
+func HasPermission(role, perm string) bool {
+	if role == RoleSuperAdmin {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
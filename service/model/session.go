@@ -0,0 +1,164 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session 一个设备上的登录会话，保存在 Redis 中，刷新令牌只保存哈希
+type Session struct {
+	ID           string    `json:"id"`
+	UserID       uint      `json:"user_id"`
+	DeviceID     string    `json:"device_id"`
+	DeviceName   string    `json:"device_name"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	RefreshHash  string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+}
+
+// Redis 中保存的结构，RefreshHash 不对外输出但需要持久化
+type storedSession struct {
+	Session
+	RefreshHash string `json:"refresh_hash"`
+}
+
+func buildSessionKey(sid string) string {
+	return fmt.Sprintf("session:%s", sid)
+}
+
+// 用户的会话列表，hash 结构，field 为设备ID，value 为会话ID
+func buildUserSessionsKey(uid uint) string {
+	return fmt.Sprintf("user_sessions:%d", uid)
+}
+
+// 保存会话，同一设备上的旧会话会被替换
+func SaveSession(s *Session, ttl time.Duration) error {
+	data, err := json.Marshal(storedSession{Session: *s, RefreshHash: s.RefreshHash})
+	if err != nil {
+		return err
+	}
+	rds := GetRds()
+	old, err := rds.HGet(Ctx, buildUserSessionsKey(s.UserID), s.DeviceID).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	_, err = rds.TxPipelined(Ctx, func(pipe redis.Pipeliner) error {
+		if old != "" && old != s.ID {
+			pipe.Del(Ctx, buildSessionKey(old))
+		}
+		pipe.Set(Ctx, buildSessionKey(s.ID), data, ttl)
+		pipe.HSet(Ctx, buildUserSessionsKey(s.UserID), s.DeviceID, s.ID)
+		pipe.Expire(Ctx, buildUserSessionsKey(s.UserID), ttl)
+		return nil
+	})
+	return err
+}
+
+func GetSession(sid string) (*Session, error) {
+	data, err := GetRds().Get(Ctx, buildSessionKey(sid)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored storedSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	stored.Session.RefreshHash = stored.RefreshHash
+	return &stored.Session, nil
+}
+
+func SessionExists(sid string) (bool, error) {
+	n, err := GetRds().Exists(Ctx, buildSessionKey(sid)).Result()
+	return n > 0, err
+}
+
+// 用户当前有效的会话，顺便清理已过期的设备记录
+func GetUserSessions(uid uint) ([]Session, error) {
+	devices, err := GetRds().HGetAll(Ctx, buildUserSessionsKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, 0, len(devices))
+	for deviceID, sid := range devices {
+		s, err := GetSession(sid)
+		if errors.Is(err, ErrSessionNotFound) {
+			GetRds().HDel(Ctx, buildUserSessionsKey(uid), deviceID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, nil
+}
+
+func DeleteSession(s *Session) error {
+	_, err := GetRds().TxPipelined(Ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(Ctx, buildSessionKey(s.ID))
+		pipe.HDel(Ctx, buildUserSessionsKey(s.UserID), s.DeviceID)
+		return nil
+	})
+	return err
+}
+
+// 删除用户的全部会话
+func DeleteUserSessions(uid uint) error {
+	sids, err := GetRds().HVals(Ctx, buildUserSessionsKey(uid)).Result()
+	if err != nil {
+		return err
+	}
+	_, err = GetRds().TxPipelined(Ctx, func(pipe redis.Pipeliner) error {
+		for _, sid := range sids {
+			pipe.Del(Ctx, buildSessionKey(sid))
+		}
+		pipe.Del(Ctx, buildUserSessionsKey(uid))
+		return nil
+	})
+	return err
+}
+
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// 轮换刷新令牌，旧令牌哈希不匹配说明令牌已被使用过
+func RotateSessionRefresh(sid, oldHash, newHash string, ttl time.Duration) (*Session, error) {
+	var session *Session
+	key := buildSessionKey(sid)
+	err := GetRds().Watch(Ctx, func(tx *redis.Tx) error {
+		s, err := GetSession(sid)
+		if err != nil {
+			return err
+		}
+		if s.RefreshHash != oldHash {
+			return ErrRefreshTokenReused
+		}
+		s.RefreshHash = newHash
+		s.LastActiveAt = time.Now()
+		data, err := json.Marshal(storedSession{Session: *s, RefreshHash: newHash})
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(Ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(Ctx, key, data, ttl)
+			pipe.Expire(Ctx, buildUserSessionsKey(s.UserID), ttl)
+			return nil
+		})
+		session = s
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return nil, ErrRefreshTokenReused
+	}
+	return session, err
+}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Topic 话题，由动态中的 #话题 自动创建，Key 为统一小写后的名称
+type Topic struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Key       string    `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Name      string    `gorm:"size:64;not null" json:"name"` // 第一次出现时的写法
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// MomentTopic 动态和话题的关联
+type MomentTopic struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MomentID  uint      `gorm:"not null;uniqueIndex:idx_moment_topic" json:"moment_id"`
+	TopicID   uint      `gorm:"not null;uniqueIndex:idx_moment_topic;index" json:"topic_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TopicFollow 关注的话题，话题下的动态会出现在时间线中
+type TopicFollow struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_topic" json:"user_id"`
+	TopicID   uint      `gorm:"not null;uniqueIndex:idx_user_topic;index" json:"topic_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 按 key 获取话题，不存在的话题会被创建，names 为 key -> 写法
+func GetOrCreateTopics(names map[string]string) ([]Topic, error) {
+	var topics []Topic
+	if len(names) == 0 {
+		return topics, nil
+	}
+	keys := make([]string, 0, len(names))
+	rows := make([]Topic, 0, len(names))
+	for key, name := range names {
+		keys = append(keys, key)
+		rows = append(rows, Topic{Key: key, Name: name})
+	}
+	db := GetDB()
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	err := db.Where("`key` IN ?", keys).Find(&topics).Error
+	return topics, err
+}
+
+// 替换动态关联的话题
+func SetMomentTopics(momentID uint, topicIDs []uint) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("moment_id = ?", momentID).Delete(&MomentTopic{}).Error; err != nil {
+			return err
+		}
+		if len(topicIDs) == 0 {
+			return nil
+		}
+		rows := make([]MomentTopic, 0, len(topicIDs))
+		for _, id := range topicIDs {
+			rows = append(rows, MomentTopic{MomentID: momentID, TopicID: id})
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+func GetMomentTopicIDs(momentID uint) ([]uint, error) {
+	var ids []uint
+	err := GetDB().Model(&MomentTopic{}).Where("moment_id = ?", momentID).Pluck("topic_id", &ids).Error
+	return ids, err
+}
+
+func GetTopicByKey(key string) (*Topic, error) {
+	var topic Topic
+	if err := GetDB().Where("`key` = ?", key).First(&topic).Error; err != nil {
+		return nil, err
+	}
+	return &topic, nil
+}
+
+func GetTopicsByIds(ids []uint) ([]Topic, error) {
+	var topics []Topic
+	if len(ids) == 0 {
+		return topics, nil
+	}
+	err := GetDB().Where("id IN ?", ids).Find(&topics).Error
+	return topics, err
+}
+
+// 话题下可见的动态数
+func CountTopicMoments(topicID uint) (int64, error) {
+	var count int64
+	err := GetDB().Model(&Moment{}).
+		Where("review_status = ? AND id IN (?)", ReviewStatusApproved,
+			GetDB().Model(&MomentTopic{}).Select("moment_id").Where("topic_id = ?", topicID)).
+		Count(&count).Error
+	return count, err
+}
+
+func CountTopicFollowers(topicID uint) (int64, error) {
+	var count int64
+	err := GetDB().Model(&TopicFollow{}).Where("topic_id = ?", topicID).Count(&count).Error
+	return count, err
+}
+
+// 重复关注不报错
+func FollowTopic(userID, topicID uint) error {
+	return GetDB().Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&TopicFollow{UserID: userID, TopicID: topicID}).Error
+}
+
+func UnfollowTopic(userID, topicID uint) error {
+	return GetDB().Where("user_id = ? AND topic_id = ?", userID, topicID).Delete(&TopicFollow{}).Error
+}
+
+func IsFollowingTopic(userID, topicID uint) (bool, error) {
+	var count int64
+	err := GetDB().Model(&TopicFollow{}).Where("user_id = ? AND topic_id = ?", userID, topicID).Count(&count).Error
+	return count > 0, err
+}
+
+func GetFollowedTopicIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := GetDB().Model(&TopicFollow{}).Where("user_id = ?", userID).Order("id desc").Pluck("topic_id", &ids).Error
+	return ids, err
+}
+
+// MomentFeedFilter 动态列表的查询条件
+// UserIDs 和 TopicIDs 都为空时不限制来源，否则返回其中任一用户发布的或带有任一话题的动态
+type MomentFeedFilter struct {
+	ViewerID       uint // 只返回查看者可见的动态，见 visibleMoments
+	UserIDs        []uint
+	TopicIDs       []uint
+	ExcludeUserIDs []uint
+	Offset         int
+	Limit          int
+}
+
+func GetFeedMoments(f MomentFeedFilter) ([]Moment, error) {
+	db := GetDB()
+	query := visibleMoments(db.Model(&Moment{}), f.ViewerID)
+	topicMoments := db.Model(&MomentTopic{}).Select("moment_id").Where("topic_id IN ?", f.TopicIDs)
+	switch {
+	case len(f.UserIDs) > 0 && len(f.TopicIDs) > 0:
+		query = query.Where("user_id IN ? OR id IN (?)", f.UserIDs, topicMoments)
+	case len(f.UserIDs) > 0:
+		query = query.Where("user_id IN ?", f.UserIDs)
+	case len(f.TopicIDs) > 0:
+		query = query.Where("id IN (?)", topicMoments)
+	}
+	if len(f.ExcludeUserIDs) > 0 {
+		query = query.Where("user_id NOT IN ?", f.ExcludeUserIDs)
+	}
+	var moments []Moment
+	err := query.Order("created_at desc, id desc").Offset(f.Offset).Limit(f.Limit).Find(&moments).Error
+	return moments, err
+}
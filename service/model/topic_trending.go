@@ -0,0 +1,80 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 话题热度按小时分桶记录互动，查询时按时间衰减合并最近的分桶
+const (
+	topicTrendKeyPrefix = "topic_trend:"
+	topicTrendingKey    = "topic_trending" // 合并结果的缓存
+	topicTrendingTTL    = time.Minute
+)
+
+func buildTopicTrendKey(hour int64) string {
+	return fmt.Sprintf("%s%d", topicTrendKeyPrefix, hour)
+}
+
+// 给话题增加互动分数，分桶在统计窗口结束后过期
+func IncrTopicEngagement(topicIDs []uint, weight float64, at time.Time, window time.Duration) error {
+	if len(topicIDs) == 0 {
+		return nil
+	}
+	key := buildTopicTrendKey(at.Unix() / 3600)
+	pipe := GetRds().Pipeline()
+	for _, id := range topicIDs {
+		pipe.ZIncrBy(Ctx, key, weight, strconv.FormatUint(uint64(id), 10))
+	}
+	pipe.Expire(Ctx, key, window+time.Hour)
+	_, err := pipe.Exec(Ctx)
+	return err
+}
+
+// TopicScore 话题的热度分数
+type TopicScore struct {
+	TopicID uint
+	Score   float64
+}
+
+// 最近 window 内的热度，每过 halfLife 权重减半，结果缓存一分钟
+func GetTrendingTopicScores(now time.Time, window, halfLife time.Duration, limit int) ([]TopicScore, error) {
+	rds := GetRds()
+	exists, err := rds.Exists(Ctx, topicTrendingKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		hours := max(int64(window/time.Hour), 1)
+		cur := now.Unix() / 3600
+		keys := make([]string, 0, hours)
+		weights := make([]float64, 0, hours)
+		for age := int64(0); age < hours; age++ {
+			keys = append(keys, buildTopicTrendKey(cur-age))
+			weights = append(weights, math.Pow(0.5, float64(age)*float64(time.Hour)/float64(halfLife)))
+		}
+		pipe := rds.TxPipeline()
+		pipe.ZUnionStore(Ctx, topicTrendingKey, &redis.ZStore{Keys: keys, Weights: weights, Aggregate: "SUM"})
+		pipe.Expire(Ctx, topicTrendingKey, topicTrendingTTL)
+		if _, err := pipe.Exec(Ctx); err != nil {
+			return nil, err
+		}
+	}
+	zs, err := rds.ZRevRangeWithScores(Ctx, topicTrendingKey, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]TopicScore, 0, len(zs))
+	for _, z := range zs {
+		id, err := strconv.ParseUint(fmt.Sprint(z.Member), 10, 64)
+		if err != nil {
+			continue
+		}
+		scores = append(scores, TopicScore{TopicID: uint(id), Score: z.Score})
+	}
+	return scores, nil
+}
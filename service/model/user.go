@@ -6,35 +6,49 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 视频访问权限
+const (
+	VideoAccessPublic    uint = 0 // 所有人可见
+	VideoAccessFollowers uint = 1 // 关注者可见
+	VideoAccessPaid      uint = 2 // 金币解锁
 )
 
 type VideoInfo struct {
+	ID     string `json:"id"`
 	Title  string `json:"title"`
 	Access uint   `json:"access"`
+	Price  uint   `json:"price"` // 解锁所需金币，仅 Access 为 VideoAccessPaid 时有效
+	Cover  string `json:"cover"`
 	Uri    string `json:"uri"`
 }
 
 type User struct {
 	// 默认包含ID, CreatedAt, UpdatedAt, DeletedAt
-	ID         uint        `gorm:"primary,unique" json:"id"`
-	Name       string      `gorm:"unique" json:"name"`
-	Nickname   string      `json:"nickname"`
-	Password   string      `json:"password"`
-	Avatar     string      `json:"avatar"`
-	AccId      string      `gorm:"unique" json:"acc_id"`
-	MerchantId uint        `json:"merchant_id"`
-	Gender     uint        `json:"gender"`
-	Photos     []string    `gorm:"type:json;serializer:json" json:"photos"` // 推荐使用 GORM serializer
-	Videos     []VideoInfo `gorm:"type:json;serializer:json" json:"videos"`
-	Desc       string      `gorm:"type:text" json:"desc"`
-	Birthday   time.Time   `gorm:"default:'1970-01-01'" json:"birthday"`
-	Height     uint        `json:"height"`
-	Weight     uint        `json:"weight"`
-	Coins      uint        `gorm:"default:0" json:"coins"`
-	Merchant   Merchant    `json:"merchant"`
-	CreatedAt  time.Time   `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt  time.Time   `gorm:"default:NULL" json:"deleted_at"`
+	ID          uint        `gorm:"primary,unique" json:"id"`
+	Name        string      `gorm:"unique" json:"name"`
+	Nickname    string      `json:"nickname"`
+	Password    string      `json:"-"` // 密码哈希，任何接口都不返回
+	Avatar      string      `json:"avatar"`
+	AccId       string      `gorm:"unique" json:"acc_id"`
+	MerchantId  uint        `json:"merchant_id"`
+	Gender      uint        `json:"gender"`
+	Photos      []string    `gorm:"type:json;serializer:json" json:"photos"` // 推荐使用 GORM serializer
+	Videos      []VideoInfo `gorm:"type:json;serializer:json" json:"videos"`
+	Desc        string      `gorm:"type:text" json:"desc"`
+	Birthday    time.Time   `gorm:"default:'1970-01-01'" json:"birthday"`
+	Height      uint        `json:"height"`
+	Weight      uint        `json:"weight"`
+	Coins       uint        `gorm:"default:0" json:"coins"`
+	CallPrice   uint        `gorm:"default:0" json:"call_price"` // 音视频通话每分钟收费金币，0 表示免费
+	Role        string      `gorm:"size:32;default:user" json:"role"`
+	BannedUntil *time.Time  `json:"banned_until"` // 封禁到期时间，为空表示未封禁
+	Merchant    Merchant    `json:"merchant"`
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt   time.Time   `gorm:"default:NULL" json:"deleted_at"`
 }
 
 type Tags struct {
@@ -55,6 +69,29 @@ func GetUserById(uid uint) (*User, error) {
 	return &user, nil
 }
 
+// 批量查询用户，不存在的 ID 不在结果中
+func GetUsersByIds(ids []uint) ([]User, error) {
+	var users []User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := GetDB().Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+// 查询用户的云信账号，没有云信账号的用户不在结果中
+func GetAccIds(ids ...uint) (map[uint]string, error) {
+	var users []User
+	if err := GetDB().Select("id", "acc_id").Where("id IN ? AND acc_id <> ''", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[uint]string, len(users))
+	for _, u := range users {
+		res[u.ID] = u.AccId
+	}
+	return res, nil
+}
+
 func GetUserByName(name string) (*User, error) {
 	var user User
 	db := GetDB()
@@ -64,11 +101,17 @@ func GetUserByName(name string) (*User, error) {
 	return &user, nil
 }
 
-func GetUserList(PageNum, PageSize uint) ([]User, error) {
+// excludeIDs 中的用户不出现在列表中，例如存在拉黑关系的用户
+func GetUserList(PageNum, PageSize uint, excludeIDs []uint) ([]User, error) {
 	var users []User
 	db := GetDB()
 	offset := (PageNum - 1) * PageSize
-	if err := db.Model(&User{}).Find(&users).Offset(int(offset)).Limit(int(PageSize)).Error; err != nil {
+	query := db.Model(&User{})
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeIDs)
+	}
+	// Offset 和 Limit 必须在 Find 之前，否则不生效
+	if err := query.Order("id").Offset(int(offset)).Limit(int(PageSize)).Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
@@ -87,8 +130,8 @@ func IsUserExisted(name string) (bool, error) {
 	return true, nil
 }
 
-func CreateUser(user *User) (*User, error) {
-	db := GetDB()
+// db 可以是事务，用户和领域事件一起提交
+func CreateUser(db *gorm.DB, user *User) (*User, error) {
 	// gorm create 之后会自动更新原结构体
 	err := db.Model(&User{}).Create(user).Error
 	if err != nil {
@@ -131,12 +174,107 @@ func CreateTag(UserId uint, Tag string) (*Tags, error) {
 	return tag, nil
 }
 
-func DeleteTag(TagId uint) error {
+func DeleteTag(UserId, TagId uint) error {
 	db := GetDB()
-	return db.Model(&Tags{}).Delete(&Tags{}, TagId).Error
+	return db.Model(&Tags{}).Where("user_id = ?", UserId).Delete(&Tags{}, TagId).Error
 }
 
 func UserRecharge(UserId, Coins uint) error {
 	db := GetDB()
 	return db.Model(&User{}).Where("id=?", UserId).Update("coins", gorm.Expr("coins + ?", Coins)).Error
 }
+
+func UpdateUserRole(userID uint, role string) error {
+	return GetDB().Model(&User{}).Where("id = ?", userID).Update("role", role).Error
+}
+
+func UpdateUserPassword(userID uint, hash string) error {
+	return GetDB().Model(&User{}).Where("id = ?", userID).Update("password", hash).Error
+}
+
+func (u *User) IsBanned() bool {
+	return u.BannedUntil != nil && u.BannedUntil.After(time.Now())
+}
+
+// 按列更新资料，cols 中的零值也会写入
+func UpdateUserColumns(user *User, cols []string) error {
+	return GetDB().Model(&User{}).Where("id = ?", user.ID).Select(cols).Updates(user).Error
+}
+
+// 用户排序方式
+const (
+	UserSortRecommend = "recommend"
+	UserSortNewest    = "newest"
+)
+
+// UserFilter 发现用户的筛选条件
+type UserFilter struct {
+	ExcludeIDs  []uint     // 排除的用户，例如自己和拉黑关系
+	Gender      *uint      // 性别
+	BornAfter   *time.Time // 生日晚于该时间，对应年龄上限
+	BornBefore  *time.Time // 生日早于该时间，对应年龄下限
+	Tags        []string   // 包含任一标签
+	OnlineIDs   []uint     // 最近活跃的在线用户，推荐排序时排在前面，调用方需要限制数量
+	OnlineOnly  bool       // 只返回 OnlineIDs 中的用户
+	HasServices bool       // 只返回有上架服务的用户
+	Sort        string
+	Offset      int
+	Limit       int
+}
+
+// 按条件分页查询用户，返回当前页和总数
+func SearchUsers(f *UserFilter) ([]User, int64, error) {
+	// 不返回已注销和封禁中的用户
+	query := GetDB().Model(&User{}).Where("deleted_at IS NULL").
+		Where("banned_until IS NULL OR banned_until < ?", time.Now())
+	if len(f.ExcludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", f.ExcludeIDs)
+	}
+	if f.Gender != nil {
+		query = query.Where("gender = ?", *f.Gender)
+	}
+	if f.BornAfter != nil || f.BornBefore != nil {
+		// 未填写生日的用户使用默认值，不参与年龄筛选
+		query = query.Where("birthday <> ?", "1970-01-01")
+	}
+	if f.BornAfter != nil {
+		query = query.Where("birthday > ?", *f.BornAfter)
+	}
+	if f.BornBefore != nil {
+		query = query.Where("birthday <= ?", *f.BornBefore)
+	}
+	if len(f.Tags) > 0 {
+		query = query.Where("id IN (?)", GetDB().Model(&Tags{}).Select("user_id").Where("tag IN ?", f.Tags))
+	}
+	if f.OnlineOnly {
+		if len(f.OnlineIDs) == 0 {
+			return []User{}, 0, nil
+		}
+		query = query.Where("id IN ?", f.OnlineIDs)
+	}
+	if f.HasServices {
+		query = query.Where("EXISTS (?)", GetDB().Model(&Product{}).Select("1").
+			Where("products.user_id = users.id AND products.is_active = ?", true))
+	}
+
+	// 新会话使计数和分页查询互不影响
+	query = query.Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 排序写在同一个表达式里，clause.OrderBy 的表达式和列不能混用
+	order := clause.Expr{SQL: "created_at DESC, id DESC", WithoutParentheses: true}
+	if f.Sort != UserSortNewest {
+		// 推荐：在线优先，其次资料完整度，最后按最近更新
+		order.SQL = "CASE WHEN avatar <> '' THEN 1 ELSE 0 END + CASE WHEN `desc` <> '' THEN 1 ELSE 0 END DESC, updated_at DESC, id DESC"
+		if len(f.OnlineIDs) > 0 {
+			order.SQL = "CASE WHEN id IN (?) THEN 1 ELSE 0 END DESC, " + order.SQL
+			order.Vars = []interface{}{f.OnlineIDs}
+		}
+	}
+	var users []User
+	err := query.Order(clause.OrderBy{Expression: order}).Offset(f.Offset).Limit(f.Limit).Find(&users).Error
+	return users, total, err
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	auth "worldCity/controller/auth"
+	"worldCity/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,7 +10,27 @@ import (
 func InitAuthRoutes(api *gin.RouterGroup) {
 	api.POST("/auth/register", auth.Register)
 	api.POST("/auth/login", auth.Login)
+	api.POST("/auth/login/sms", auth.SmsLogin)
 
 	api.POST("/auth/send_code", auth.SendCode)
 	api.POST("/auth/verify_code", auth.VerifyCode)
+
+	// 第三方登录
+	api.GET("/auth/oauth/:provider/authorize", auth.OAuthAuthorize)
+	api.POST("/auth/oauth/:provider/callback", auth.OAuthCallback)
+
+	api.POST("/auth/refresh", auth.Refresh)
+	session := api.Group("/auth", middleware.JWTAuth())
+	{
+		session.POST("/logout", auth.Logout)
+		session.POST("/logout-all", auth.LogoutAll)
+		session.GET("/sessions", auth.GetSessions)
+		session.DELETE("/sessions/:session_id", auth.RevokeSession)
+
+		// 绑定第三方账号
+		session.GET("/oauth/:provider/link/authorize", auth.OAuthLinkAuthorize)
+		session.POST("/oauth/:provider/link", auth.OAuthLink)
+		session.GET("/identities", auth.GetIdentities)
+		session.DELETE("/identities/:provider", auth.UnlinkIdentity)
+	}
 }
@@ -0,0 +1,21 @@
+package router
+
+import (
+	controller "worldCity/controller/call"
+	"worldCity/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitCallRoutes(api *gin.RouterGroup) {
+	call := api.Group("/call", middleware.JWTAuth())
+	{
+		call.POST("/start", controller.StartCall)
+		call.GET("/:call_id", controller.GetCall)
+		call.POST("/:call_id/accept", controller.AcceptCall)
+		call.POST("/:call_id/reject", controller.RejectCall)
+		call.POST("/:call_id/cancel", controller.CancelCall)
+		call.POST("/:call_id/hangup", controller.HangupCall)
+		call.POST("/:call_id/signal", controller.RelayCallSignal)
+	}
+}
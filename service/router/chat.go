@@ -13,11 +13,20 @@ func RegisterChatRoutes(rg *gin.RouterGroup) {
 
 	// 私聊
 	chat.POST("/private/send", message.SendPrivateMessage)
-	// chat.GET("/private/history", controller.GetPrivateChatHistory)
+	chat.GET("/private/history", message.GetHistoryMessages)
+	chat.POST("/private/message/:id/recall", message.RecallMessage)
+	chat.POST("/private/message/:id/edit", message.EditMessage)
+	chat.DELETE("/private/message/:id", message.DeleteMessage)
+	chat.GET("/private/message/:id/revisions", message.GetMessageRevisions)
 
 	// 群聊
 	chat.POST("/group/send", group.SendGroupMessage)
-	// chat.GET("/group/history", controller.GetGroupChatHistory)
+	chat.POST("/group/message/:id/recall", group.RecallGroupMessage)
+	chat.POST("/group/message/:id/edit", group.EditGroupMessage)
+	chat.DELETE("/group/message/:id", group.DeleteGroupMessage)
+	chat.GET("/group/history", group.GetGroupHistoryMessages)
+	chat.GET("/group/unread", group.GetGroupUnreadCounts)
+	chat.POST("/group/read", group.MarkGroupRead)
 
 	// 已读回执
 	// chat.POST("/read-receipt", controller.MarkMessagesAsRead)
@@ -8,8 +8,15 @@ import (
 )
 
 func InitFileRoutes(api *gin.RouterGroup) {
-	api.Use(middleware.JWTAuth()) // 下面接口需要登录
+	// 本地存储直传，凭签名访问
+	api.PUT("/file/direct", file.DirectUpload)
+
+	files := api.Group("/file", middleware.JWTAuth()) // 下面接口需要登录
 	{
-		api.POST("/file/upload", file.UploadFile)
+		files.POST("/upload", file.UploadFile)
+		files.POST("/presign", file.PresignUpload)
+		files.POST("/complete", file.CompleteUpload)
+		files.POST("/variants", file.GetImageVariants)
+		files.GET("/:id", file.GetFile)
 	}
 }
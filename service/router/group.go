@@ -2,21 +2,33 @@ package router
 
 import (
 	controller "worldCity/controller/group"
+	"worldCity/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func InitGroupRoutes(api *gin.RouterGroup) {
 
-	group := api.Group("/group")
+	group := api.Group("/group", middleware.JWTAuth())
 	{
 		group.POST("/create", controller.CreateGroup)
 		group.POST("/send", controller.SendGroupMessage)
 
-		// api.POST("/group/create", controller.CreateGroup)
-		// api.POST("/group/join", controller.JoinGroup)
-		// api.POST("/video/start", controller.StartVideoCall)
-		// api.POST("/video/accept", controller.AcceptVideoCall)
-		// api.POST("/video/cancel", controller.CancelVideoCall)
+		// 入群：邀请链接或申请
+		group.POST("/join", controller.JoinGroupByInvite)
+		group.GET("/:group_id", controller.GetGroupInfo)
+		group.POST("/:group_id/invite", controller.CreateGroupInvite)
+		group.POST("/:group_id/join-requests", controller.ApplyJoinGroup)
+		group.GET("/:group_id/join-requests", controller.GetGroupJoinRequests)
+		group.POST("/:group_id/join-requests/:request_id", controller.HandleGroupJoinRequest)
+
+		// 成员管理
+		group.POST("/:group_id/leave", controller.LeaveGroup)
+		group.POST("/:group_id/kick", controller.KickGroupMember)
+		group.POST("/:group_id/role", controller.SetGroupMemberRole)
+		group.POST("/:group_id/transfer", controller.TransferGroup)
+		group.POST("/:group_id/mute", controller.MuteGroupMember)
+		group.POST("/:group_id/mute-all", controller.MuteAllGroupMembers)
+		group.DELETE("/:group_id", controller.DissolveGroup)
 	}
 }
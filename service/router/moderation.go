@@ -0,0 +1,19 @@
+package router
+
+import (
+	controller "worldCity/controller/moderation"
+	"worldCity/middleware"
+	"worldCity/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitModerationRoutes(api *gin.RouterGroup) {
+	// 运营查看审核记录，处理待人工审核的内容
+	moderation := api.Group("/admin/moderation", middleware.JWTAuth(), middleware.RequireRole(model.RoleOperator))
+	{
+		moderation.GET("/records", controller.ListRecords)
+		moderation.POST("/records/:id/review", controller.ReviewRecord)
+		moderation.POST("/reload", controller.ReloadDict)
+	}
+}
@@ -3,6 +3,7 @@ package router
 import (
 	controller "worldCity/controller/moment"
 	"worldCity/middleware"
+	"worldCity/model"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,10 +13,19 @@ func RegisterMomentRoutes(r *gin.RouterGroup) {
 
 	moments.Use(middleware.JWTAuth())
 	{
-		r.GET("", controller.GetMoments)
-		r.POST("", controller.PostMoment)
-		r.POST("/:id/like", controller.LikeMoment)
-		r.POST("/:id/comment", controller.CommentMoment)
-		r.GET("/:id/comments", controller.GetMomentComments)
+		moments.GET("", controller.GetMoments)
+		moments.GET("/timeline", controller.GetTimeline)
+		moments.POST("", controller.PostMoment)
+		moments.PATCH("/:id", controller.EditMoment)
+		moments.DELETE("/:id", controller.DeleteMoment)
+		moments.POST("/:id/like", controller.LikeMoment)
+		moments.POST("/:id/comment", controller.CommentMoment)
+		moments.GET("/:id/comments", controller.GetMomentComments)
+	}
+
+	// 运营下架动态
+	admin := r.Group("/admin", middleware.JWTAuth(), middleware.RequireRole(model.RoleOperator))
+	{
+		admin.POST("/moments/:id/takedown", controller.TakedownMoment)
 	}
 }
@@ -0,0 +1,26 @@
+package router
+
+import (
+	controller "worldCity/controller/notification"
+	"worldCity/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitNotificationRoutes(api *gin.RouterGroup) {
+	notification := api.Group("/notifications", middleware.JWTAuth())
+	{
+		notification.GET("", controller.GetNotifications)
+		notification.GET("/unread_count", controller.GetUnreadCount)
+		notification.POST("/read", controller.MarkRead)
+		notification.GET("/preferences", controller.GetPreferences)
+		notification.PUT("/preferences", controller.UpdatePreferences)
+
+		// 手机推送的设备令牌和免打扰时段
+		notification.GET("/devices", controller.GetDevices)
+		notification.POST("/devices", controller.RegisterDevice)
+		notification.DELETE("/devices", controller.UnregisterDevice)
+		notification.GET("/push_settings", controller.GetPushSetting)
+		notification.PUT("/push_settings", controller.UpdatePushSetting)
+	}
+}
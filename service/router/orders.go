@@ -12,9 +12,8 @@ func RegisterOrderRoutes(router *gin.RouterGroup, orderService services.OrderSer
 
 	orderController := controllers.NewOrderController(orderService)
 
-	orderRoutes := router.Group("/orders")
-	// 添加认证中间件
-	orderRoutes.Use(middleware.JWTAuth())
+	// 订单只能由本人查看和操作，归属在 service 中按订单校验
+	orderRoutes := router.Group("/orders", middleware.JWTAuth())
 	{
 		orderRoutes.POST("", orderController.CreateOrder)                   // POST /api/v1/orders
 		orderRoutes.GET("", orderController.GetOrders)                      // GET /api/v1/orders
@@ -0,0 +1,21 @@
+package router
+
+import (
+	controller "worldCity/controller/report"
+	"worldCity/middleware"
+	"worldCity/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitReportRoutes(api *gin.RouterGroup) {
+	api.POST("/report", middleware.JWTAuth(), controller.CreateReport)
+
+	// 运营审核举报
+	admin := api.Group("/admin", middleware.JWTAuth(), middleware.RequireRole(model.RoleOperator))
+	{
+		admin.GET("/reports", controller.ListReports)
+		admin.POST("/reports/:id/resolve", controller.ResolveReport)
+		admin.GET("/users/:id/sanctions", controller.GetUserSanctions)
+	}
+}
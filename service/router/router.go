@@ -15,6 +15,13 @@ func InitRoutes(r *gin.Engine) {
 	InitUserRoutes(api)
 	RegisterMomentRoutes(api)
 	InitServicesRouters(api)
+	RegisterChatRoutes(api)
+	InitGroupRoutes(api)
+	InitCallRoutes(api)
+	InitReportRoutes(api)
+	InitModerationRoutes(api)
+	InitNotificationRoutes(api)
+	InitTopicRoutes(api)
 
 	// 3. 依赖注入：初始化 Repository, Service
 	orderRepo := model.NewOrderRepository(nil)
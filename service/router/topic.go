@@ -0,0 +1,20 @@
+package router
+
+import (
+	controller "worldCity/controller/topic"
+	"worldCity/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitTopicRoutes(api *gin.RouterGroup) {
+	topics := api.Group("/topics", middleware.JWTAuth())
+	{
+		topics.GET("/trending", controller.GetTrendingTopics)
+		topics.GET("/following", controller.GetFollowedTopics)
+		topics.GET("/:name", controller.GetTopic)
+		topics.GET("/:name/moments", controller.GetTopicMoments)
+		topics.POST("/:name/follow", controller.FollowTopic)
+		topics.DELETE("/:name/follow", controller.UnfollowTopic)
+	}
+}
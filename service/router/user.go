@@ -3,6 +3,7 @@ package router
 import (
 	controller "worldCity/controller/user"
 	"worldCity/middleware"
+	"worldCity/model"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,31 +12,70 @@ func InitUserRoutes(api *gin.RouterGroup) {
 	user := api.Group("/user")
 
 	user.Use(middleware.JWTAuth()) // 下面接口需要登录
+	self := middleware.RequireSelf("id")
 	{
 		user.GET("/check", controller.CheckLogin)
+
+		// 个人数据导出和账号注销
+		user.POST("/me/export", controller.RequestDataExport)
+		user.GET("/me/export", controller.GetDataExports)
+		user.GET("/me/export/:export_id/download", controller.DownloadDataExport)
+		user.POST("/me/delete", controller.RequestAccountDeletion)
+		user.GET("/me/delete", controller.GetAccountDeletion)
+		user.POST("/me/delete/cancel", controller.CancelAccountDeletion)
+
+		// 黑名单和屏蔽列表
+		user.GET("/me/blocks", controller.GetBlockedUsers)
+		user.GET("/me/mutes", controller.GetMutedUsers)
+
 		user.GET("/list", controller.GetUserList)
+		user.GET("/discover", controller.DiscoverUsers)
 		user.GET("/:id", controller.GetUserProfile)
-		user.POST("/:id", controller.UpdateProfile)
+		user.POST("/:id", self, controller.UpdateProfile)
+		user.PATCH("/:id", self, controller.PatchProfile)
 
 		// 个人标签
 		user.GET("/:id/tags", controller.GetTags)
-		user.POST("/:id/tags", controller.CreateTag)
-		user.DELETE("/:id/tags/:tag_id", controller.DeleteTag)
+		user.POST("/:id/tags", self, controller.CreateTag)
+		user.DELETE("/:id/tags/:tag_id", self, controller.DeleteTag)
+
+		// 个人照片和视频
+		user.POST("/:id/photos", self, controller.AddPhoto)
+		user.PUT("/:id/photos/order", self, controller.ReorderPhotos)
+		user.DELETE("/:id/photos", self, controller.RemovePhoto)
+		user.POST("/:id/videos", self, controller.AddVideo)
+		user.PUT("/:id/videos/order", self, controller.ReorderVideos)
+		user.PUT("/:id/videos/:video_id", self, controller.UpdateVideo)
+		user.DELETE("/:id/videos/:video_id", self, controller.RemoveVideo)
+		user.POST("/:id/videos/:video_id/unlock", controller.UnlockVideo)
+
+		// 关注
+		user.POST("/:id/follow", controller.FollowUser)
+		user.DELETE("/:id/follow", controller.UnfollowUser)
+
+		// 拉黑和屏蔽
+		user.POST("/:id/block", controller.BlockUser)
+		user.DELETE("/:id/block", controller.UnblockUser)
+		user.POST("/:id/mute", controller.MuteUser)
+		user.DELETE("/:id/mute", controller.UnmuteUser)
 
 		// 个人朋友圈
 		user.GET("/:id/moments", controller.GetUserMoments)
 
 		// 用户的收货地址操作
-		user.GET("/:id/addresses", controller.GetUserAddresses)
-		user.POST("/:id/address", controller.CreateAddress)
+		user.GET("/:id/addresses", self, controller.GetUserAddresses)
+		user.POST("/:id/address", self, controller.CreateAddress)
 		// user.PUT("/:id/address/:address_id")
-		user.DELETE("/:id/address/:address_id", controller.DeleteAddress)
+		user.DELETE("/:id/address/:address_id", self, controller.DeleteAddress)
 
 		// 提供的项目
 		user.GET("/:id/services", controller.GetUserServices)
 
-		// 用户充值/体现
-		user.POST("/:id/recharge", controller.UserRecharge)
+		// 充值只能由运营操作，用户自助充值需要接入支付回调
+		user.POST("/:id/recharge", middleware.RequireRole(model.RoleOperator), controller.UserRecharge)
+
+		// 角色管理
+		user.POST("/:id/role", middleware.RequireRole(model.RoleSuperAdmin), controller.SetUserRole)
 	}
 
 }
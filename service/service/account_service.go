@@ -0,0 +1,291 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrExportNotFound   = errors.New("export not found")
+	ErrExportNotReady   = errors.New("export is not ready")
+	ErrExportInProgress = errors.New("an export is already in progress")
+	ErrDeletionPending  = errors.New("account deletion already requested")
+	ErrDeletionNotFound = errors.New("no pending account deletion")
+	ErrOwnsGroups       = errors.New("transfer or dissolve your groups before deleting the account")
+)
+
+const (
+	defaultExportDir        = "data/exports"
+	exportTTL               = 7 * 24 * time.Hour
+	exportStaleAfter        = 30 * time.Minute // 超过这个时间仍未完成，视为生成过程中服务重启
+	defaultDeletionCoolDays = 15
+)
+
+func exportDir() string {
+	if dir := config.GetConf().Account.ExportDir; dir != "" {
+		return dir
+	}
+	return defaultExportDir
+}
+
+// 申请导出个人数据，后台生成 zip 文件，每个数据类别一个 JSON
+func RequestDataExport(uid uint) (*model.DataExport, error) {
+	exports, err := model.GetUserDataExports(uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exports {
+		if e.Status != model.ExportStatusPending {
+			continue
+		}
+		if time.Since(e.CreatedAt) < exportStaleAfter {
+			return nil, ErrExportInProgress
+		}
+		if err := model.UpdateDataExport(e.ID, map[string]interface{}{
+			"status": model.ExportStatusFailed,
+			"error":  "export interrupted",
+		}); err != nil {
+			return nil, err
+		}
+	}
+	export := &model.DataExport{
+		UserID: uid,
+		Status: model.ExportStatusPending,
+	}
+	if err := model.CreateDataExport(export); err != nil {
+		return nil, err
+	}
+	go buildDataExport(export)
+	return export, nil
+}
+
+func buildDataExport(export *model.DataExport) {
+	path, size, err := writeExportArchive(export)
+	if err != nil {
+		log.Printf("build data export %d failed: %v", export.ID, err)
+		model.UpdateDataExport(export.ID, map[string]interface{}{
+			"status": model.ExportStatusFailed,
+			"error":  err.Error(),
+		})
+		return
+	}
+	expiresAt := time.Now().Add(exportTTL)
+	err = model.UpdateDataExport(export.ID, map[string]interface{}{
+		"status":     model.ExportStatusReady,
+		"file_path":  path,
+		"size":       size,
+		"expires_at": &expiresAt,
+	})
+	if err != nil {
+		log.Printf("update data export %d failed: %v", export.ID, err)
+	}
+}
+
+func writeExportArchive(export *model.DataExport) (string, int64, error) {
+	data, err := model.CollectUserData(export.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	dir := exportDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d_%d.zip", export.UserID, export.ID))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, v := range data {
+		w, err := zw.Create(name + ".json")
+		if err != nil {
+			return "", 0, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
+
+func GetDataExports(uid uint) ([]model.DataExport, error) {
+	return model.GetUserDataExports(uid)
+}
+
+// 下载导出文件，只能下载自己的并且未过期的导出
+func GetDataExportFile(uid, exportID uint) (string, error) {
+	export, err := model.GetDataExport(exportID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && export.UserID != uid) {
+		return "", ErrExportNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if export.Status != model.ExportStatusReady || (export.ExpiresAt != nil && export.ExpiresAt.Before(time.Now())) {
+		return "", ErrExportNotReady
+	}
+	return export.FilePath, nil
+}
+
+func deletionCoolingOff() time.Duration {
+	days := intOr(config.GetConf().Account.DeletionCoolingDays, defaultDeletionCoolDays)
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// 申请注销账号，冷静期内可以撤销
+func RequestAccountDeletion(uid uint, reason string) (*model.AccountDeletion, error) {
+	if _, err := model.GetPendingDeletion(uid); err == nil {
+		return nil, ErrDeletionPending
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	owned, err := model.GetOwnedGroupIDs(uidString(uid))
+	if err != nil {
+		return nil, err
+	}
+	if len(owned) > 0 {
+		return nil, ErrOwnsGroups
+	}
+	deletion := &model.AccountDeletion{
+		UserID:      uid,
+		Status:      model.DeletionStatusPending,
+		Reason:      reason,
+		ScheduledAt: time.Now().Add(deletionCoolingOff()),
+	}
+	if err := model.CreateAccountDeletion(deletion); err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+func GetAccountDeletion(uid uint) (*model.AccountDeletion, error) {
+	deletion, err := model.GetPendingDeletion(uid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeletionNotFound
+	}
+	return deletion, err
+}
+
+func CancelAccountDeletion(uid uint) error {
+	deletion, err := GetAccountDeletion(uid)
+	if err != nil {
+		return err
+	}
+	return model.UpdateAccountDeletion(deletion.ID, map[string]interface{}{
+		"status": model.DeletionStatusCancelled,
+	})
+}
+
+// 执行冷静期已结束的注销申请
+func ProcessAccountDeletions() {
+	now := time.Now()
+	deletions, err := model.GetDueDeletions(now)
+	if err != nil {
+		log.Printf("query account deletions failed: %v", err)
+		return
+	}
+	for _, d := range deletions {
+		if err := deleteAccount(d.UserID, now); err != nil {
+			log.Printf("delete account %d failed: %v", d.UserID, err)
+			continue
+		}
+		err := model.UpdateAccountDeletion(d.ID, map[string]interface{}{
+			"status":       model.DeletionStatusCompleted,
+			"completed_at": &now,
+		})
+		if err != nil {
+			log.Printf("update account deletion %d failed: %v", d.ID, err)
+		}
+	}
+}
+
+func deleteAccount(uid uint, now time.Time) error {
+	// 冷静期内新建的群直接解散
+	owned, err := model.GetOwnedGroupIDs(uidString(uid))
+	if err != nil {
+		return err
+	}
+	for _, groupID := range owned {
+		if err := model.DissolveGroup(groupID); err != nil {
+			return err
+		}
+	}
+	// 先查出只属于该用户的文件，匿名化后上传记录会被清除
+	files, err := model.GetUserOnlyFiles(uid)
+	if err != nil {
+		return err
+	}
+	if err := model.AnonymizeUser(uid, now); err != nil {
+		return err
+	}
+	for i := range files {
+		if err := deleteStoredFile(&files[i]); err != nil {
+			log.Printf("delete file %d of deleted user %d failed: %v", files[i].ID, uid, err)
+		}
+	}
+	if err := model.DeleteUserSessions(uid); err != nil {
+		log.Printf("revoke sessions of deleted user %d failed: %v", uid, err)
+	}
+	// 已生成的导出文件一并删除
+	exports, err := model.GetUserDataExports(uid)
+	if err != nil {
+		return err
+	}
+	for _, e := range exports {
+		if e.FilePath != "" {
+			os.Remove(e.FilePath)
+		}
+	}
+	return nil
+}
+
+// 删除过期的导出文件和记录
+func CleanupExpiredExports() {
+	exports, err := model.GetExpiredDataExports(time.Now())
+	if err != nil {
+		log.Println("get expired exports error:", err)
+		return
+	}
+	for _, e := range exports {
+		if e.FilePath != "" {
+			if err := os.Remove(e.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("remove export %d file failed: %v", e.ID, err)
+				continue
+			}
+		}
+		if err := model.DeleteDataExport(e.ID); err != nil {
+			log.Printf("delete export %d failed: %v", e.ID, err)
+		}
+	}
+}
+
+// 后台定时执行到期的注销申请，并清理过期的导出
+func RunAccountDeletionWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ProcessAccountDeletions()
+		CleanupExpiredExports()
+	}
+}
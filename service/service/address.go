@@ -10,8 +10,8 @@ func CreateAddress(UserId uint, Name, Phone, Address string, IsDefault bool) (*m
 	return address, nil
 }
 
-func DeleteAddress(AddressId uint) error {
-	return model.DeleteAddress(AddressId)
+func DeleteAddress(UserId, AddressId uint) error {
+	return model.DeleteAddress(UserId, AddressId)
 }
 
 func GetUserAddresses(UserId uint) (map[string]interface{}, error) {
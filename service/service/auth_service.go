@@ -1,13 +1,16 @@
 package service
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"worldCity/config"
 	"worldCity/model"
 	"worldCity/utils"
+
+	"gorm.io/gorm"
 )
 
 type RegisterInfo struct {
@@ -15,84 +18,200 @@ type RegisterInfo struct {
 	Password string
 }
 
-func buildCodeKey(phone string) string {
-	return fmt.Sprintf("verify:%s", phone)
+var (
+	ErrUserExists        = errors.New("user already exists")
+	ErrUserNotRegistered = errors.New("user not registered")
+	ErrLoginFailed       = errors.New("incorrect username or password")
+	ErrAccountLocked     = errors.New("account temporarily locked, try again later")
+	ErrPasswordTooLong   = fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+)
+
+const (
+	defaultLoginMaxFailures  = 5
+	defaultLoginLockDuration = 15 * time.Minute
+	// bcrypt 只使用前 72 字节，更长的密码直接拒绝
+	maxPasswordBytes = 72
+)
+
+// 设置或修改密码前检查长度并哈希
+func hashNewPassword(password string) (string, error) {
+	if len(password) > maxPasswordBytes {
+		return "", ErrPasswordTooLong
+	}
+	return utils.HashPassword(password)
 }
 
-func Register(phone, password string) (map[string]interface{}, error) {
+// 注册需要先通过短信验证拿到注册凭证
+func Register(phone, password, ticket string, client ClientInfo) (map[string]interface{}, error) {
+	hash, err := hashNewPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRegisterTicket(phone, ticket); err != nil {
+		return nil, err
+	}
 	// 检查是否存在
 	ok, err := model.IsUserExisted(phone)
 	if err != nil {
 		return nil, err
 	}
 	if ok {
-		return nil, errors.New("user already exists")
+		return nil, ErrUserExists
 	}
 
-	// 创建云信用户（后面补充）
-	// yunxin.RegisterUser(req.Accid)
-	accId := phone
-
-	newUser, err := model.CreateUser(&model.User{
-		AccId:    accId,
+	// 云信账号由 UserRegistered 的处理器创建
+	newUser := &model.User{
+		AccId:    utils.GenerateAccId(),
 		Name:     phone,
-		Password: utils.HashPassword(password), // demo版直接存密码（正式需要加密）
+		Password: hash,
+		Role:     model.RoleUser,
+	}
+	err = withEvents(func(tx *gorm.DB) ([]Event, error) {
+		if _, err := model.CreateUser(tx, newUser); err != nil {
+			return nil, err
+		}
+		return []Event{&UserRegistered{UserID: newUser.ID, Method: "phone"}}, nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	consumeRegisterTicket(ticket)
 
-	token, _ := utils.GenerateToken(uint64(newUser.ID), accId)
+	return createSession(newUser, client)
+}
+
+// ClientInfo 请求来源，用于登录审计和设备会话
+type ClientInfo struct {
+	IP         string
+	UserAgent  string
+	DeviceID   string
+	DeviceName string
+}
+
+// 写入登录审计记录，失败只打日志不影响登录
+func recordLoginEvent(user *model.User, account, method, result, reason string, client ClientInfo) {
+	event := &model.LoginEvent{
+		Account:   account,
+		Method:    method,
+		Result:    result,
+		Reason:    reason,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	go func() {
+		if err := model.CreateLoginEvent(event); err != nil {
+			log.Printf("record login event for %s failed: %v", account, err)
+		}
+	}()
+}
+
+func buildLoginFailKey(uid uint) string {
+	return fmt.Sprintf("login_fail:%d", uid)
+}
+
+func buildLoginLockKey(uid uint) string {
+	return fmt.Sprintf("login_lock:%d", uid)
+}
+
+// 账号是否因为连续输错密码被锁定
+func isLoginLocked(uid uint) (bool, error) {
+	n, err := model.GetRds().Exists(model.Ctx, buildLoginLockKey(uid)).Result()
+	return n > 0, err
+}
 
-	return map[string]interface{}{
-		"token": token,
-	}, nil
+// 记录一次密码错误，达到上限后锁定账号，返回是否已锁定
+func recordLoginFailure(uid uint) bool {
+	loginConf := config.GetConf().Login
+	lockDuration := secondsOr(loginConf.LockDuration, defaultLoginLockDuration)
+	rds := model.GetRds()
+	n, err := incrWindow(rds, buildLoginFailKey(uid), lockDuration)
+	if err != nil {
+		log.Printf("record login failure for %d failed: %v", uid, err)
+		return false
+	}
+	if n < int64(intOr(loginConf.MaxFailures, defaultLoginMaxFailures)) {
+		return false
+	}
+	rds.Set(model.Ctx, buildLoginLockKey(uid), 1, lockDuration)
+	rds.Del(model.Ctx, buildLoginFailKey(uid))
+	return true
 }
 
-func Login(name, password string) (map[string]interface{}, error) {
+// 账号密码登录，连续输错会临时锁定账号
+func Login(name, password string, client ClientInfo) (map[string]interface{}, error) {
 	user, err := model.GetUserByName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		recordLoginEvent(nil, name, model.LoginMethodPassword, model.LoginResultFailed, "user not found", client)
+		return nil, ErrLoginFailed
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if user.Password != password {
-		return nil, errors.New("incorrect password")
+	locked, err := isLoginLocked(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		recordLoginEvent(user, name, model.LoginMethodPassword, model.LoginResultLocked, "account locked", client)
+		return nil, ErrAccountLocked
 	}
 
-	token, _ := utils.GenerateToken(uint64(user.ID), user.AccId)
+	if !utils.CheckPassword(user.Password, password) {
+		if recordLoginFailure(user.ID) {
+			recordLoginEvent(user, name, model.LoginMethodPassword, model.LoginResultLocked, "too many failures", client)
+			return nil, ErrAccountLocked
+		}
+		recordLoginEvent(user, name, model.LoginMethodPassword, model.LoginResultFailed, "incorrect password", client)
+		return nil, ErrLoginFailed
+	}
+	model.GetRds().Del(model.Ctx, buildLoginFailKey(user.ID))
+
+	// 旧 cost 的密码在登录成功后重新哈希
+	if utils.PasswordNeedsRehash(user.Password) {
+		hash, err := utils.HashPassword(password)
+		if err == nil {
+			err = model.UpdateUserPassword(user.ID, hash)
+		}
+		if err != nil {
+			log.Printf("rehash password for %d failed: %v", user.ID, err)
+		}
+	}
 
-	return map[string]interface{}{
-		"token": token,
-	}, nil
+	res, err := createSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	recordLoginEvent(user, name, model.LoginMethodPassword, model.LoginResultSuccess, "", client)
+	return res, nil
 }
 
-func SendCode(phone string) (map[string]interface{}, error) {
-	// 检查是否存在
-	ok, err := model.IsUserExisted(phone)
+// 短信验证码登录
+func SmsLogin(phone, code string, client ClientInfo) (map[string]interface{}, error) {
+	user, err := model.GetUserByName(phone)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		recordLoginEvent(nil, phone, model.LoginMethodSms, model.LoginResultFailed, "user not found", client)
+		return nil, ErrUserNotRegistered
+	}
 	if err != nil {
 		return nil, err
 	}
-	if ok {
-		return nil, errors.New("user already exists")
+	if err := checkVerifyCode(SmsSceneLogin, phone, code); err != nil {
+		result := model.LoginResultFailed
+		if errors.Is(err, ErrCodeLocked) {
+			result = model.LoginResultLocked
+		}
+		recordLoginEvent(user, phone, model.LoginMethodSms, result, err.Error(), client)
+		return nil, err
 	}
 
-	// 生成一个验证码，并发送给手机号,并保存在redis中
-	// code := fmt.Sprintf("%06d", rand.Intn(1000000))
-	code := "123456"
-	rds := model.GetRds()
-	rds.SetEx(context.Background(), buildCodeKey(phone), code, time.Minute*10)
-
-	// 推送给手机号
-
-	return nil, nil
-}
-
-func VerifyCode(phone, code string) (map[string]interface{}, error) {
-	// 从redis查询对应验证码
-	rds := model.GetRds()
-	result := rds.Get(context.Background(), buildCodeKey(phone))
-	if result.Err() != nil {
-		return nil, result.Err()
+	res, err := createSession(user, client)
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil
+	recordLoginEvent(user, phone, model.LoginMethodSms, model.LoginResultSuccess, "", client)
+	return res, nil
 }
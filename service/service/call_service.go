@@ -0,0 +1,370 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCallNotFound          = errors.New("call not found")
+	ErrCallBusy              = errors.New("user is busy in another call")
+	ErrCallPermission        = errors.New("not a participant of this call")
+	ErrCallSelf              = errors.New("cannot call yourself")
+	ErrCallInvalidMedia      = errors.New("invalid call media type")
+	ErrCallInvalidState      = errors.New("call is not in a valid state for this operation")
+	ErrCallInsufficientCoins = errors.New("insufficient coins for this call")
+	ErrCallInvalidSignal     = errors.New("invalid signal")
+)
+
+// 通话事件，通过投递通道推送给对方
+const (
+	CallEventInvite    = "call_invite"
+	CallEventAccepted  = "call_accepted"
+	CallEventRejected  = "call_rejected"
+	CallEventCancelled = "call_cancelled"
+	CallEventMissed    = "call_missed"
+	CallEventEnded     = "call_ended"
+	CallEventSignal    = "call_signal"
+)
+
+const (
+	defaultRingTimeout = 60 * time.Second
+	defaultMaxDuration = 4 * time.Hour
+	maxSignalSize      = 64 << 10
+	callScope          = "call"
+)
+
+// 信令类型，内容由客户端自行解析，服务端只做转发
+var signalTypes = []string{"offer", "answer", "candidate"}
+
+func ringTimeout() time.Duration {
+	if seconds := config.GetConf().Call.RingTimeout; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultRingTimeout
+}
+
+func callMaxDuration() time.Duration {
+	return secondsOr(config.GetConf().Call.MaxDuration, defaultMaxDuration)
+}
+
+func loadCall(uid uint, callID string) (*model.CallSession, error) {
+	call, err := model.GetCallSession(callID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCallNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if call.CallerID != uid && call.CalleeID != uid {
+		return nil, ErrCallPermission
+	}
+	return call, nil
+}
+
+// 通话另一方
+func callPeer(call *model.CallSession, uid uint) uint {
+	if call.CallerID == uid {
+		return call.CalleeID
+	}
+	return call.CallerID
+}
+
+func notifyCall(fromID, toID uint, event string, call *model.CallSession, data interface{}) {
+	notifyAsync(fromID, []uint{toID}, &ChatEvent{
+		Event:      event,
+		Scope:      callScope,
+		CallID:     call.CallID,
+		OperatorID: fromID,
+		Message:    call,
+		Data:       data,
+	})
+}
+
+// 发起通话，offer 为主叫的 SDP，原样转发给被叫
+func StartCall(callerID, calleeID uint, mediaType string, offer json.RawMessage) (*model.CallSession, error) {
+	if callerID == calleeID {
+		return nil, ErrCallSelf
+	}
+	if mediaType != model.CallMediaAudio && mediaType != model.CallMediaVideo {
+		return nil, ErrCallInvalidMedia
+	}
+	if len(offer) > maxSignalSize {
+		return nil, ErrCallInvalidSignal
+	}
+	if err := checkNotBlocked(callerID, calleeID); err != nil {
+		return nil, err
+	}
+	caller, err := model.GetUserById(callerID)
+	if err != nil {
+		return nil, err
+	}
+	callee, err := model.GetUserById(calleeID)
+	if err != nil {
+		return nil, err
+	}
+	if callee.CallPrice > 0 && caller.Coins < callee.CallPrice {
+		return nil, ErrCallInsufficientCoins
+	}
+	for _, uid := range []uint{callerID, calleeID} {
+		busy, err := model.HasActiveCall(uid)
+		if err != nil {
+			return nil, err
+		}
+		if busy {
+			return nil, ErrCallBusy
+		}
+	}
+
+	call := &model.CallSession{
+		CallID:    utils.RandomString(24),
+		CallerID:  callerID,
+		CalleeID:  calleeID,
+		MediaType: mediaType,
+		Status:    model.CallStatusRinging,
+		Price:     callee.CallPrice,
+	}
+	if err := model.CreateCallSession(call); err != nil {
+		return nil, err
+	}
+	notifyCall(callerID, calleeID, CallEventInvite, call, offer)
+	return call, nil
+}
+
+// 被叫接听，answer 为被叫的 SDP
+func AcceptCall(uid uint, callID string, answer json.RawMessage) (map[string]interface{}, error) {
+	call, err := loadCall(uid, callID)
+	if err != nil {
+		return nil, err
+	}
+	if call.CalleeID != uid {
+		return nil, ErrCallPermission
+	}
+	if len(answer) > maxSignalSize {
+		return nil, ErrCallInvalidSignal
+	}
+	// 付费通话按主叫当前余额计算最长可通话时间，超时后由服务端结束
+	var maxSeconds uint
+	if call.Price > 0 {
+		caller, err := model.GetUserById(call.CallerID)
+		if err != nil {
+			return nil, err
+		}
+		maxSeconds = caller.Coins / call.Price * 60
+	}
+	now := time.Now()
+	err = model.TransitCallSession(callID, model.CallStatusRinging, map[string]interface{}{
+		"status":      model.CallStatusAccepted,
+		"accepted_at": &now,
+		"max_seconds": maxSeconds,
+	})
+	if errors.Is(err, model.ErrCallStateChanged) {
+		return nil, ErrCallInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+	call.Status = model.CallStatusAccepted
+	call.AcceptedAt = &now
+	call.MaxSeconds = maxSeconds
+
+	notifyCall(uid, call.CallerID, CallEventAccepted, call, answer)
+	return map[string]interface{}{
+		"call":        call,
+		"max_seconds": maxSeconds,
+	}, nil
+}
+
+// 结束一个未接通的通话：被叫拒接、主叫取消或超时未接
+func closeRingingCall(call *model.CallSession, operatorID uint, status, event string) error {
+	now := time.Now()
+	err := model.TransitCallSession(call.CallID, model.CallStatusRinging, map[string]interface{}{
+		"status":   status,
+		"ended_at": &now,
+	})
+	if errors.Is(err, model.ErrCallStateChanged) {
+		return ErrCallInvalidState
+	}
+	if err != nil {
+		return err
+	}
+	call.Status = status
+	call.EndedAt = &now
+
+	notifyCall(operatorID, callPeer(call, operatorID), event, call, nil)
+	if event == CallEventMissed {
+		// 超时由服务端发起，被叫的来电界面也需要关闭
+		notifyCall(call.CallerID, call.CalleeID, event, call, nil)
+	}
+	writeCallRecord(call)
+	return nil
+}
+
+func RejectCall(uid uint, callID string) error {
+	call, err := loadCall(uid, callID)
+	if err != nil {
+		return err
+	}
+	if call.CalleeID != uid {
+		return ErrCallPermission
+	}
+	return closeRingingCall(call, uid, model.CallStatusRejected, CallEventRejected)
+}
+
+func CancelCall(uid uint, callID string) error {
+	call, err := loadCall(uid, callID)
+	if err != nil {
+		return err
+	}
+	if call.CallerID != uid {
+		return ErrCallPermission
+	}
+	return closeRingingCall(call, uid, model.CallStatusCancelled, CallEventCancelled)
+}
+
+// 挂断已接通的通话，并按时长结算
+func HangupCall(uid uint, callID string) (*model.CallSession, error) {
+	call, err := loadCall(uid, callID)
+	if err != nil {
+		return nil, err
+	}
+	if err := endAcceptedCall(call, time.Now()); err != nil {
+		return nil, err
+	}
+	notifyCall(uid, callPeer(call, uid), CallEventEnded, call, nil)
+	writeCallRecord(call)
+	return call, nil
+}
+
+// 结束已接通的通话并结算，时长不超过通话允许的最长时长
+func endAcceptedCall(call *model.CallSession, now time.Time) error {
+	if call.Status != model.CallStatusAccepted || call.AcceptedAt == nil {
+		return ErrCallInvalidState
+	}
+	limit := callMaxDuration()
+	if call.Price > 0 {
+		limit = min(limit, time.Duration(call.MaxSeconds)*time.Second)
+	}
+	duration := uint(min(now.Sub(*call.AcceptedAt), limit).Seconds())
+	// 不足一分钟按一分钟计费
+	minutes := (duration + 59) / 60
+	charged, err := model.EndCallSession(call, now, duration, minutes*call.Price)
+	if errors.Is(err, model.ErrCallStateChanged) {
+		return ErrCallInvalidState
+	}
+	if err != nil {
+		return err
+	}
+	call.Status = model.CallStatusEnded
+	call.EndedAt = &now
+	call.Duration = duration
+	call.Coins = charged
+	return nil
+}
+
+// 转发 SDP/ICE 等信令给对方
+func RelayCallSignal(uid uint, callID, signalType string, payload json.RawMessage) error {
+	if !slices.Contains(signalTypes, signalType) || len(payload) == 0 || len(payload) > maxSignalSize {
+		return ErrCallInvalidSignal
+	}
+	call, err := loadCall(uid, callID)
+	if err != nil {
+		return err
+	}
+	if !call.IsActive() {
+		return ErrCallInvalidState
+	}
+	notifyCall(uid, callPeer(call, uid), CallEventSignal, call, map[string]interface{}{
+		"type":    signalType,
+		"payload": payload,
+	})
+	return nil
+}
+
+func GetCallSession(uid uint, callID string) (*model.CallSession, error) {
+	return loadCall(uid, callID)
+}
+
+// 通话结果写入双方的聊天记录
+func writeCallRecord(call *model.CallSession) {
+	payload := &model.CallPayload{
+		CallID:    call.CallID,
+		MediaType: call.MediaType,
+		Status:    call.Status,
+		Duration:  call.Duration,
+		Coins:     call.Coins,
+	}
+	content, err := model.EncodeMessagePayload(payload)
+	if err != nil {
+		log.Printf("encode call record %s failed: %v", call.CallID, err)
+		return
+	}
+	msg := &model.Message{
+		SessionID:   model.BuildSessionID(call.CallerID, call.CalleeID),
+		SenderID:    call.CallerID,
+		ReceiverID:  call.CalleeID,
+		ContentType: payload.Type(),
+		Content:     content,
+		Timestamp:   uint(time.Now().Unix()),
+	}
+	if err := model.CreateMessage(msg); err != nil {
+		log.Printf("save call record %s failed: %v", call.CallID, err)
+		return
+	}
+	deliverAsync(call.CallerID, call.CalleeID, payload)
+}
+
+// 将超时未接听的通话标记为未接
+func ExpireRingingCalls() {
+	calls, err := model.GetExpiredRingingCalls(time.Now().Add(-ringTimeout()))
+	if err != nil {
+		log.Printf("query ringing calls failed: %v", err)
+		return
+	}
+	for i := range calls {
+		err := closeRingingCall(&calls[i], calls[i].CalleeID, model.CallStatusMissed, CallEventMissed)
+		if err != nil && !errors.Is(err, ErrCallInvalidState) {
+			log.Printf("expire call %s failed: %v", calls[i].CallID, err)
+		}
+	}
+}
+
+// 结束超过最长时长的通话，客户端异常退出没有挂断时也能结算并释放双方的占线状态
+func EndOverdueCalls() {
+	now := time.Now()
+	calls, err := model.GetOverdueAcceptedCalls(now, callMaxDuration())
+	if err != nil {
+		log.Printf("query overdue calls failed: %v", err)
+		return
+	}
+	for i := range calls {
+		call := &calls[i]
+		if err := endAcceptedCall(call, now); err != nil {
+			if !errors.Is(err, ErrCallInvalidState) {
+				log.Printf("end overdue call %s failed: %v", call.CallID, err)
+			}
+			continue
+		}
+		notifyCall(0, call.CallerID, CallEventEnded, call, nil)
+		notifyCall(0, call.CalleeID, CallEventEnded, call, nil)
+		writeCallRecord(call)
+	}
+}
+
+// 后台定时检查未接来电和超时的通话，多实例同时运行时依靠状态条件更新避免重复处理
+func RunCallWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ExpireRingingCalls()
+		EndOverdueCalls()
+	}
+}
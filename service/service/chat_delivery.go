@@ -0,0 +1,102 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/utils"
+)
+
+// 聊天事件类型
+const (
+	ChatEventRecalled = "message_recalled"
+	ChatEventEdited   = "message_edited"
+	ChatEventDeleted  = "message_deleted" // 仅自己删除，同步到自己的其他设备
+)
+
+// ChatEvent 通过投递通道同步给在线端的事件
+type ChatEvent struct {
+	Event      string      `json:"event"`
+	Scope      string      `json:"scope"` // p2p, group, call
+	MessageID  uint        `json:"message_id,omitempty"`
+	GroupID    string      `json:"group_id,omitempty"`
+	CallID     string      `json:"call_id,omitempty"`
+	OperatorID uint        `json:"operator_id"`
+	Message    interface{} `json:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// MessageDeliverer 聊天消息的投递通道，默认使用云信
+type MessageDeliverer interface {
+	Deliver(fromID, toID uint, payload model.MessagePayload) error
+	Notify(fromID, toID uint, event *ChatEvent) error
+}
+
+type yunxinDeliverer struct{}
+
+// 云信使用 accid 标识用户，和用户 ID 不同，fromID 为 0 表示系统
+func yunxinAccIds(fromID, toID uint) (string, string, error) {
+	accIds, err := model.GetAccIds(fromID, toID)
+	if err != nil {
+		return "", "", err
+	}
+	if fromID == 0 {
+		accIds[0] = config.GetConf().Yunxin.SystemAccId
+	}
+	from, ok := accIds[fromID]
+	if !ok {
+		return "", "", fmt.Errorf("user %d has no yunxin account", fromID)
+	}
+	to, ok := accIds[toID]
+	if !ok {
+		return "", "", fmt.Errorf("user %d has no yunxin account", toID)
+	}
+	return from, to, nil
+}
+
+func (yunxinDeliverer) Deliver(fromID, toID uint, payload model.MessagePayload) error {
+	from, to, err := yunxinAccIds(fromID, toID)
+	if err != nil {
+		return err
+	}
+	return utils.SendYunxinMsg(from, to, payload)
+}
+
+func (yunxinDeliverer) Notify(fromID, toID uint, event *ChatEvent) error {
+	attach, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	from, to, err := yunxinAccIds(fromID, toID)
+	if err != nil {
+		return err
+	}
+	return utils.SendYunxinAttachMsg(from, to, string(attach))
+}
+
+var deliverer MessageDeliverer = yunxinDeliverer{}
+
+// SetMessageDeliverer 替换投递通道，例如本地调试时不接入云信
+func SetMessageDeliverer(d MessageDeliverer) {
+	deliverer = d
+}
+
+func deliverAsync(fromID, toID uint, payload model.MessagePayload) {
+	go func() {
+		if err := deliverer.Deliver(fromID, toID, payload); err != nil {
+			log.Printf("deliver message from %d to %d failed: %v", fromID, toID, err)
+		}
+	}()
+}
+
+func notifyAsync(fromID uint, toIDs []uint, event *ChatEvent) {
+	go func() {
+		for _, toID := range toIDs {
+			if err := deliverer.Notify(fromID, toID, event); err != nil {
+				log.Printf("notify %s to %d failed: %v", event.Event, toID, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"time"
+	"worldCity/model"
+)
+
+var ErrInvalidDiscoverQuery = errors.New("invalid discover query")
+
+const (
+	defaultDiscoverPageSize = 20
+	maxDiscoverPageSize     = 50
+	maxDiscoverOnline       = 1000 // 参与筛选和排序的在线用户上限
+)
+
+// DiscoverQuery 发现用户的筛选条件，零值表示不筛选
+type DiscoverQuery struct {
+	Gender      *uint
+	MinAge      int
+	MaxAge      int
+	Tags        []string
+	Online      bool
+	HasServices bool
+	Sort        string // recommend, newest
+	Page        int
+	PageSize    int
+}
+
+// DiscoverUser 发现列表中的用户
+type DiscoverUser struct {
+	*PublicProfile
+	Online bool `json:"online"`
+}
+
+// 发现用户，排除自己和存在拉黑关系的用户
+func DiscoverUsers(viewerID uint, q *DiscoverQuery) (map[string]interface{}, error) {
+	if q.Sort == "" {
+		q.Sort = model.UserSortRecommend
+	}
+	if q.Sort != model.UserSortRecommend && q.Sort != model.UserSortNewest {
+		return nil, ErrInvalidDiscoverQuery
+	}
+	if q.MinAge < 0 || q.MaxAge < 0 || (q.MaxAge > 0 && q.MinAge > q.MaxAge) {
+		return nil, ErrInvalidDiscoverQuery
+	}
+	q.Page, q.PageSize = pageOr(q.Page, q.PageSize, defaultDiscoverPageSize, maxDiscoverPageSize)
+
+	exclude, err := model.GetBlockRelatedUserIDs(viewerID)
+	if err != nil {
+		return nil, err
+	}
+	filter := &model.UserFilter{
+		ExcludeIDs:  append(exclude, viewerID),
+		Gender:      q.Gender,
+		Tags:        q.Tags,
+		OnlineOnly:  q.Online,
+		HasServices: q.HasServices,
+		Sort:        q.Sort,
+		Offset:      (q.Page - 1) * q.PageSize,
+		Limit:       q.PageSize,
+	}
+	// 年龄换算为生日范围
+	now := time.Now()
+	if q.MinAge > 0 {
+		t := now.AddDate(-q.MinAge, 0, 0)
+		filter.BornBefore = &t
+	}
+	if q.MaxAge > 0 {
+		t := now.AddDate(-q.MaxAge-1, 0, 0)
+		filter.BornAfter = &t
+	}
+	// 只取最近活跃的一部分在线用户参与筛选和排序，避免查询条件过大
+	online, err := model.GetOnlineUserIDs(maxDiscoverOnline)
+	if err != nil {
+		return nil, err
+	}
+	filter.OnlineIDs = online
+
+	users, total, err := model.SearchUsers(filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(users))
+	for i := range users {
+		ids[i] = users[i].ID
+	}
+	online, err = model.FilterOnline(ids)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]DiscoverUser, 0, len(users))
+	for i := range users {
+		videos, err := visibleVideos(viewerID, &users[i])
+		if err != nil {
+			return nil, err
+		}
+		p := toPublicProfile(&users[i], videos)
+		list = append(list, DiscoverUser{PublicProfile: &p, Online: slices.Contains(online, users[i].ID)})
+	}
+	return map[string]interface{}{
+		"total":     total,
+		"page":      q.Page,
+		"page_size": q.PageSize,
+		"users":     list,
+	}, nil
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/utils"
+
+	"gorm.io/gorm"
+)
+
+// Event 领域事件，EventType 为写入 outbox 的类型名
+type Event interface {
+	EventType() string
+}
+
+// 领域事件的类型名
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderCancelled = "order.cancelled"
+	EventMomentLiked    = "moment.liked"
+	EventMessageSent    = "message.sent"
+	EventUserRegistered = "user.registered"
+)
+
+type OrderCreated struct {
+	OrderID        uint              `json:"order_id"`
+	OrderNo        string            `json:"order_no"`
+	UserID         uint              `json:"user_id"`          // 下单用户
+	ProviderUserID uint              `json:"provider_user_id"` // 服务者
+	Status         model.OrderStatus `json:"status"`
+}
+
+type OrderCancelled struct {
+	OrderID        uint            `json:"order_id"`
+	OrderNo        string          `json:"order_no"`
+	UserID         uint            `json:"user_id"`
+	ProviderUserID uint            `json:"provider_user_id"`
+	Reason         json.RawMessage `json:"reason"`
+}
+
+type MomentLiked struct {
+	MomentID uint      `json:"moment_id"`
+	OwnerID  uint      `json:"owner_id"`
+	UserID   uint      `json:"user_id"`
+	LikedAt  time.Time `json:"liked_at"`
+}
+
+type MessageSent struct {
+	MessageID  uint   `json:"message_id"`
+	SenderID   uint   `json:"sender_id"`
+	ReceiverID uint   `json:"receiver_id"`
+	Type       string `json:"type"`
+}
+
+type UserRegistered struct {
+	UserID uint   `json:"user_id"`
+	Method string `json:"method"` // phone 或第三方身份提供方的名称
+}
+
+func (*OrderCreated) EventType() string   { return EventOrderCreated }
+func (*OrderCancelled) EventType() string { return EventOrderCancelled }
+func (*MomentLiked) EventType() string    { return EventMomentLiked }
+func (*MessageSent) EventType() string    { return EventMessageSent }
+func (*UserRegistered) EventType() string { return EventUserRegistered }
+
+// 从 outbox 解码事件时按类型名创建
+var eventFactories = map[string]func() Event{
+	EventOrderCreated:   func() Event { return &OrderCreated{} },
+	EventOrderCancelled: func() Event { return &OrderCancelled{} },
+	EventMomentLiked:    func() Event { return &MomentLiked{} },
+	EventMessageSent:    func() Event { return &MessageSent{} },
+	EventUserRegistered: func() Event { return &UserRegistered{} },
+}
+
+type eventHandler struct {
+	name string
+	fn   func(uint, Event) error
+}
+
+var eventHandlers = map[string][]eventHandler{}
+
+// Subscribe 注册事件处理器，name 在同一事件中唯一，用于记录处理进度
+// 事件至少投递一次，处理器失败后会重试，已成功的处理器通常不会重复执行，
+// 但处理超时被其他消费者接手时可能重复，处理器需要能够重复执行
+func Subscribe[T Event](name string, fn func(T) error) {
+	SubscribeWithID(name, func(_ uint, e T) error { return fn(e) })
+}
+
+// SubscribeWithID 处理器需要按事件 ID 去重时使用，例如计数和创建通知
+func SubscribeWithID[T Event](name string, fn func(eventID uint, e T) error) {
+	var zero T
+	eventType := zero.EventType()
+	for _, h := range eventHandlers[eventType] {
+		if h.name == name {
+			panic(fmt.Sprintf("event handler %s for %s already registered", name, eventType))
+		}
+	}
+	eventHandlers[eventType] = append(eventHandlers[eventType], eventHandler{
+		name: name,
+		fn:   func(id uint, e Event) error { return fn(id, e.(T)) },
+	})
+}
+
+// 在同一事务中执行写操作并把返回的事件写入 outbox，提交后唤醒投递任务
+func withEvents(fn func(tx *gorm.DB) ([]Event, error)) error {
+	err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+		events, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		records := make([]model.OutboxEvent, 0, len(events))
+		for _, e := range events {
+			payload, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			records = append(records, model.OutboxEvent{
+				Type:          e.EventType(),
+				Payload:       payload,
+				Status:        model.OutboxStatusPending,
+				NextAttemptAt: time.Now(),
+			})
+		}
+		return model.AddOutboxEvents(tx, records)
+	})
+	if err == nil {
+		wakeEventRelay()
+	}
+	return err
+}
+
+const (
+	eventRelayBatch = 100
+	// 认领后多久没有完成视为处理中断，重新投递
+	eventLeaseTime      = 2 * time.Minute
+	eventRetryBaseDelay = 5 * time.Second
+	eventRetryMaxDelay  = time.Hour
+)
+
+var eventRelayWake = make(chan struct{}, 1)
+
+func wakeEventRelay() {
+	select {
+	case eventRelayWake <- struct{}{}:
+	default:
+	}
+}
+
+func eventMaxAttempts() int {
+	return intOr(config.GetConf().Event.MaxAttempts, 10)
+}
+
+// 第 n 次失败后的等待时间，指数退避
+func eventRetryDelay(attempts int) time.Duration {
+	delay := eventRetryBaseDelay << min(attempts-1, 20)
+	return min(delay, eventRetryMaxDelay)
+}
+
+// 执行事件尚未成功的处理器并更新 outbox 状态
+// 只有 outbox 本身读写失败时返回错误，处理器失败通过重试解决
+func handleOutboxEvent(id uint) error {
+	// 先认领租约，同一事件被重复投递时只有一个消费者执行
+	owner := utils.RandomString(16)
+	leased, err := model.LeaseOutboxEvent(id, owner, time.Now(), eventLeaseTime)
+	if err != nil || !leased {
+		return err
+	}
+	rec, err := model.GetOutboxEventById(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	newEvent, ok := eventFactories[rec.Type]
+	if !ok {
+		return updateLeasedEvent(rec, owner, map[string]interface{}{
+			"status":     model.OutboxStatusFailed,
+			"last_error": "unknown event type",
+		})
+	}
+	event := newEvent()
+	if err := json.Unmarshal(rec.Payload, event); err != nil {
+		return updateLeasedEvent(rec, owner, map[string]interface{}{
+			"status":     model.OutboxStatusFailed,
+			"last_error": "decode payload: " + err.Error(),
+		})
+	}
+
+	done := rec.Done
+	var errs []string
+	for _, h := range eventHandlers[rec.Type] {
+		if slices.Contains(done, h.name) {
+			continue
+		}
+		if err := runEventHandler(h, rec.ID, event); err != nil {
+			errs = append(errs, h.name+": "+err.Error())
+			continue
+		}
+		done = append(done, h.name)
+	}
+
+	// map 更新不经过 serializer，手动编码
+	doneJSON, err := json.Marshal(done)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if len(errs) == 0 {
+		return updateLeasedEvent(rec, owner, map[string]interface{}{
+			"status":        model.OutboxStatusDispatched,
+			"done":          string(doneJSON),
+			"dispatched_at": &now,
+		})
+	}
+	attempts := rec.Attempts + 1
+	updates := map[string]interface{}{
+		"status":          model.OutboxStatusPending,
+		"done":            string(doneJSON),
+		"attempts":        attempts,
+		"next_attempt_at": now.Add(eventRetryDelay(attempts)),
+		"last_error":      truncateString(strings.Join(errs, "; "), 1000),
+	}
+	if attempts >= eventMaxAttempts() {
+		updates["status"] = model.OutboxStatusFailed
+		log.Printf("event %d %s failed after %d attempts: %s", rec.ID, rec.Type, attempts, updates["last_error"])
+	}
+	return updateLeasedEvent(rec, owner, updates)
+}
+
+// 租约已被其他消费者接手时放弃本次结果，只记录日志
+func updateLeasedEvent(rec *model.OutboxEvent, owner string, updates map[string]interface{}) error {
+	ok, err := model.UpdateLeasedOutboxEvent(rec.ID, owner, updates)
+	if err == nil && !ok {
+		log.Printf("event %d %s lease lost before update", rec.ID, rec.Type)
+	}
+	return err
+}
+
+// 处理器 panic 视为失败，不影响其他处理器
+func runEventHandler(h eventHandler, eventID uint, e Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h.fn(eventID, e)
+}
+
+func truncateString(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// RunEventRelay 认领到期的 outbox 事件交给传输层，新事件提交后立即唤醒，同时定时扫描重试和中断的事件
+func RunEventRelay(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-eventRelayWake:
+		case <-ticker.C:
+		case <-cleanup.C:
+			cleanupOutbox()
+			continue
+		}
+		for {
+			events, err := model.ClaimOutboxEvents(time.Now(), eventLeaseTime, eventRelayBatch)
+			if err != nil {
+				log.Println("event relay claim error:", err)
+				break
+			}
+			for i := range events {
+				if err := eventTransport.Publish(&events[i]); err != nil {
+					log.Printf("event relay publish %d error: %v", events[i].ID, err)
+				}
+			}
+			if len(events) < eventRelayBatch {
+				break
+			}
+		}
+	}
+}
+
+func cleanupOutbox() {
+	days := intOr(config.GetConf().Event.Retention, 7)
+	n, err := model.DeleteDispatchedOutboxEvents(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Println("outbox cleanup error:", err)
+	} else if n > 0 {
+		log.Printf("outbox cleanup: %d dispatched events removed", n)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"worldCity/model"
+	"worldCity/utils"
+
+	"gorm.io/gorm"
+)
+
+// 按事件去重的标记保留时间，超过重试的时间跨度即可
+const eventDedupTTL = 7 * 24 * time.Hour
+
+// 跨模块的副作用都通过事件处理器完成，处理器失败会重试，需要能够重复执行
+func registerEventHandlers() {
+	SubscribeWithID("notify_provider", func(id uint, e *OrderCreated) error {
+		return notifyOrderByNo(e.UserID, e.ProviderUserID, e.OrderNo, OrderEventCreated, notificationEventKey(id, "notify_provider"))
+	})
+	SubscribeWithID("notify_provider", func(id uint, e *OrderCancelled) error {
+		return notifyOrderByNo(e.UserID, e.ProviderUserID, e.OrderNo, OrderEventCancelled, notificationEventKey(id, "notify_provider"))
+	})
+	Subscribe("notify_owner", func(e *MomentLiked) error {
+		moment, err := model.GetMomentById(e.MomentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return notifyMomentLiked(e.UserID, moment, e.LikedAt)
+	})
+	Subscribe("topic_trending", func(e *MomentLiked) error {
+		moment, err := model.GetMomentById(e.MomentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return recordTopicEngagement(moment, topicWeightLike)
+	})
+	SubscribeWithID("unread_count", func(id uint, e *MessageSent) error {
+		return model.IncrOnce(fmt.Sprintf("event:%d:unread_count", id), utils.GetUnreadKey(e.ReceiverID), eventDedupTTL)
+	})
+	Subscribe("deliver", deliverSentMessage)
+	Subscribe("yunxin_account", func(e *UserRegistered) error {
+		user, err := model.GetUserById(e.UserID)
+		if err != nil {
+			return err
+		}
+		return utils.CreateYunxinUser(user.AccId, user.Nickname)
+	})
+	SubscribeWithID("welcome", func(id uint, e *UserRegistered) error {
+		return sendSystemNotification(e.UserID, "Welcome to WorldCity!", nil, notificationEventKey(id, "welcome"))
+	})
+}
+
+func notifyOrderByNo(actorID, recipientID uint, orderNo, event string, eventKey *string) error {
+	if recipientID == 0 {
+		return nil
+	}
+	order, err := model.NewOrderRepository(nil).FindByOrderNo(orderNo)
+	if err != nil || order == nil {
+		return err
+	}
+	return notifyOrder(actorID, recipientID, order, event, eventKey)
+}
+
+// 投递给接收方，消息已撤回或删除时不再投递
+func deliverSentMessage(e *MessageSent) error {
+	msg, err := model.GetMessageById(e.MessageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if msg.Recalled {
+		return nil
+	}
+	payload, err := model.ParseMessagePayload(msg.ContentType, []byte(msg.Content))
+	if err != nil {
+		return nil
+	}
+	return deliverer.Deliver(e.SenderID, e.ReceiverID, payload)
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// EventTransport 把认领的 outbox 事件送到处理器
+type EventTransport interface {
+	// Publish 交出一条已认领的事件，之后由传输层负责调用 handleOutboxEvent
+	Publish(rec *model.OutboxEvent) error
+	// Consume 持续接收其他实例发布的事件，不需要时直接返回
+	Consume()
+}
+
+// 单实例部署：在投递任务中直接执行处理器
+type localEventTransport struct{}
+
+func (localEventTransport) Publish(rec *model.OutboxEvent) error {
+	return handleOutboxEvent(rec.ID)
+}
+
+func (localEventTransport) Consume() {}
+
+const (
+	eventConsumerGroup = "dispatch"
+	eventStreamMaxLen  = 100000
+	eventReadCount     = 50
+	eventReadBlock     = 5 * time.Second
+	// 消费者取走后超过这个时间没有确认，视为消费者退出，由其他消费者接手
+	eventClaimIdle = time.Minute
+	// 写入 Stream 后等待消费的时间，超时后重新发布
+	eventPublishedLease = 5 * time.Minute
+)
+
+// 多实例部署：事件写入 Redis Stream，所有实例在同一个消费组中消费，每条事件只交给一个实例
+// Stream 中带有事件内容，其他服务也可以建立自己的消费组订阅
+type redisEventTransport struct {
+	stream   string
+	consumer string
+}
+
+func newRedisEventTransport(stream string) *redisEventTransport {
+	host, _ := os.Hostname()
+	return &redisEventTransport{stream: stream, consumer: fmt.Sprintf("%s-%d", host, os.Getpid())}
+}
+
+func (r *redisEventTransport) Publish(rec *model.OutboxEvent) error {
+	err := model.GetRds().XAdd(model.Ctx, &redis.XAddArgs{
+		Stream: r.stream,
+		MaxLen: eventStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"id":      rec.ID,
+			"type":    rec.Type,
+			"payload": string(rec.Payload),
+		},
+	}).Err()
+	if err != nil {
+		return err
+	}
+	return model.UpdateOutboxEvent(rec.ID, map[string]interface{}{
+		"status":          model.OutboxStatusPublished,
+		"next_attempt_at": time.Now().Add(eventPublishedLease),
+	})
+}
+
+func (r *redisEventTransport) Consume() {
+	rds := model.GetRds()
+	for {
+		err := rds.XGroupCreateMkStream(model.Ctx, r.stream, eventConsumerGroup, "0").Err()
+		if err == nil || strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			break
+		}
+		log.Println("event consumer create group error:", err)
+		time.Sleep(eventReadBlock)
+	}
+	lastClaim := time.Time{}
+	for {
+		// 接手其他消费者取走后没有确认的事件
+		if time.Since(lastClaim) >= eventClaimIdle {
+			lastClaim = time.Now()
+			msgs, _, err := rds.XAutoClaim(model.Ctx, &redis.XAutoClaimArgs{
+				Stream:   r.stream,
+				Group:    eventConsumerGroup,
+				Consumer: r.consumer,
+				MinIdle:  eventClaimIdle,
+				Start:    "0-0",
+				Count:    eventReadCount,
+			}).Result()
+			if err != nil {
+				log.Println("event consumer claim error:", err)
+			}
+			r.handle(msgs)
+		}
+		streams, err := rds.XReadGroup(model.Ctx, &redis.XReadGroupArgs{
+			Group:    eventConsumerGroup,
+			Consumer: r.consumer,
+			Streams:  []string{r.stream, ">"},
+			Count:    eventReadCount,
+			Block:    eventReadBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			log.Println("event consumer read error:", err)
+			time.Sleep(eventReadBlock)
+			continue
+		}
+		for _, s := range streams {
+			r.handle(s.Messages)
+		}
+	}
+}
+
+// 处理器的失败由 outbox 重试，这里只在 outbox 读写失败时不确认，等待重新认领
+func (r *redisEventTransport) handle(msgs []redis.XMessage) {
+	for _, m := range msgs {
+		id, err := strconv.ParseUint(fmt.Sprint(m.Values["id"]), 10, 64)
+		if err == nil {
+			if err := handleOutboxEvent(uint(id)); err != nil {
+				log.Printf("event consumer handle %s error: %v", m.ID, err)
+				continue
+			}
+		}
+		if err := model.GetRds().XAck(model.Ctx, r.stream, eventConsumerGroup, m.ID).Err(); err != nil {
+			log.Printf("event consumer ack %s error: %v", m.ID, err)
+		}
+	}
+}
+
+var eventTransport EventTransport = localEventTransport{}
+
+// InitEventBus 根据配置选择传输方式并注册事件处理器
+func InitEventBus() {
+	eventConf := config.GetConf().Event
+	if eventConf.Transport == "redis" {
+		stream := eventConf.Stream
+		if stream == "" {
+			stream = "worldcity:events"
+		}
+		eventTransport = newRedisEventTransport(stream)
+	}
+	registerEventHandlers()
+}
+
+// RunEventConsumer 接收 Redis Stream 中的事件，本地传输时直接返回
+func RunEventConsumer() {
+	eventTransport.Consume()
+}
@@ -0,0 +1,384 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/storage"
+	"worldCity/utils"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFileTooLarge      = errors.New("file is too large")
+	ErrFileTypeNotAllow  = errors.New("file type is not allowed")
+	ErrFileCategory      = errors.New("invalid file category")
+	ErrUploadTicketInval = errors.New("upload ticket is invalid or expired")
+	ErrFileNotFound      = errors.New("file not found")
+	ErrUploadMissing     = errors.New("uploaded file not found in storage")
+)
+
+const (
+	defaultMaxFileSize  = 20 << 20
+	directUploadTTL     = 15 * time.Minute
+	defaultFileCategory = "misc"
+)
+
+var defaultAllowedTypes = []string{"image/*", "video/mp4", "audio/*"}
+
+// 文件分类，作为存储路径的第一级目录
+var fileCategories = []string{"avatar", "photo", "video", "moment", "product", "chat", "misc"}
+
+func maxFileSize() int64 {
+	if size := config.GetConf().Storage.MaxSize; size > 0 {
+		return size
+	}
+	return defaultMaxFileSize
+}
+
+func isAllowedType(contentType string) bool {
+	allowed := config.GetConf().Storage.AllowedTypes
+	if len(allowed) == 0 {
+		allowed = defaultAllowedTypes
+	}
+	for _, t := range allowed {
+		if t == contentType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// 图片还需要能去掉元数据
+func isAllowedUploadType(contentType string) bool {
+	if strings.HasPrefix(contentType, "image/") && !isProcessableImage(contentType) {
+		return false
+	}
+	return isAllowedType(contentType)
+}
+
+func checkFileCategory(category string) (string, error) {
+	if category == "" {
+		return defaultFileCategory, nil
+	}
+	if !slices.Contains(fileCategories, category) {
+		return "", ErrFileCategory
+	}
+	return category, nil
+}
+
+// 文件扩展名，优先使用原文件名的扩展名
+func fileExt(filename, contentType string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext != "" && len(ext) <= 8 && mime.TypeByExtension(ext) != "" {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// 读取到临时文件中的上传内容，类型根据内容判断，不信任客户端声明的类型
+type spooledUpload struct {
+	*os.File
+	Size        int64
+	Hash        string
+	ContentType string
+}
+
+func (u *spooledUpload) Close() error {
+	u.File.Close()
+	return os.Remove(u.File.Name())
+}
+
+// 先写入临时文件，计算哈希和大小，超过 limit 时返回 ErrFileTooLarge
+func spoolUpload(r io.Reader, limit int64) (*spooledUpload, error) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	u := &spooledUpload{File: tmp}
+	h := sha256.New()
+	u.Size, err = io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, limit+1))
+	if err == nil && u.Size > limit {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		u.Close()
+		return nil, err
+	}
+	u.Hash = hex.EncodeToString(h.Sum(nil))
+
+	head := make([]byte, 512)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		u.Close()
+		return nil, err
+	}
+	u.ContentType, _, _ = mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		u.Close()
+		return nil, err
+	}
+	return u, nil
+}
+
+// 上传文件：检查大小和类型，按内容哈希去重，相同内容只存一份
+func UploadFile(uid uint, r io.Reader, filename, category string) (*model.StoredFile, error) {
+	category, err := checkFileCategory(category)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := spoolUpload(r, maxFileSize())
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+	return saveUpload(uid, upload, category, fileExt(filename, upload.ContentType))
+}
+
+// 保存校验过大小的上传内容，并记录上传者，已存在相同内容时返回已有文件
+func saveUpload(uid uint, upload *spooledUpload, category, ext string) (*model.StoredFile, error) {
+	file, err := storeUpload(uid, upload, category, ext)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.AddFileOwner(uid, file.ID); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func storeUpload(uid uint, upload *spooledUpload, category, ext string) (*model.StoredFile, error) {
+	if !isAllowedUploadType(upload.ContentType) {
+		return nil, ErrFileTypeNotAllow
+	}
+	if existing, err := model.GetStoredFileByHash(upload.Hash); err == nil {
+		if existing.Status == model.FileStatusRejected {
+			return nil, ErrImageRejected
+		}
+		return existing, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hash, contentType := upload.Hash, upload.ContentType
+	file := &model.StoredFile{
+		Hash:       hash,
+		Key:        fmt.Sprintf("%s/%s/%s%s", category, hash[:2], hash, ext),
+		Size:       upload.Size,
+		MimeType:   contentType,
+		UploaderID: uid,
+		Status:     model.FileStatusReady,
+	}
+	var body io.Reader = upload
+	key := file.Key
+	// 图片先去掉元数据并检查尺寸，写到私有前缀下，缩略图和审核异步处理
+	if isProcessableImage(contentType) {
+		data, err := io.ReadAll(upload)
+		if err != nil {
+			return nil, err
+		}
+		data, file.Width, file.Height, err = prepareImage(data, contentType)
+		if err != nil {
+			return nil, err
+		}
+		body, file.Size, file.Status = bytes.NewReader(data), int64(len(data)), model.FileStatusPending
+		key = pendingImageKey(file.Key)
+	}
+
+	store := storage.Get()
+	if err := store.Put(context.Background(), key, body, file.Size, contentType); err != nil {
+		return nil, err
+	}
+	file.URL = store.URL(file.Key)
+	if err := model.CreateStoredFile(file); err != nil {
+		// 并发上传了相同内容
+		if existing, e := model.GetStoredFileByHash(hash); e == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	if file.Status == model.FileStatusPending {
+		enqueueImage(file.ID)
+	}
+	return file, nil
+}
+
+// 删除文件的原图、待审核的图片和缩略图
+func deleteStoredFile(file *model.StoredFile) error {
+	ctx := context.Background()
+	store := storage.Get()
+	keys := []string{file.Key, pendingImageKey(file.Key)}
+	for size, url := range file.Variants {
+		keys = append(keys, thumbnailKey(file.Hash, size, path.Ext(url)))
+	}
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return model.DeleteStoredFile(file.ID)
+}
+
+// 只能查询自己上传过的文件，其他用户的文件通过资料、动态等有权限检查的接口获取
+func GetStoredFile(uid, id uint) (*model.StoredFile, error) {
+	file, err := model.GetStoredFileById(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	owned, err := model.IsFileOwner(uid, file)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrFileNotFound
+	}
+	return file, nil
+}
+
+// 客户端直传的凭证，完成上传时校验
+type uploadTicket struct {
+	UserID      uint   `json:"user_id"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Category    string `json:"category"`
+	Ext         string `json:"ext"`
+}
+
+func buildUploadTicketKey(key string) string {
+	return fmt.Sprintf("upload_ticket:%s", key)
+}
+
+// 申请直传地址，相同内容在完成上传时去重，不按客户端提供的哈希秒传，避免泄露文件是否存在
+func RequestDirectUpload(uid uint, filename, category, contentType string, size int64) (map[string]interface{}, error) {
+	category, err := checkFileCategory(category)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 || size > maxFileSize() {
+		return nil, ErrFileTooLarge
+	}
+	contentType, _, _ = mime.ParseMediaType(contentType)
+	if !isAllowedUploadType(contentType) {
+		return nil, ErrFileTypeNotAllow
+	}
+
+	// 直传到私有前缀下，完成上传时校验内容后和服务端上传一样处理，原对象随后删除
+	ext := fileExt(filename, contentType)
+	key := fmt.Sprintf("%sdirect/%s/%s%s", storage.PrivatePrefix, time.Now().Format("20060102"), utils.RandomString(32), ext)
+	url, headers, err := storage.Get().PresignPut(key, contentType, directUploadTTL)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(&uploadTicket{UserID: uid, Size: size, ContentType: contentType, Category: category, Ext: ext})
+	if err != nil {
+		return nil, err
+	}
+	if err := model.GetRds().Set(model.Ctx, buildUploadTicketKey(key), data, directUploadTTL).Err(); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"key":        key,
+		"upload_url": url,
+		"headers":    headers,
+		"expires_in": int(directUploadTTL.Seconds()),
+	}, nil
+}
+
+func getUploadTicket(key string) (*uploadTicket, error) {
+	data, err := model.GetRds().Get(model.Ctx, buildUploadTicketKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrUploadTicketInval
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ticket uploadTicket
+	if err := json.Unmarshal(data, &ticket); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// 客户端直传完成后校验实际内容并保存
+// 预签名地址不限制上传大小，大小和类型都以存储中的对象为准
+func CompleteDirectUpload(uid uint, key string) (*model.StoredFile, error) {
+	ticket, err := getUploadTicket(key)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.UserID != uid {
+		return nil, ErrUploadTicketInval
+	}
+	ctx := context.Background()
+	store := storage.Get()
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		// 对象不存在时保留凭证，客户端可以重新上传后再完成
+		return nil, fmt.Errorf("%w: %v", ErrUploadMissing, err)
+	}
+	upload, err := spoolUpload(r, min(ticket.Size, maxFileSize()))
+	r.Close()
+
+	// 无论校验是否通过，直传的对象和凭证都不再使用
+	model.GetRds().Del(model.Ctx, buildUploadTicketKey(key))
+	if e := store.Delete(ctx, key); e != nil {
+		log.Printf("delete direct upload %s error: %v", key, e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+	category, err := checkFileCategory(ticket.Category)
+	if err != nil {
+		return nil, err
+	}
+	return saveUpload(uid, upload, category, ticket.Ext)
+}
+
+// 本地存储的直传接口，校验签名和大小后写入磁盘
+func ReceiveLocalDirectUpload(key, contentType, expires, signature string, r io.Reader) error {
+	local, ok := storage.Get().(*storage.Local)
+	if !ok {
+		return ErrUploadTicketInval
+	}
+	if err := local.VerifyPresigned(key, contentType, expires, signature); err != nil {
+		return err
+	}
+	ticket, err := getUploadTicket(key)
+	if err != nil {
+		return err
+	}
+	// 多读一个字节用于判断是否超出申请的大小
+	data, err := io.ReadAll(io.LimitReader(r, ticket.Size+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > ticket.Size {
+		return ErrFileTooLarge
+	}
+	return local.Put(context.Background(), key, bytes.NewReader(data), int64(len(data)), contentType)
+}
@@ -1,23 +1,152 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
 	"time"
 	"worldCity/model"
+	"worldCity/utils"
 )
 
-func SendGroupMessage(groupID, senderID, content, msgType string) error {
-	msg := model.GroupMessage{
-		GroupID:   groupID,
-		SenderID:  senderID,
-		Content:   content,
-		Type:      msgType,
-		CreatedAt: time.Now(),
+var (
+	ErrGroupMuted       = errors.New("you are muted in this group")
+	ErrMentionAllDenied = errors.New("only owner and admins can mention all")
+	ErrMentionNotMember = errors.New("mentioned user is not a member of this group")
+)
+
+const ChatEventGroupMessage = "group_message"
+
+type SendGroupMessageRequest struct {
+	GroupID    string          `json:"group_id" binding:"required"`
+	Type       model.MsgType   `json:"type" binding:"required"`
+	Content    json.RawMessage `json:"content" binding:"required"`
+	Mentions   []uint          `json:"mentions"`    // 被@的用户ID
+	MentionAll bool            `json:"mention_all"` // @所有人，仅群主和管理员
+}
+
+// 发送群消息：校验成员身份和禁言，更新未读/@计数，并投递给在线成员
+func SendGroupMessage(senderID uint, req SendGroupMessageRequest) (*model.GroupMessage, error) {
+	group, sender, err := requireGroupRole(req.GroupID, senderID)
+	if err != nil {
+		return nil, err
+	}
+	isManager := sender.Role == model.GroupRoleOwner || sender.Role == model.GroupRoleAdmin
+	if sender.IsMuted() || (group.MuteAll && !isManager) {
+		return nil, ErrGroupMuted
+	}
+	if req.MentionAll && !isManager {
+		return nil, ErrMentionAllDenied
+	}
+
+	payload, content, err := parseMessageContent(senderID, req.Type, req.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	memberIDs, err := groupMemberIDs(group.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	mentions := make([]uint, 0, len(req.Mentions))
+	for _, uid := range req.Mentions {
+		if uid == senderID || slices.Contains(mentions, uid) {
+			continue
+		}
+		if !slices.Contains(memberIDs, uid) {
+			return nil, ErrMentionNotMember
+		}
+		mentions = append(mentions, uid)
+	}
+
+	msg := &model.GroupMessage{
+		GroupID:    group.GroupID,
+		SenderID:   uidString(senderID),
+		Content:    content,
+		Type:       payload.Type(),
+		Mentions:   mentions,
+		MentionAll: req.MentionAll,
+		CreatedAt:  time.Now(),
+	}
+	if err := model.CreateGroupMessage(msg); err != nil {
+		return nil, err
+	}
+
+	receivers := make([]uint, 0, len(memberIDs))
+	for _, uid := range memberIDs {
+		if uid != senderID {
+			receivers = append(receivers, uid)
+		}
 	}
-	return model.GetDB().Create(&msg).Error
+	incrGroupCounters(group.GroupID, receivers, mentions, req.MentionAll)
+
+	// 只推送给在线成员，离线成员上线后通过未读数和历史消息拉取
+	online, err := model.FilterOnline(receivers)
+	if err == nil && len(online) > 0 {
+		notifyAsync(senderID, online, &ChatEvent{
+			Event:      ChatEventGroupMessage,
+			Scope:      model.MsgScopeGroup,
+			MessageID:  msg.ID,
+			GroupID:    group.GroupID,
+			OperatorID: senderID,
+			Message:    msg,
+		})
+	}
+	return msg, nil
+}
+
+// 更新每个成员的群未读数，被@的成员同时增加@计数
+func incrGroupCounters(groupID string, receivers, mentions []uint, mentionAll bool) {
+	pipe := model.GetRds().Pipeline()
+	for _, uid := range receivers {
+		pipe.HIncrBy(model.Ctx, utils.GetGroupUnreadKey(uid), groupID, 1)
+		if mentionAll || slices.Contains(mentions, uid) {
+			pipe.HIncrBy(model.Ctx, utils.GetGroupMentionKey(uid), groupID, 1)
+		}
+	}
+	pipe.Exec(model.Ctx)
+}
+
+// 获取用户在各个群的未读数和@数
+func GetGroupUnreadCounts(uid uint) (map[string]interface{}, error) {
+	rds := model.GetRds()
+	unread, err := rds.HGetAll(model.Ctx, utils.GetGroupUnreadKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := rds.HGetAll(model.Ctx, utils.GetGroupMentionKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"unread":   toCountMap(unread),
+		"mentions": toCountMap(mentions),
+	}, nil
+}
+
+func toCountMap(values map[string]string) map[string]int64 {
+	counts := make(map[string]int64, len(values))
+	for k, v := range values {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		counts[k] = n
+	}
+	return counts
 }
 
-func GetGroupMessages(groupID string, limit int) ([]model.GroupMessage, error) {
-	var messages []model.GroupMessage
-	err := model.GetDB().Where("group_id = ?", groupID).Order("created_at desc").Limit(limit).Find(&messages).Error
-	return messages, err
+// 标记群消息已读，清空未读数和@数
+func MarkGroupRead(uid uint, groupID string) error {
+	rds := model.GetRds()
+	if err := rds.HDel(model.Ctx, utils.GetGroupUnreadKey(uid), groupID).Err(); err != nil {
+		return err
+	}
+	return rds.HDel(model.Ctx, utils.GetGroupMentionKey(uid), groupID).Err()
+}
+
+// 群历史消息，只有群成员可以查看
+func GetGroupMessages(groupID string, userID uint, limit int) ([]model.GroupMessage, error) {
+	if _, _, err := requireGroupRole(groupID, userID); err != nil {
+		return nil, err
+	}
+	return model.FetchGroupMessages(groupID, userID, limit)
 }
@@ -1,43 +1,407 @@
 package service
 
 import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
 	"worldCity/model"
+	"worldCity/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrNotGroupMember      = errors.New("not a member of this group")
+	ErrGroupPermission     = errors.New("permission denied")
+	ErrGroupFull           = model.ErrGroupFull
+	ErrAlreadyInGroup      = errors.New("already a member of this group")
+	ErrInviteInvalid       = errors.New("invite link is invalid or expired")
+	ErrInviteOnlyGroup     = errors.New("this group can only be joined by invitation")
+	ErrOwnerCannotLeave    = errors.New("owner must transfer the group before leaving")
+	ErrJoinRequestNotFound = errors.New("join request not found")
+	ErrInvalidGroupRole    = errors.New("invalid group role")
 )
 
-func CreateGroup(groupID, name, creatorID string) error {
+const maxGroupMembersLimit = 2000
+
+func uidString(uid uint) string {
+	return strconv.FormatUint(uint64(uid), 10)
+}
+
+func loadGroup(groupID string) (*model.Group, error) {
+	group, err := model.GetGroupByGroupId(groupID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGroupNotFound
+	}
+	return group, err
+}
+
+func loadGroupMember(groupID string, uid uint) (*model.GroupMember, error) {
+	member, err := model.GetGroupMember(groupID, uidString(uid))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotGroupMember
+	}
+	return member, err
+}
+
+// 校验操作者在群内并且是指定角色之一
+func requireGroupRole(groupID string, uid uint, roles ...string) (*model.Group, *model.GroupMember, error) {
+	group, err := loadGroup(groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	member, err := loadGroupMember(groupID, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(roles) > 0 && !slices.Contains(roles, member.Role) {
+		return nil, nil, ErrGroupPermission
+	}
+	return group, member, nil
+}
+
+// 角色等级，用于判断管理员能否操作目标成员
+func roleLevel(role string) int {
+	switch role {
+	case model.GroupRoleOwner:
+		return 2
+	case model.GroupRoleAdmin:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// 创建群，群ID由服务端生成，创建者为群主
+func CreateGroup(ownerID uint, name string, joinMode, maxMembers uint) (*model.Group, error) {
+	if joinMode > model.GroupJoinInviteOnly {
+		joinMode = model.GroupJoinFree
+	}
+	if maxMembers == 0 {
+		maxMembers = model.DefaultGroupMaxMembers
+	} else if maxMembers > maxGroupMembersLimit {
+		maxMembers = maxGroupMembersLimit
+	}
+
+	group := &model.Group{
+		Name:       name,
+		CreatorID:  uidString(ownerID),
+		JoinMode:   joinMode,
+		MaxMembers: maxMembers,
+	}
 	db := model.GetDB()
+	// 群ID冲突时重新生成
+	err := model.Retry(func() error {
+		group.ID = 0
+		group.GroupID = utils.GenerateGroupID()
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(group).Error; err != nil {
+				return err
+			}
+			return tx.Create(&model.GroupMember{
+				GroupID: group.GroupID,
+				UserID:  group.CreatorID,
+				Role:    model.GroupRoleOwner,
+			}).Error
+		})
+	}, 3)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func GetGroupMembers(groupID string) ([]model.GroupMember, error) {
+	return model.GetGroupMembers(groupID)
+}
 
-	group := model.Group{
-		GroupID:   groupID,
-		Name:      name,
-		CreatorID: creatorID,
+// 群资料及成员列表，只有群成员可以查看
+func GetGroupInfo(uid uint, groupID string) (map[string]interface{}, error) {
+	group, member, err := requireGroupRole(groupID, uid)
+	if err != nil {
+		return nil, err
+	}
+	members, err := model.GetGroupMembers(groupID)
+	if err != nil {
+		return nil, err
 	}
-	if err := db.Create(&group).Error; err != nil {
+	return map[string]interface{}{
+		"group":   group,
+		"role":    member.Role,
+		"count":   len(members),
+		"members": members,
+	}, nil
+}
+
+func joinGroup(group *model.Group, uid uint) error {
+	_, err := model.GetGroupMember(group.GroupID, uidString(uid))
+	if err == nil {
+		return ErrAlreadyInGroup
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return err
 	}
+	return model.AddGroupMember(group, &model.GroupMember{
+		GroupID: group.GroupID,
+		UserID:  uidString(uid),
+		Role:    model.GroupRoleMember,
+	})
+}
 
-	// 添加创建者为成员
-	member := model.GroupMember{
-		GroupID: groupID,
-		UserID:  creatorID,
-		Role:    "owner",
+// 生成邀请链接，expireHours 为 0 表示永久有效，maxUses 为 0 表示不限次数
+func CreateGroupInvite(uid uint, groupID string, expireHours, maxUses uint) (*model.GroupInvite, error) {
+	group, _, err := requireGroupRole(groupID, uid, model.GroupRoleOwner, model.GroupRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	invite := &model.GroupInvite{
+		Code:      utils.RandomString(12),
+		GroupID:   group.GroupID,
+		CreatorID: uidString(uid),
+		MaxUses:   maxUses,
 	}
-	return db.Create(&member).Error
+	if expireHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(expireHours) * time.Hour)
+		invite.ExpiresAt = &expiresAt
+	}
+	if err := model.CreateGroupInvite(invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
 }
 
-func AddMember(groupID, userID string) error {
-	db := model.GetDB()
+// 邀请链接的创建者已不是群主或管理员时链接失效
+func inviteCreatorValid(invite *model.GroupInvite) (bool, error) {
+	creator, err := model.GetGroupMember(invite.GroupID, invite.CreatorID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return creator.Role == model.GroupRoleOwner || creator.Role == model.GroupRoleAdmin, nil
+}
 
-	member := model.GroupMember{
-		GroupID: groupID,
-		UserID:  userID,
-		Role:    "member",
+// 通过邀请链接入群，不需要审批
+func JoinGroupByInvite(uid uint, code string) (*model.Group, error) {
+	invite, err := model.GetGroupInviteByCode(code)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInviteInvalid
+	}
+	if err != nil {
+		return nil, err
 	}
-	return db.Create(&member).Error
+	if !invite.Valid() {
+		return nil, ErrInviteInvalid
+	}
+	group, err := loadGroup(invite.GroupID)
+	if errors.Is(err, ErrGroupNotFound) {
+		return nil, ErrInviteInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := inviteCreatorValid(invite)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInviteInvalid
+	}
+	// 先占用次数再入群，并发入群时不会超过上限
+	ok, err = model.ClaimGroupInviteUse(invite.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInviteInvalid
+	}
+	if err := joinGroup(group, uid); err != nil {
+		if err := model.ReleaseGroupInviteUse(invite.ID); err != nil {
+			log.Printf("release invite %d use error: %v", invite.ID, err)
+		}
+		return nil, err
+	}
+	return group, nil
 }
 
-func GetGroupMembers(groupID string) ([]model.GroupMember, error) {
-	var members []model.GroupMember
-	err := model.GetDB().Where("group_id = ?", groupID).Find(&members).Error
-	return members, err
+// 申请入群：无需审批的群直接加入，否则生成待审批的申请
+func ApplyJoinGroup(uid uint, groupID, message string) (map[string]interface{}, error) {
+	group, err := loadGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	switch group.JoinMode {
+	case model.GroupJoinInviteOnly:
+		return nil, ErrInviteOnlyGroup
+	case model.GroupJoinFree:
+		if err := joinGroup(group, uid); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"joined": true}, nil
+	}
+
+	if _, err := model.GetGroupMember(groupID, uidString(uid)); err == nil {
+		return nil, ErrAlreadyInGroup
+	}
+	// 已有待审批的申请时直接返回
+	req, err := model.GetPendingJoinRequest(groupID, uidString(uid))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		req = &model.GroupJoinRequest{
+			GroupID: groupID,
+			UserID:  uidString(uid),
+			Message: message,
+			Status:  model.JoinRequestPending,
+		}
+		err = model.CreateGroupJoinRequest(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"joined": false, "request": req}, nil
+}
+
+func GetGroupJoinRequests(uid uint, groupID string) ([]model.GroupJoinRequest, error) {
+	if _, _, err := requireGroupRole(groupID, uid, model.GroupRoleOwner, model.GroupRoleAdmin); err != nil {
+		return nil, err
+	}
+	return model.GetGroupJoinRequests(groupID, model.JoinRequestPending)
+}
+
+// 审批入群申请
+func HandleGroupJoinRequest(uid uint, groupID string, requestID uint, approve bool) error {
+	group, _, err := requireGroupRole(groupID, uid, model.GroupRoleOwner, model.GroupRoleAdmin)
+	if err != nil {
+		return err
+	}
+	req, err := model.GetGroupJoinRequest(requestID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && req.GroupID != groupID) {
+		return ErrJoinRequestNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if req.Status != model.JoinRequestPending {
+		return ErrJoinRequestNotFound
+	}
+
+	status := model.JoinRequestRejected
+	if approve {
+		applicant, err := strconv.ParseUint(req.UserID, 10, 64)
+		if err != nil {
+			return err
+		}
+		if err := joinGroup(group, uint(applicant)); err != nil && !errors.Is(err, ErrAlreadyInGroup) {
+			return err
+		}
+		status = model.JoinRequestApproved
+	}
+	return model.UpdateGroupJoinRequest(req.ID, map[string]interface{}{
+		"status":     status,
+		"handler_id": uidString(uid),
+	})
+}
+
+// 退出群，群主需要先转让
+func LeaveGroup(uid uint, groupID string) error {
+	_, member, err := requireGroupRole(groupID, uid)
+	if err != nil {
+		return err
+	}
+	if member.Role == model.GroupRoleOwner {
+		return ErrOwnerCannotLeave
+	}
+	return model.RemoveGroupMember(groupID, member.UserID)
+}
+
+// 操作者必须比目标成员的角色更高
+func requireHigherRole(groupID string, uid, targetID uint) (*model.GroupMember, error) {
+	_, operator, err := requireGroupRole(groupID, uid, model.GroupRoleOwner, model.GroupRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	target, err := loadGroupMember(groupID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if roleLevel(operator.Role) <= roleLevel(target.Role) {
+		return nil, ErrGroupPermission
+	}
+	return target, nil
+}
+
+// 踢出成员，群主可以踢管理员和成员，管理员只能踢成员
+func KickGroupMember(uid uint, groupID string, targetID uint) error {
+	target, err := requireHigherRole(groupID, uid, targetID)
+	if err != nil {
+		return err
+	}
+	return model.RemoveGroupMember(groupID, target.UserID)
+}
+
+// 设置成员角色，只有群主可以设置管理员
+func SetGroupMemberRole(uid uint, groupID string, targetID uint, role string) error {
+	if role != model.GroupRoleAdmin && role != model.GroupRoleMember {
+		return ErrInvalidGroupRole
+	}
+	if _, _, err := requireGroupRole(groupID, uid, model.GroupRoleOwner); err != nil {
+		return err
+	}
+	target, err := loadGroupMember(groupID, targetID)
+	if err != nil {
+		return err
+	}
+	if target.Role == model.GroupRoleOwner {
+		return ErrGroupPermission
+	}
+	return model.UpdateGroupMember(groupID, target.UserID, map[string]interface{}{"role": role})
+}
+
+// 转让群主
+func TransferGroup(uid uint, groupID string, targetID uint) error {
+	_, owner, err := requireGroupRole(groupID, uid, model.GroupRoleOwner)
+	if err != nil {
+		return err
+	}
+	target, err := loadGroupMember(groupID, targetID)
+	if err != nil {
+		return err
+	}
+	if target.UserID == owner.UserID {
+		return nil
+	}
+	return model.TransferGroupOwner(groupID, owner.UserID, target.UserID)
+}
+
+// 禁言成员，minutes 为 0 表示解除禁言
+func MuteGroupMember(uid uint, groupID string, targetID, minutes uint) error {
+	target, err := requireHigherRole(groupID, uid, targetID)
+	if err != nil {
+		return err
+	}
+	var mutedUntil *time.Time
+	if minutes > 0 {
+		t := time.Now().Add(time.Duration(minutes) * time.Minute)
+		mutedUntil = &t
+	}
+	return model.UpdateGroupMember(groupID, target.UserID, map[string]interface{}{"muted_until": mutedUntil})
+}
+
+// 全员禁言，群主和管理员不受影响
+func MuteAllGroupMembers(uid uint, groupID string, mute bool) error {
+	if _, _, err := requireGroupRole(groupID, uid, model.GroupRoleOwner, model.GroupRoleAdmin); err != nil {
+		return err
+	}
+	return model.UpdateGroup(groupID, map[string]interface{}{"mute_all": mute})
+}
+
+// 解散群，只有群主可以操作
+func DissolveGroup(uid uint, groupID string) error {
+	if _, _, err := requireGroupRole(groupID, uid, model.GroupRoleOwner); err != nil {
+		return err
+	}
+	return model.DissolveGroup(groupID)
 }
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/storage"
+	"worldCity/utils"
+
+	_ "image/gif"
+)
+
+var (
+	ErrImageTooLarge = errors.New("image dimensions are too large")
+	ErrImageCorrupt  = errors.New("image is corrupt or unsupported")
+	ErrImageRejected = errors.New("image was rejected by moderation")
+)
+
+const (
+	defaultMaxPixels  = 40_000_000
+	thumbnailQuality  = 85
+	imageJobQueueSize = 256
+)
+
+var defaultThumbnailSizes = []int{160, 480, 1080}
+
+// ImageModeration 审核结果
+type ImageModeration struct {
+	Allowed bool
+	Reason  string
+}
+
+// ImageModerator 图片审核接口，返回错误时图片保持待处理，稍后重试
+type ImageModerator interface {
+	ModerateImage(ctx context.Context, file *model.StoredFile, img image.Image) (*ImageModeration, error)
+}
+
+// 本地规则审核：禁止的内容哈希、极端长宽比
+type ruleImageModerator struct{}
+
+func (ruleImageModerator) ModerateImage(ctx context.Context, file *model.StoredFile, img image.Image) (*ImageModeration, error) {
+	conf := config.GetConf().Image
+	if slices.Contains(conf.BlockedHashes, file.Hash) {
+		return &ImageModeration{Reason: "image is blocked"}, nil
+	}
+	w, h := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+	if conf.MaxAspectRatio > 0 && max(w/h, h/w) > conf.MaxAspectRatio {
+		return &ImageModeration{Reason: "image aspect ratio is too extreme"}, nil
+	}
+	return &ImageModeration{Allowed: true}, nil
+}
+
+var imageModerator ImageModerator = ruleImageModerator{}
+
+// SetImageModerator 替换图片审核实现
+func SetImageModerator(m ImageModerator) {
+	imageModerator = m
+}
+
+var imageJobs = make(chan uint, imageJobQueueSize)
+
+// 只接受标准库能解码、能去掉元数据的图片格式，webp、heic 等无法去掉位置信息，不允许上传
+func isProcessableImage(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png" || contentType == "image/gif"
+}
+
+// 缩略图按内容哈希存放，相同内容共用
+func thumbnailKey(hash, size, ext string) string {
+	return fmt.Sprintf("thumbs/%s/%s_%s%s", hash[:2], hash, size, ext)
+}
+
+// 审核通过前图片存放在私有前缀下，通过后才写到对外的地址
+func pendingImageKey(key string) string {
+	return storage.PrivatePrefix + "pending/" + key
+}
+
+func maxImagePixels() int {
+	if n := config.GetConf().Image.MaxPixels; n > 0 {
+		return n
+	}
+	return defaultMaxPixels
+}
+
+func thumbnailSizes() []int {
+	if sizes := config.GetConf().Image.ThumbnailSizes; len(sizes) > 0 {
+		return sizes
+	}
+	return defaultThumbnailSizes
+}
+
+// 上传时同步执行的检查：去掉位置等元数据，检查尺寸，返回处理后的内容和宽高
+func prepareImage(data []byte, contentType string) ([]byte, int, int, error) {
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		data, err = utils.StripJPEGMetadata(data)
+	case "image/png":
+		data, err = utils.StripPNGMetadata(data)
+	}
+	if err != nil {
+		return nil, 0, 0, ErrImageCorrupt
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, ErrImageCorrupt
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, 0, 0, ErrImageCorrupt
+	}
+	if cfg.Width*cfg.Height > maxImagePixels() {
+		return nil, 0, 0, ErrImageTooLarge
+	}
+	// 返回显示方向的宽高，方向 5 到 8 需要旋转 90 度
+	if contentType == "image/jpeg" && utils.JPEGOrientation(data) >= 5 {
+		return data, cfg.Height, cfg.Width, nil
+	}
+	return data, cfg.Width, cfg.Height, nil
+}
+
+// 加入处理队列，队列满时由定时扫描补上
+func enqueueImage(fileID uint) {
+	select {
+	case imageJobs <- fileID:
+	default:
+	}
+}
+
+// RunImageWorker 异步生成缩略图并审核，同时定时扫描遗漏的待处理图片
+func RunImageWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case id := <-imageJobs:
+			file, err := model.GetStoredFileById(id)
+			if err != nil {
+				log.Println("image worker load file error:", err)
+				continue
+			}
+			processImage(file)
+		case <-ticker.C:
+			files, err := model.GetPendingStoredFiles(50)
+			if err != nil {
+				log.Println("image worker scan error:", err)
+				continue
+			}
+			for i := range files {
+				processImage(&files[i])
+			}
+		}
+	}
+}
+
+func processImage(file *model.StoredFile) {
+	if file.Status != model.FileStatusPending {
+		return
+	}
+	if err := doProcessImage(file); err != nil {
+		log.Printf("process image %d error: %v", file.ID, err)
+	}
+}
+
+func doProcessImage(file *model.StoredFile) error {
+	ctx := context.Background()
+	store := storage.Get()
+	r, err := store.Get(ctx, pendingImageKey(file.Key))
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return rejectImage(file, ErrImageCorrupt.Error())
+	}
+	// 解码不会处理 EXIF 方向，先转正再审核和生成缩略图，缩略图重新编码后不带 EXIF
+	if file.MimeType == "image/jpeg" {
+		img = utils.ApplyOrientation(img, utils.JPEGOrientation(data))
+	}
+	res, err := imageModerator.ModerateImage(ctx, file, img)
+	if err != nil {
+		return err
+	}
+	if !res.Allowed {
+		return rejectImage(file, res.Reason)
+	}
+
+	variants := map[string]string{}
+	for _, size := range thumbnailSizes() {
+		thumb := utils.Thumbnail(img, size)
+		if thumb == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		ext, contentType := ".jpg", "image/jpeg"
+		if utils.IsOpaque(img) {
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: thumbnailQuality})
+		} else {
+			// 有透明通道的保留为 PNG
+			ext, contentType = ".png", "image/png"
+			err = png.Encode(&buf, thumb)
+		}
+		if err != nil {
+			return err
+		}
+		key := thumbnailKey(file.Hash, strconv.Itoa(size), ext)
+		if err := store.Put(ctx, key, &buf, int64(buf.Len()), contentType); err != nil {
+			return err
+		}
+		variants[strconv.Itoa(size)] = store.URL(key)
+	}
+	if err := store.Put(ctx, file.Key, bytes.NewReader(data), int64(len(data)), file.MimeType); err != nil {
+		return err
+	}
+	file.Variants = variants
+	file.Status = model.FileStatusReady
+	if err := model.UpdateStoredFile(file); err != nil {
+		return err
+	}
+	return store.Delete(ctx, pendingImageKey(file.Key))
+}
+
+// 审核不通过或无法解码时删除原图，保留记录防止再次上传
+func rejectImage(file *model.StoredFile, reason string) error {
+	if err := storage.Get().Delete(context.Background(), pendingImageKey(file.Key)); err != nil {
+		return err
+	}
+	file.Status = model.FileStatusRejected
+	file.RejectReason = reason
+	return model.UpdateStoredFile(file)
+}
+
+// ImageVariant 原图地址和各尺寸缩略图
+type ImageVariant struct {
+	URL      string            `json:"url"`
+	Status   string            `json:"status"`
+	Variants map[string]string `json:"variants,omitempty"`
+}
+
+// 按原图地址批量查询缩略图，不是通过上传服务存储的地址不在结果中
+func GetImageVariants(urls []string) (map[string]*ImageVariant, error) {
+	files, err := model.GetStoredFilesByURLs(urls)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*ImageVariant, len(files))
+	for _, f := range files {
+		res[f.URL] = &ImageVariant{URL: f.URL, Status: f.Status, Variants: f.Variants}
+	}
+	return res, nil
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+
+	"gorm.io/gorm"
+)
+
+const defaultRecallWindow = 2 * time.Minute
+
+var (
+	ErrMessageNotFound  = errors.New("message not found")
+	ErrNotMessageSender = errors.New("only the sender can do this")
+	ErrNotMessageMember = errors.New("not a participant of this conversation")
+	ErrRecallExpired    = errors.New("message can no longer be recalled")
+	ErrEditExpired      = errors.New("message can no longer be edited")
+	ErrNotRevisable     = errors.New("this message cannot be recalled or edited")
+	ErrMessageRecalled  = errors.New("message has been recalled")
+)
+
+// 撤回时限，未配置时使用默认值
+func recallWindow() time.Duration {
+	if seconds := config.GetConf().Chat.RecallWindow; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultRecallWindow
+}
+
+// 撤回和编辑共用时限，服务端生成的消息不能修改
+func checkRevisable(msgType model.MsgType, createdAt time.Time, expired error) error {
+	if msgType.ServerGenerated() {
+		return ErrNotRevisable
+	}
+	if time.Since(createdAt) > recallWindow() {
+		return expired
+	}
+	return nil
+}
+
+func loadPrivateMessage(msgID uint) (*model.Message, error) {
+	msg, err := model.GetMessageById(msgID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	return msg, err
+}
+
+func loadGroupMessage(msgID uint) (*model.GroupMessage, error) {
+	msg, err := model.GetGroupMessageById(msgID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	return msg, err
+}
+
+// 撤回私聊消息，只有发送者可以在时限内撤回
+func RecallMessage(userID, msgID uint) error {
+	msg, err := loadPrivateMessage(msgID)
+	if err != nil {
+		return err
+	}
+	if msg.SenderID != userID {
+		return ErrNotMessageSender
+	}
+	if msg.Recalled {
+		return ErrMessageRecalled
+	}
+	if err := checkRevisable(msg.ContentType, msg.CreatedAt, ErrRecallExpired); err != nil {
+		return err
+	}
+
+	err = model.ReviseMessage(&model.Message{}, &model.MessageRevision{
+		Scope:       model.MsgScopeP2P,
+		MessageID:   msg.ID,
+		Action:      model.MsgActionRecall,
+		OperatorID:  userID,
+		ContentType: msg.ContentType,
+		Content:     msg.Content,
+	}, map[string]interface{}{"recalled": true, "content": ""})
+	if err != nil {
+		return err
+	}
+
+	notifyAsync(userID, []uint{msg.SenderID, msg.ReceiverID}, &ChatEvent{
+		Event:      ChatEventRecalled,
+		Scope:      model.MsgScopeP2P,
+		MessageID:  msg.ID,
+		OperatorID: userID,
+	})
+	return nil
+}
+
+// 编辑私聊消息，只有发送者可以在时限内编辑，且不能修改消息类型
+func EditMessage(userID, msgID uint, raw json.RawMessage) (*model.Message, error) {
+	msg, err := loadPrivateMessage(msgID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID {
+		return nil, ErrNotMessageSender
+	}
+	if msg.Recalled {
+		return nil, ErrMessageRecalled
+	}
+	if err := checkRevisable(msg.ContentType, msg.CreatedAt, ErrEditExpired); err != nil {
+		return nil, err
+	}
+	_, content, err := parseMessageContent(userID, msg.ContentType, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = model.ReviseMessage(&model.Message{}, &model.MessageRevision{
+		Scope:       model.MsgScopeP2P,
+		MessageID:   msg.ID,
+		Action:      model.MsgActionEdit,
+		OperatorID:  userID,
+		ContentType: msg.ContentType,
+		Content:     msg.Content,
+	}, map[string]interface{}{"content": content, "edited_at": &now})
+	if err != nil {
+		return nil, err
+	}
+	msg.Content = content
+	msg.EditedAt = &now
+
+	notifyAsync(userID, []uint{msg.SenderID, msg.ReceiverID}, &ChatEvent{
+		Event:      ChatEventEdited,
+		Scope:      model.MsgScopeP2P,
+		MessageID:  msg.ID,
+		OperatorID: userID,
+		Message:    msg,
+	})
+	return msg, nil
+}
+
+// 仅对自己删除私聊消息，对方不受影响
+func DeleteMessageForMe(userID, msgID uint) error {
+	msg, err := loadPrivateMessage(msgID)
+	if err != nil {
+		return err
+	}
+	if msg.SenderID != userID && msg.ReceiverID != userID {
+		return ErrNotMessageMember
+	}
+	if err := model.CreateMessageDeletion(userID, model.MsgScopeP2P, msg.ID); err != nil {
+		return err
+	}
+
+	notifyAsync(userID, []uint{userID}, &ChatEvent{
+		Event:      ChatEventDeleted,
+		Scope:      model.MsgScopeP2P,
+		MessageID:  msg.ID,
+		OperatorID: userID,
+	})
+	return nil
+}
+
+func GetMessageRevisions(userID, msgID uint) ([]model.MessageRevision, error) {
+	msg, err := loadPrivateMessage(msgID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID && msg.ReceiverID != userID {
+		return nil, ErrNotMessageMember
+	}
+	revisions, err := model.GetMessageRevisions(model.MsgScopeP2P, msg.ID)
+	if err != nil {
+		return nil, err
+	}
+	// 撤回或下架后接收方不能再看到任何历史内容，只保留修改记录本身
+	if msg.Recalled && msg.SenderID != userID {
+		for i := range revisions {
+			revisions[i].Content = ""
+		}
+	}
+	return revisions, nil
+}
+
+// 群成员的用户ID列表，用于同步事件
+func groupMemberIDs(groupID string) ([]uint, error) {
+	members, err := GetGroupMembers(groupID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseUint(member.UserID, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func isGroupSender(msg *model.GroupMessage, userID uint) bool {
+	return msg.SenderID == fmt.Sprintf("%d", userID)
+}
+
+// 撤回群消息，只有发送者可以在时限内撤回
+func RecallGroupMessage(userID, msgID uint) error {
+	msg, err := loadGroupMessage(msgID)
+	if err != nil {
+		return err
+	}
+	if !isGroupSender(msg, userID) {
+		return ErrNotMessageSender
+	}
+	if msg.Recalled {
+		return ErrMessageRecalled
+	}
+	if err := checkRevisable(msg.Type, msg.CreatedAt, ErrRecallExpired); err != nil {
+		return err
+	}
+
+	err = model.ReviseMessage(&model.GroupMessage{}, &model.MessageRevision{
+		Scope:       model.MsgScopeGroup,
+		MessageID:   msg.ID,
+		Action:      model.MsgActionRecall,
+		OperatorID:  userID,
+		ContentType: msg.Type,
+		Content:     msg.Content,
+	}, map[string]interface{}{"recalled": true, "content": ""})
+	if err != nil {
+		return err
+	}
+
+	memberIDs, err := groupMemberIDs(msg.GroupID)
+	if err != nil {
+		return err
+	}
+	notifyAsync(userID, memberIDs, &ChatEvent{
+		Event:      ChatEventRecalled,
+		Scope:      model.MsgScopeGroup,
+		MessageID:  msg.ID,
+		GroupID:    msg.GroupID,
+		OperatorID: userID,
+	})
+	return nil
+}
+
+// 编辑群消息，只有发送者可以在时限内编辑，且不能修改消息类型
+func EditGroupMessage(userID, msgID uint, raw json.RawMessage) (*model.GroupMessage, error) {
+	msg, err := loadGroupMessage(msgID)
+	if err != nil {
+		return nil, err
+	}
+	if !isGroupSender(msg, userID) {
+		return nil, ErrNotMessageSender
+	}
+	if msg.Recalled {
+		return nil, ErrMessageRecalled
+	}
+	if err := checkRevisable(msg.Type, msg.CreatedAt, ErrEditExpired); err != nil {
+		return nil, err
+	}
+	_, content, err := parseMessageContent(userID, msg.Type, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = model.ReviseMessage(&model.GroupMessage{}, &model.MessageRevision{
+		Scope:       model.MsgScopeGroup,
+		MessageID:   msg.ID,
+		Action:      model.MsgActionEdit,
+		OperatorID:  userID,
+		ContentType: msg.Type,
+		Content:     msg.Content,
+	}, map[string]interface{}{"content": content, "edited_at": &now})
+	if err != nil {
+		return nil, err
+	}
+	msg.Content = content
+	msg.EditedAt = &now
+
+	memberIDs, err := groupMemberIDs(msg.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	notifyAsync(userID, memberIDs, &ChatEvent{
+		Event:      ChatEventEdited,
+		Scope:      model.MsgScopeGroup,
+		MessageID:  msg.ID,
+		GroupID:    msg.GroupID,
+		OperatorID: userID,
+		Message:    msg,
+	})
+	return msg, nil
+}
+
+// 仅对自己删除群消息
+func DeleteGroupMessageForMe(userID, msgID uint) error {
+	msg, err := loadGroupMessage(msgID)
+	if err != nil {
+		return err
+	}
+	memberIDs, err := groupMemberIDs(msg.GroupID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(memberIDs, userID) {
+		return ErrNotMessageMember
+	}
+	if err := model.CreateMessageDeletion(userID, model.MsgScopeGroup, msg.ID); err != nil {
+		return err
+	}
+
+	notifyAsync(userID, []uint{userID}, &ChatEvent{
+		Event:      ChatEventDeleted,
+		Scope:      model.MsgScopeGroup,
+		MessageID:  msg.ID,
+		GroupID:    msg.GroupID,
+		OperatorID: userID,
+	})
+	return nil
+}
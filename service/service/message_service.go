@@ -1,41 +1,77 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 	"worldCity/model"
-	"worldCity/utils"
+
+	"gorm.io/gorm"
 )
 
+// 消息内容校验失败，调用方应返回参数错误
+var ErrInvalidMessage = errors.New("invalid message")
+
 type SendMessageRequest struct {
-	ToID    uint
-	Type    string
-	Content string
-	IsGroup bool
-	GroupID uint
+	ToID    uint            `json:"to_id" binding:"required"`
+	Type    model.MsgType   `json:"type" binding:"required"`
+	Content json.RawMessage `json:"content" binding:"required"`
 }
 
-func SendMessage(fromID uint, req SendMessageRequest) error {
-	// 保存数据库
-	msg := model.Message{
-		ID:         fromID,
-		ReceiverID: req.ToID,
-		// Type:    req.Type,
-		Content: req.Content,
-		// IsGroup: req.IsGroup,
-		GroupID: req.GroupID,
-		IsRead:  false,
+func SendMessage(fromID uint, req SendMessageRequest) (*model.Message, error) {
+	if err := checkNotBlocked(fromID, req.ToID); err != nil {
+		return nil, err
 	}
-	if err := model.GetDB().Create(&msg).Error; err != nil {
-		return err
+	payload, content, err := parseMessageContent(fromID, req.Type, req.Content)
+	if err != nil {
+		return nil, err
 	}
 
-	// 保存Redis未读数
-	if !req.IsGroup {
-		key := utils.GetUnreadKey(req.ToID)
-		model.GetRds().Incr(model.Ctx, key)
+	// 保存数据库
+	msg := &model.Message{
+		SessionID:   model.BuildSessionID(fromID, req.ToID),
+		SenderID:    fromID,
+		ReceiverID:  req.ToID,
+		ContentType: payload.Type(),
+		Content:     content,
+		Timestamp:   uint(time.Now().Unix()),
+		IsRead:      false,
 	}
+	// 未读数和投递给接收方由 MessageSent 的处理器完成
+	err = withEvents(func(tx *gorm.DB) ([]Event, error) {
+		if err := tx.Create(msg).Error; err != nil {
+			return nil, err
+		}
+		return []Event{&MessageSent{
+			MessageID:  msg.ID,
+			SenderID:   fromID,
+			ReceiverID: req.ToID,
+			Type:       string(msg.ContentType),
+		}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
 
-	// 调用云信发送
-	go utils.SendYunxinMsg(fromID, req.ToID, req.Type, req.Content)
-
-	return nil
+// 校验消息内容，文本消息经过审核，返回规范化后的 JSON 编码
+func parseMessageContent(senderID uint, msgType model.MsgType, raw json.RawMessage) (model.MessagePayload, string, error) {
+	payload, err := model.ParseMessagePayload(msgType, raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if text, ok := payload.(*model.TextPayload); ok {
+		mod, err := ModerateText(senderID, model.ModerationSceneMessage, text.Text)
+		if err != nil {
+			return nil, "", err
+		}
+		text.Text = mod.Text
+	}
+	content, err := model.EncodeMessagePayload(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, content, nil
 }
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrContentRejected     = errors.New("content violates community guidelines")
+	ErrModerationNotFound  = errors.New("moderation record not found")
+	ErrModerationReviewed  = errors.New("moderation record is not waiting for review")
+	ErrInvalidModerationOp = errors.New("invalid moderation query")
+)
+
+const (
+	defaultModerationPageSize = 20
+	maxModerationPageSize     = 100
+)
+
+// 结论的严重程度，多处命中时取最严重的
+var verdictSeverity = map[string]int{
+	model.ModerationPass:   0,
+	model.ModerationMask:   1,
+	model.ModerationReview: 2,
+	model.ModerationReject: 3,
+}
+
+func severer(a, b string) string {
+	if verdictSeverity[b] > verdictSeverity[a] {
+		return b
+	}
+	return a
+}
+
+// TextCheckResult 文本检查结果，Text 为替换敏感词后的内容
+type TextCheckResult struct {
+	Checker string
+	Verdict string
+	Text    string
+	Hits    []string
+}
+
+// TextChecker 文本审核接口，scene 为 model.ModerationScene*
+type TextChecker interface {
+	CheckText(ctx context.Context, scene, text string) (*TextCheckResult, error)
+}
+
+// 词典中的一条正则规则
+type dictRegexp struct {
+	re      *regexp.Regexp
+	source  string
+	verdict string
+}
+
+type textDict struct {
+	matcher  *utils.ACMatcher
+	verdicts []string // 与 matcher 中的词一一对应
+	regexps  []dictRegexp
+	modTime  time.Time
+}
+
+// 解析词典，每行为 "词语 [mask|review|reject]"，re: 开头的是正则表达式
+func parseTextDict(path string) (*textDict, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &textDict{modTime: info.ModTime()}
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		verdict := model.ModerationMask
+		if i := strings.LastIndexAny(line, " \t"); i > 0 {
+			if _, ok := verdictSeverity[line[i+1:]]; ok && line[i+1:] != model.ModerationPass {
+				verdict = line[i+1:]
+				line = strings.TrimSpace(line[:i])
+			}
+		}
+		if expr, ok := strings.CutPrefix(line, "re:"); ok {
+			re, err := regexp.Compile("(?i)" + expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			d.regexps = append(d.regexps, dictRegexp{re: re, source: line, verdict: verdict})
+			continue
+		}
+		words = append(words, line)
+		d.verdicts = append(d.verdicts, verdict)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	d.matcher = utils.NewACMatcher(words)
+	return d, nil
+}
+
+// 内置的敏感词和正则检查，词典可以热更新
+type keywordTextChecker struct {
+	dict atomic.Pointer[textDict]
+}
+
+func (k *keywordTextChecker) CheckText(ctx context.Context, scene, text string) (*TextCheckResult, error) {
+	res := &TextCheckResult{Checker: "keyword", Verdict: model.ModerationPass, Text: text}
+	d := k.dict.Load()
+	if d == nil {
+		return res, nil
+	}
+	hit := func(word, verdict string) {
+		res.Verdict = severer(res.Verdict, verdict)
+		if !slices.Contains(res.Hits, word) {
+			res.Hits = append(res.Hits, word)
+		}
+	}
+
+	runes := []rune(text)
+	masked := false
+	for _, m := range d.matcher.FindAll(runes) {
+		verdict := d.verdicts[m.Pattern]
+		hit(d.matcher.Pattern(m.Pattern), verdict)
+		if verdict == model.ModerationMask {
+			for i := m.Start; i < m.End; i++ {
+				runes[i] = '*'
+			}
+			masked = true
+		}
+	}
+	if masked {
+		res.Text = string(runes)
+	}
+	for _, r := range d.regexps {
+		if !r.re.MatchString(text) {
+			continue
+		}
+		hit(r.source, r.verdict)
+		if r.verdict == model.ModerationMask {
+			res.Text = r.re.ReplaceAllStringFunc(res.Text, func(s string) string {
+				return strings.Repeat("*", utf8.RuneCountInString(s))
+			})
+		}
+	}
+	return res, nil
+}
+
+var keywordChecker = &keywordTextChecker{}
+
+var textChecker TextChecker = keywordChecker
+
+// SetTextChecker 替换文本审核实现，例如接入第三方内容安全服务
+func SetTextChecker(c TextChecker) {
+	textChecker = c
+}
+
+// LoadTextDict 重新加载敏感词词典，加载失败时保留之前的词典
+func LoadTextDict() error {
+	path := config.GetConf().Moderation.DictFile
+	if path == "" {
+		keywordChecker.dict.Store(nil)
+		return nil
+	}
+	d, err := parseTextDict(path)
+	if err != nil {
+		return err
+	}
+	keywordChecker.dict.Store(d)
+	return nil
+}
+
+// RunTextDictWatcher 定时检查词典文件，修改后重新加载
+func RunTextDictWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		path := config.GetConf().Moderation.DictFile
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Println("text dict stat error:", err)
+			continue
+		}
+		if d := keywordChecker.dict.Load(); d != nil && d.modTime.Equal(info.ModTime()) {
+			continue
+		}
+		if err := LoadTextDict(); err != nil {
+			log.Println("text dict reload error:", err)
+		}
+	}
+}
+
+func TextDictReloadInterval() time.Duration {
+	return secondsOr(config.GetConf().Moderation.ReloadInterval, 30*time.Second)
+}
+
+// 不同场景能接受的结论不同：
+// 私聊和简介无法先隐藏再展示，需要人工审核的直接拒绝；昵称和标签较短，命中即拒绝
+func sceneVerdict(scene, verdict string) string {
+	switch scene {
+	case model.ModerationSceneMoment, model.ModerationSceneComment:
+		return verdict
+	case model.ModerationSceneMessage, model.ModerationSceneDesc:
+		if verdict == model.ModerationReview {
+			return model.ModerationReject
+		}
+		return verdict
+	}
+	if verdict != model.ModerationPass {
+		return model.ModerationReject
+	}
+	return verdict
+}
+
+// TextModeration 审核后可以保存的内容
+type TextModeration struct {
+	Verdict  string
+	Text     string
+	RecordID uint
+}
+
+// 需要人工审核的内容先隐藏
+func (m *TextModeration) ReviewStatus() string {
+	if m.Verdict == model.ModerationReview {
+		return model.ReviewStatusPending
+	}
+	return model.ReviewStatusApproved
+}
+
+// ModerateText 审核用户提交的文本并写入审核记录，拒绝时返回 ErrContentRejected
+func ModerateText(uid uint, scene, text string) (*TextModeration, error) {
+	if strings.TrimSpace(text) == "" {
+		return &TextModeration{Verdict: model.ModerationPass, Text: text}, nil
+	}
+	res, err := textChecker.CheckText(context.Background(), scene, text)
+	if err != nil {
+		return nil, err
+	}
+	verdict := sceneVerdict(scene, res.Verdict)
+	record := &model.ModerationRecord{
+		UserID:  uid,
+		Scene:   scene,
+		Checker: res.Checker,
+		Verdict: verdict,
+		Hits:    res.Hits,
+		Content: text,
+		Result:  res.Text,
+	}
+	if verdict == model.ModerationReview {
+		record.ReviewStatus = model.ReviewStatusPending
+	}
+	if err := model.CreateModerationRecord(record); err != nil {
+		return nil, err
+	}
+	if verdict == model.ModerationReject {
+		return nil, ErrContentRejected
+	}
+	mod := &TextModeration{Verdict: verdict, Text: text, RecordID: record.ID}
+	if verdict == model.ModerationMask {
+		mod.Text = res.Text
+	}
+	return mod, nil
+}
+
+// 审核记录关联到保存后的动态或评论
+func linkModerationTarget(mod *TextModeration, targetID uint) {
+	if mod.RecordID == 0 {
+		return
+	}
+	if err := model.SetModerationTarget(mod.RecordID, targetID); err != nil {
+		log.Printf("link moderation record %d error: %v", mod.RecordID, err)
+	}
+}
+
+// 审核记录列表，可按场景、结论、人工审核状态和用户筛选
+func ListModerationRecords(filter model.ModerationFilter, page, size int) (map[string]interface{}, error) {
+	if filter.Verdict != "" {
+		if _, ok := verdictSeverity[filter.Verdict]; !ok {
+			return nil, ErrInvalidModerationOp
+		}
+	}
+	page, size = pageOr(page, size, defaultModerationPageSize, maxModerationPageSize)
+	filter.Offset, filter.Limit = (page-1)*size, size
+	records, total, err := model.GetModerationRecords(filter)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total":     total,
+		"page":      page,
+		"page_size": size,
+		"records":   records,
+	}, nil
+}
+
+// 人工审核，通过后动态或评论对其他人可见
+func ReviewModeration(operatorID, recordID uint, approve bool, note string) (*model.ModerationRecord, error) {
+	record, err := model.GetModerationRecordById(recordID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrModerationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.ReviewStatus != model.ReviewStatusPending {
+		return nil, ErrModerationReviewed
+	}
+	status := model.ReviewStatusRejected
+	if approve {
+		status = model.ReviewStatusApproved
+	}
+	if err := model.ReviewModerationRecord(record, status, operatorID, note); err != nil {
+		return nil, err
+	}
+	if approve && record.Scene == model.ModerationSceneComment {
+		notifyApprovedComment(record.TargetID)
+	}
+	return model.GetModerationRecordById(recordID)
+}
+
+// 评论审核通过后补发评论通知
+func notifyApprovedComment(commentID uint) {
+	comment, err := model.GetMomentCommentById(commentID)
+	if err != nil {
+		return
+	}
+	moment, err := model.GetMomentById(comment.MomentID)
+	if err != nil {
+		return
+	}
+	NotifyMomentCommented(comment.UserID, moment, comment)
+}
@@ -1,23 +1,115 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"worldCity/config"
 	"worldCity/model"
+	"worldCity/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidMoment     = errors.New("invalid moment")
+	ErrNotMomentOwner    = errors.New("only the author can change this moment")
+	ErrMomentNotEditable = errors.New("moment can no longer be edited")
+)
+
+const (
+	maxMomentVisibility  = model.MomentVisibilityPrivate
+	maxMomentMediaURLLen = 512
 )
 
+func momentEditWindow() time.Duration {
+	return secondsOr(config.GetConf().Moment.EditWindow, 15*time.Minute)
+}
+
+func momentMaxImages() int {
+	return intOr(config.GetConf().Moment.MaxImages, 9)
+}
+
+func isMomentMediaURL(url string) bool {
+	return len(url) <= maxMomentMediaURLLen &&
+		(strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://"))
+}
+
+// 文字、图片和视频至少有一项，图片和视频不能同时发布
+func validateMoment(content string, images []string, video string, visibility uint) error {
+	if strings.TrimSpace(content) == "" && len(images) == 0 && video == "" {
+		return fmt.Errorf("%w: content, images or video is required", ErrInvalidMoment)
+	}
+	if len(images) > 0 && video != "" {
+		return fmt.Errorf("%w: images and video cannot be posted together", ErrInvalidMoment)
+	}
+	if n := momentMaxImages(); len(images) > n {
+		return fmt.Errorf("%w: at most %d images", ErrInvalidMoment, n)
+	}
+	for _, url := range images {
+		if !isMomentMediaURL(url) {
+			return fmt.Errorf("%w: invalid image url", ErrInvalidMoment)
+		}
+	}
+	if video != "" && !isMomentMediaURL(video) {
+		return fmt.Errorf("%w: invalid video url", ErrInvalidMoment)
+	}
+	if visibility > maxMomentVisibility {
+		return fmt.Errorf("%w: invalid visibility", ErrInvalidMoment)
+	}
+	return nil
+}
+
 type MomentSummy struct {
 	MomentInfo *model.Moment `json:"moment"`
-	Owner      *model.User   `json:"owner"`
+	Owner      *UserBrief    `json:"owner"`
 	Liked      bool          `json:"liked"`
 	LikeCount  uint          `json:"like_count"`
+	// 原图地址 -> 缩略图，只包含通过上传服务存储的图片
+	ImageVariants map[string]*ImageVariant `json:"image_variants,omitempty"`
+}
+
+// 批量补充动态图片的缩略图
+func fillMomentImageVariants(summy []MomentSummy) error {
+	var urls []string
+	for _, s := range summy {
+		urls = append(urls, s.MomentInfo.Images...)
+	}
+	variants, err := GetImageVariants(urls)
+	if err != nil {
+		return err
+	}
+	for i := range summy {
+		for _, url := range summy[i].MomentInfo.Images {
+			if v, ok := variants[url]; ok {
+				if summy[i].ImageVariants == nil {
+					summy[i].ImageVariants = map[string]*ImageVariant{}
+				}
+				summy[i].ImageVariants[url] = v
+			}
+		}
+	}
+	return nil
 }
 
 // 获取所有moments，不区分是哪个用户发布的
+// 不展示与自己存在拉黑关系以及被自己屏蔽的用户
 func GetMoments(LoginedUserId uint) ([]MomentSummy, error) {
-	moments, err := model.GetMoments(0)
+	hidden, err := hiddenFeedUserIDs(LoginedUserId)
+	if err != nil {
+		return nil, err
+	}
+	moments, err := model.GetMoments(0, LoginedUserId, hidden)
 	if err != nil {
 		return nil, err
 	}
+	return buildMomentSummaries(LoginedUserId, moments)
+}
 
+// 补充发布者、点赞状态和图片缩略图
+func buildMomentSummaries(viewerId uint, moments []model.Moment) ([]MomentSummy, error) {
 	summy := []MomentSummy{}
 	for _, moment := range moments {
 		user, err := model.GetUserById(moment.UserID)
@@ -25,21 +117,27 @@ func GetMoments(LoginedUserId uint) ([]MomentSummy, error) {
 			return nil, err
 		}
 
-		liked, err := model.IsLiked(LoginedUserId, moment.ID)
+		liked, err := model.IsLiked(viewerId, moment.ID)
 		likeCount, err := model.GetMomentsLikesCount(moment.ID)
 		summy = append(summy, MomentSummy{
 			MomentInfo: &moment,
-			Owner:      user,
+			Owner:      ToUserBrief(user),
 			Liked:      liked,
 			LikeCount:  likeCount,
 		})
 	}
+	if err := fillMomentImageVariants(summy); err != nil {
+		return nil, err
+	}
 	return summy, nil
 }
 
 // 获取指定用户发布的moments
-func GetUserMoments(UserId uint) ([]MomentSummy, error) {
-	moments, err := model.GetMoments(UserId)
+func GetUserMoments(viewerId, UserId uint) ([]MomentSummy, error) {
+	if err := checkNotBlocked(viewerId, UserId); err != nil {
+		return nil, err
+	}
+	moments, err := model.GetMoments(UserId, viewerId, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +151,9 @@ func GetUserMoments(UserId uint) ([]MomentSummy, error) {
 			LikeCount:  0,
 		})
 	}
+	if err := fillMomentImageVariants(summy); err != nil {
+		return nil, err
+	}
 	return summy, nil
 }
 
@@ -60,28 +161,234 @@ type MomentInfo struct {
 	UserId     uint
 	Content    string
 	Images     []string
+	Video      string
 	Location   string
 	Visibility uint
 }
 
+// 发布前审核文本，需要人工审核的动态先只对自己可见
 func PostMoment(moment *MomentInfo) (*model.Moment, error) {
-	return model.PostMoment(&model.Moment{
-		UserID:     moment.UserId,
-		Content:    moment.Content,
-		Images:     moment.Images,
-		Location:   moment.Location,
-		Visibility: moment.Visibility,
+	if err := validateMoment(moment.Content, moment.Images, moment.Video, moment.Visibility); err != nil {
+		return nil, err
+	}
+	mod, err := ModerateText(moment.UserId, model.ModerationSceneMoment, moment.Content)
+	if err != nil {
+		return nil, err
+	}
+	newMoment, err := model.PostMoment(&model.Moment{
+		UserID:       moment.UserId,
+		Content:      mod.Text,
+		Images:       moment.Images,
+		Video:        moment.Video,
+		Location:     moment.Location,
+		Visibility:   moment.Visibility,
+		ReviewStatus: mod.ReviewStatus(),
 	})
+	if err != nil {
+		return nil, err
+	}
+	linkModerationTarget(mod, newMoment.ID)
+	updateMomentTopics(newMoment, true)
+	return newMoment, nil
+}
+
+// MomentPatch 编辑动态，只修改请求中包含的字段，null 表示清空
+type MomentPatch struct {
+	Content    utils.Optional[string]   `json:"content"`
+	Images     utils.Optional[[]string] `json:"images"`
+	Video      utils.Optional[string]   `json:"video"`
+	Location   utils.Optional[string]   `json:"location"`
+	Visibility utils.Optional[uint]     `json:"visibility"`
+}
+
+// 作者自己的动态，其他人的动态返回 ErrNotMomentOwner
+func loadOwnMoment(uid, momentID uint) (*model.Moment, error) {
+	moment, err := model.GetMomentById(momentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMomentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if moment.UserID != uid {
+		return nil, ErrNotMomentOwner
+	}
+	return moment, nil
+}
+
+// 发布后一段时间内可以编辑，修改文字后重新审核，人工审核未通过的动态不能编辑
+func EditMoment(uid, momentID uint, patch *MomentPatch) (*model.Moment, error) {
+	moment, err := loadOwnMoment(uid, momentID)
+	if err != nil {
+		return nil, err
+	}
+	if moment.ReviewStatus == model.ReviewStatusRejected || time.Since(moment.CreatedAt) > momentEditWindow() {
+		return nil, ErrMomentNotEditable
+	}
+
+	content, images, video := moment.Content, moment.Images, moment.Video
+	location, visibility := moment.Location, moment.Visibility
+	if patch.Content.Set {
+		content = patch.Content.Value
+	}
+	if patch.Images.Set {
+		images = patch.Images.Value
+	}
+	if patch.Video.Set {
+		video = patch.Video.Value
+	}
+	if patch.Location.Set {
+		location = patch.Location.Value
+	}
+	if patch.Visibility.Set {
+		visibility = patch.Visibility.Value
+	}
+	if err := validateMoment(content, images, video, visibility); err != nil {
+		return nil, err
+	}
+
+	// map 更新不经过 serializer，手动编码
+	imagesJSON, err := json.Marshal(images)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	updates := map[string]interface{}{
+		"images":     string(imagesJSON),
+		"video":      video,
+		"location":   location,
+		"visibility": visibility,
+		"edited_at":  &now,
+	}
+	var mod *TextModeration
+	if content != moment.Content {
+		if mod, err = ModerateText(uid, model.ModerationSceneMoment, content); err != nil {
+			return nil, err
+		}
+		updates["content"] = mod.Text
+		updates["review_status"] = mod.ReviewStatus()
+	}
+	if err := model.UpdateMoment(moment.ID, updates); err != nil {
+		return nil, err
+	}
+	updated, err := model.GetMomentById(moment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mod != nil {
+		linkModerationTarget(mod, moment.ID)
+		updateMomentTopics(updated, false)
+	}
+	return updated, nil
+}
+
+// 作者删除动态，点赞和评论一起删除
+func DeleteMoment(uid, momentID uint) error {
+	if _, err := loadOwnMoment(uid, momentID); err != nil {
+		return err
+	}
+	return model.DeleteMoment(momentID)
+}
+
+// 运营下架动态，原因会通知作者
+func TakedownMoment(operatorID, momentID uint, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fmt.Errorf("%w: takedown reason is required", ErrInvalidMoment)
+	}
+	moment, err := model.GetMomentById(momentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMomentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := model.TakedownMoment(moment.ID, operatorID, reason); err != nil {
+		return err
+	}
+	notifyInBackground(model.NotifyTypeSystem, func() error {
+		return SendSystemNotification(moment.UserID, "Your moment was taken down: "+reason,
+			map[string]interface{}{"moment_id": moment.ID, "reason": reason})
+	})
+	return nil
+}
+
+// 获取可以互动的动态，与发布者存在拉黑关系时不允许点赞和评论
+func loadInteractableMoment(UserId, MomentId uint) (*model.Moment, error) {
+	moment, err := model.GetMomentById(MomentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMomentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 未通过审核和不在可见范围内的动态视为不存在
+	visible, err := model.CanViewMoment(UserId, moment)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, ErrMomentNotFound
+	}
+	if err := checkNotBlocked(UserId, moment.UserID); err != nil {
+		return nil, err
+	}
+	return moment, nil
 }
 
 func LikeMoment(UserId, MomentId uint, status bool) (*model.MomentLike, error) {
-	return model.LikeMoment(UserId, MomentId, status)
+	moment, err := loadInteractableMoment(UserId, MomentId)
+	if err != nil {
+		return nil, err
+	}
+	likedAt := time.Now()
+	var like *model.MomentLike
+	err = withEvents(func(tx *gorm.DB) ([]Event, error) {
+		var err error
+		var first bool
+		// 只有第一次点赞发出事件，反复取消和点赞不会重复通知或增加话题热度
+		like, first, err = model.LikeMoment(tx, UserId, MomentId, status)
+		if err != nil || !first {
+			return nil, err
+		}
+		return []Event{&MomentLiked{MomentID: moment.ID, OwnerID: moment.UserID, UserID: UserId, LikedAt: likedAt}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return like, nil
 }
 
 func CommentMoment(UserId, MomentId uint, content string) (*model.MomentComment, error) {
-	return model.CommentMoment(UserId, MomentId, content)
+	moment, err := loadInteractableMoment(UserId, MomentId)
+	if err != nil {
+		return nil, err
+	}
+	mod, err := ModerateText(UserId, model.ModerationSceneComment, content)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := model.CommentMoment(UserId, MomentId, mod.Text, mod.ReviewStatus())
+	if err != nil {
+		return nil, err
+	}
+	linkModerationTarget(mod, comment.ID)
+	// 待审核的评论在审核通过后再通知
+	if comment.ReviewStatus == model.ReviewStatusApproved {
+		NotifyMomentCommented(UserId, moment, comment)
+		recordTopicEngagementAsync(moment, topicWeightComment)
+	}
+	return comment, nil
 }
 
-func GetMomentComments(MomentId uint) ([]model.MomentComment, error) {
-	return model.GetMomentComments(MomentId)
+// 不展示与查看者存在拉黑关系的用户的评论
+func GetMomentComments(viewerId, MomentId uint) ([]model.MomentComment, error) {
+	if _, err := loadInteractableMoment(viewerId, MomentId); err != nil {
+		return nil, err
+	}
+	blocked, err := model.GetBlockRelatedUserIDs(viewerId)
+	if err != nil {
+		return nil, err
+	}
+	return model.GetMomentComments(MomentId, viewerId, blocked)
 }
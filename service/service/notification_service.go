@@ -0,0 +1,342 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"unicode/utf8"
+	"worldCity/model"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidNotifyType = errors.New("invalid notification type")
+
+const (
+	defaultNotificationPageSize = 20
+	maxNotificationPageSize     = 100
+	notificationSnippetLen      = 50
+
+	// 新通知通过聊天投递通道通知在线端刷新未读数
+	ChatEventNotification = "notification"
+	notificationScope     = "notification"
+)
+
+// 订单通知的事件
+const (
+	OrderEventCreated   = "created"
+	OrderEventCancelled = "cancelled"
+	OrderEventReviewed  = "reviewed"
+)
+
+// NotificationView 返回给客户端的通知，Text 为展示文案
+type NotificationView struct {
+	*model.Notification
+	Actor *UserBrief `json:"actor,omitempty"`
+	Text  string     `json:"text"`
+}
+
+func snippet(text string) string {
+	if utf8.RuneCountInString(text) <= notificationSnippetLen {
+		return text
+	}
+	return string([]rune(text)[:notificationSnippetLen]) + "…"
+}
+
+// 检查接收者是否关闭了该类型通知，以及双方是否存在拉黑关系
+func shouldNotify(n *model.Notification) (bool, error) {
+	if n.UserID == 0 || n.UserID == n.ActorID {
+		return false, nil
+	}
+	if n.ActorID != 0 {
+		blocked, err := model.IsBlockedBetween(n.UserID, n.ActorID)
+		if err != nil || blocked {
+			return false, err
+		}
+	}
+	if n.Type == model.NotifyTypeSystem {
+		return true, nil
+	}
+	return model.IsNotificationEnabled(n.UserID, n.Type)
+}
+
+func createNotification(n *model.Notification) error {
+	ok, err := shouldNotify(n)
+	if err != nil || !ok {
+		return err
+	}
+	if n.ActorCount == 0 && n.ActorID != 0 {
+		n.ActorCount = 1
+	}
+	created, err := model.CreateNotification(n)
+	if err != nil || !created {
+		return err
+	}
+	pushNotificationEvent(n)
+	enqueuePush(n)
+	return nil
+}
+
+func pushNotificationEvent(n *model.Notification) {
+	notifyAsync(n.ActorID, []uint{n.UserID}, &ChatEvent{
+		Event:      ChatEventNotification,
+		Scope:      notificationScope,
+		OperatorID: n.ActorID,
+		Data:       map[string]interface{}{"id": n.ID, "type": n.Type},
+	})
+}
+
+// 通知失败不影响触发它的操作
+func notifyInBackground(name string, fn func() error) {
+	go func() {
+		if err := fn(); err != nil {
+			log.Printf("create %s notification failed: %v", name, err)
+		}
+	}()
+}
+
+// 点赞通知，同一动态未读的点赞合并为一条，likedAt 为点赞时间，用于统计合并的点赞人数
+func notifyMomentLiked(actorID uint, moment *model.Moment, likedAt time.Time) error {
+	groupKey := fmt.Sprintf("like:moment:%d", moment.ID)
+	n, err := model.GetUnreadNotificationByGroup(moment.UserID, groupKey)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return createNotification(&model.Notification{
+			UserID:     moment.UserID,
+			Type:       model.NotifyTypeLike,
+			ActorID:    actorID,
+			TargetType: model.NotifyTargetMoment,
+			TargetID:   moment.ID,
+			GroupKey:   groupKey,
+			Content:    snippet(moment.Content),
+			CreatedAt:  likedAt,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if n.ActorID == actorID {
+		return nil
+	}
+	if ok, err := shouldNotify(&model.Notification{UserID: moment.UserID, Type: model.NotifyTypeLike, ActorID: actorID}); err != nil || !ok {
+		return err
+	}
+	count, err := model.CountMomentLikersSince(moment.ID, moment.UserID, n.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := model.UpdateNotificationActors(n.ID, actorID, max(count, 1)); err != nil {
+		return err
+	}
+	pushNotificationEvent(n)
+	return nil
+}
+
+func NotifyMomentCommented(actorID uint, moment *model.Moment, comment *model.MomentComment) {
+	notifyInBackground(model.NotifyTypeComment, func() error {
+		return createNotification(&model.Notification{
+			UserID:     moment.UserID,
+			Type:       model.NotifyTypeComment,
+			ActorID:    actorID,
+			TargetType: model.NotifyTargetMoment,
+			TargetID:   moment.ID,
+			Content:    snippet(comment.Content),
+			Data:       map[string]interface{}{"comment_id": comment.ID},
+		})
+	})
+}
+
+func NotifyFollowed(actorID, followeeID uint) {
+	notifyInBackground(model.NotifyTypeFollow, func() error {
+		return createNotification(&model.Notification{
+			UserID:     followeeID,
+			Type:       model.NotifyTypeFollow,
+			ActorID:    actorID,
+			TargetType: model.NotifyTargetUser,
+			TargetID:   actorID,
+		})
+	})
+}
+
+// 事件处理器创建的通知按事件 ID 和处理器去重
+func notificationEventKey(eventID uint, handler string) *string {
+	key := fmt.Sprintf("%d:%s", eventID, handler)
+	return &key
+}
+
+// 订单状态变化时通知服务者，下单、取消和评价都会通知，eventKey 为空时不去重
+func notifyOrder(actorID, recipientID uint, order *model.Order, event string, eventKey *string) error {
+	return createNotification(&model.Notification{
+		UserID:     recipientID,
+		Type:       model.NotifyTypeOrder,
+		ActorID:    actorID,
+		TargetType: model.NotifyTargetOrder,
+		TargetID:   order.ID,
+		EventKey:   eventKey,
+		Data: map[string]interface{}{
+			"order_no": order.OrderNo,
+			"event":    event,
+			"status":   order.Status,
+		},
+	})
+}
+
+func NotifyOrderEvent(actorID, recipientID uint, order *model.Order, event string) {
+	notifyInBackground(model.NotifyTypeOrder, func() error {
+		return notifyOrder(actorID, recipientID, order, event, nil)
+	})
+}
+
+// SendSystemNotification 系统通知，不受用户的通知设置影响
+func SendSystemNotification(userID uint, content string, data map[string]interface{}) error {
+	return sendSystemNotification(userID, content, data, nil)
+}
+
+func sendSystemNotification(userID uint, content string, data map[string]interface{}, eventKey *string) error {
+	return createNotification(&model.Notification{
+		UserID:   userID,
+		Type:     model.NotifyTypeSystem,
+		Content:  content,
+		Data:     data,
+		EventKey: eventKey,
+	})
+}
+
+func notificationText(n *model.Notification, actor *UserBrief) string {
+	name := "Someone"
+	if actor != nil && actor.Nickname != "" {
+		name = actor.Nickname
+	}
+	switch n.Type {
+	case model.NotifyTypeLike:
+		if n.ActorCount > 1 {
+			return fmt.Sprintf("%s and %d others liked your moment", name, n.ActorCount-1)
+		}
+		return fmt.Sprintf("%s liked your moment", name)
+	case model.NotifyTypeComment:
+		return fmt.Sprintf("%s commented on your moment: %s", name, n.Content)
+	case model.NotifyTypeFollow:
+		return fmt.Sprintf("%s started following you", name)
+	case model.NotifyTypeOrder:
+		orderNo := n.Data["order_no"]
+		switch n.Data["event"] {
+		case OrderEventCreated:
+			return fmt.Sprintf("You have a new order %v", orderNo)
+		case OrderEventCancelled:
+			return fmt.Sprintf("Order %v was cancelled", orderNo)
+		case OrderEventReviewed:
+			return fmt.Sprintf("Order %v received a review", orderNo)
+		}
+		return fmt.Sprintf("Order %v was updated", orderNo)
+	}
+	return n.Content
+}
+
+func toNotificationViews(list []model.Notification) ([]NotificationView, error) {
+	var actorIDs []uint
+	for _, n := range list {
+		if n.ActorID != 0 && !slices.Contains(actorIDs, n.ActorID) {
+			actorIDs = append(actorIDs, n.ActorID)
+		}
+	}
+	users, err := model.GetUsersByIds(actorIDs)
+	if err != nil {
+		return nil, err
+	}
+	actors := make(map[uint]*UserBrief, len(users))
+	for i := range users {
+		actors[users[i].ID] = ToUserBrief(&users[i])
+	}
+	views := make([]NotificationView, 0, len(list))
+	for i := range list {
+		n := &list[i]
+		actor := actors[n.ActorID]
+		views = append(views, NotificationView{Notification: n, Actor: actor, Text: notificationText(n, actor)})
+	}
+	return views, nil
+}
+
+func checkNotifyType(t string) error {
+	if t != "" && !slices.Contains(model.NotifyTypes, t) {
+		return ErrInvalidNotifyType
+	}
+	return nil
+}
+
+func GetNotifications(uid uint, notifyType string, unreadOnly bool, page, size int) (map[string]interface{}, error) {
+	if err := checkNotifyType(notifyType); err != nil {
+		return nil, err
+	}
+	page, size = pageOr(page, size, defaultNotificationPageSize, maxNotificationPageSize)
+	list, total, err := model.GetNotifications(uid, notifyType, unreadOnly, (page-1)*size, size)
+	if err != nil {
+		return nil, err
+	}
+	views, err := toNotificationViews(list)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total":         total,
+		"page":          page,
+		"page_size":     size,
+		"notifications": views,
+	}, nil
+}
+
+// 未读总数和各类型的未读数
+func GetUnreadNotificationCount(uid uint) (map[string]interface{}, error) {
+	counts, err := model.CountUnreadNotifications(uid)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	byType := make(map[string]int64, len(model.NotifyTypes))
+	for _, t := range model.NotifyTypes {
+		byType[t] = counts[t]
+		total += counts[t]
+	}
+	return map[string]interface{}{
+		"total":   total,
+		"by_type": byType,
+	}, nil
+}
+
+// 标记已读，ids 为空时标记该类型或全部通知
+func MarkNotificationsRead(uid uint, ids []uint, notifyType string) (int64, error) {
+	if err := checkNotifyType(notifyType); err != nil {
+		return 0, err
+	}
+	return model.MarkNotificationsRead(uid, ids, notifyType)
+}
+
+// 各类型通知是否开启，系统通知始终开启
+func GetNotificationPreferences(uid uint) (map[string]bool, error) {
+	prefs, err := model.GetNotificationPreferences(uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool, len(model.NotifyTypes))
+	for _, t := range model.NotifyTypes {
+		res[t] = true
+	}
+	for _, p := range prefs {
+		if p.Type != model.NotifyTypeSystem {
+			res[p.Type] = p.Enabled
+		}
+	}
+	return res, nil
+}
+
+func UpdateNotificationPreferences(uid uint, prefs map[string]bool) (map[string]bool, error) {
+	for t := range prefs {
+		if t == model.NotifyTypeSystem || checkNotifyType(t) != nil {
+			return nil, ErrInvalidNotifyType
+		}
+	}
+	if err := model.SaveNotificationPreferences(uid, prefs); err != nil {
+		return nil, err
+	}
+	return GetNotificationPreferences(uid)
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"worldCity/config"
+)
+
+// OAuthProfile 第三方返回的用户信息
+type OAuthProfile struct {
+	Subject       string
+	Email         string
+	Phone         string
+	PhoneVerified bool // 第三方确认过手机号归属，才能用于合并已有账号
+	Nickname      string
+	Avatar        string
+}
+
+// OAuthProvider 第三方身份提供方
+type OAuthProvider interface {
+	// 跳转到第三方授权页面的地址
+	AuthURL(state, redirectURI string) string
+	// 用授权码换取用户信息
+	Exchange(code, redirectURI string) (*OAuthProfile, error)
+}
+
+const fakeOAuthProvider = "fake"
+
+var oauthProviders = map[string]OAuthProvider{}
+
+// RegisterOAuthProvider 注册身份提供方，同名会覆盖
+func RegisterOAuthProvider(name string, p OAuthProvider) {
+	oauthProviders[name] = p
+}
+
+// InitOAuthProviders 根据配置注册身份提供方
+func InitOAuthProviders() {
+	oauthConf := config.GetConf().OAuth
+	for name, c := range oauthConf.Providers {
+		RegisterOAuthProvider(name, &oauth2Provider{conf: c})
+	}
+	if oauthConf.Fake {
+		RegisterOAuthProvider(fakeOAuthProvider, fakeProvider{})
+	}
+}
+
+// 标准授权码模式：token 接口换取 access_token，再调用 userinfo 接口
+type oauth2Provider struct {
+	conf config.OAuthProviderConfig
+}
+
+var oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func (p *oauth2Provider) AuthURL(state, redirectURI string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.conf.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	if len(p.conf.Scopes) > 0 {
+		q.Set("scope", strings.Join(p.conf.Scopes, " "))
+	}
+	sep := "?"
+	if strings.Contains(p.conf.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.conf.AuthURL + sep + q.Encode()
+}
+
+func (p *oauth2Provider) Exchange(code, redirectURI string) (*OAuthProfile, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.conf.ClientID)
+	form.Set("client_secret", p.conf.ClientSecret)
+	token, err := postOAuthForm(p.conf.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, _ := token["access_token"].(string)
+	if accessToken == "" {
+		return nil, fmt.Errorf("oauth token response without access_token: %v", token["error"])
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.conf.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	// 微信等不支持 Bearer 的平台通过参数传递
+	q := req.URL.Query()
+	q.Set("access_token", accessToken)
+	if openid, ok := token["openid"].(string); ok {
+		q.Set("openid", openid)
+	}
+	req.URL.RawQuery = q.Encode()
+	info, err := doOAuthRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &OAuthProfile{
+		Subject:  firstString(info, "sub", "unionid", "openid", "id"),
+		Email:    firstString(info, "email"),
+		Phone:    firstString(info, "phone_number", "phone"),
+		Nickname: firstString(info, "name", "nickname"),
+		Avatar:   firstString(info, "picture", "headimgurl", "avatar"),
+	}
+	if profile.Subject == "" {
+		profile.Subject = firstString(token, "unionid", "openid")
+	}
+	profile.PhoneVerified, _ = info["phone_number_verified"].(bool)
+	if profile.Subject == "" {
+		return nil, errors.New("oauth userinfo without subject")
+	}
+	return profile, nil
+}
+
+func postOAuthForm(endpoint string, form url.Values) (map[string]interface{}, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doOAuthRequest(req)
+}
+
+func doOAuthRequest(req *http.Request) (map[string]interface{}, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth request %s failed: %d %s", req.URL.Path, resp.StatusCode, body)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func firstString(data map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		switch v := data[k].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+	}
+	return ""
+}
+
+// 本地模拟的身份提供方，授权码格式为 subject 或 subject:phone，带手机号时视为已验证
+type fakeProvider struct{}
+
+func (fakeProvider) AuthURL(state, redirectURI string) string {
+	q := url.Values{}
+	q.Set("state", state)
+	q.Set("code", "fake-user")
+	return redirectURI + "?" + q.Encode()
+}
+
+func (fakeProvider) Exchange(code, redirectURI string) (*OAuthProfile, error) {
+	subject, phone, _ := strings.Cut(code, ":")
+	if subject == "" {
+		return nil, errors.New("invalid fake oauth code")
+	}
+	return &OAuthProfile{
+		Subject:       subject,
+		Phone:         phone,
+		PhoneVerified: phone != "",
+		Nickname:      "fake " + subject,
+	}, nil
+}
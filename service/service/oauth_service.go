@@ -0,0 +1,236 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"worldCity/model"
+	"worldCity/utils"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOAuthProviderNotFound = errors.New("oauth provider not found")
+	ErrOAuthStateInvalid     = errors.New("oauth state is invalid or expired")
+	ErrIdentityLinked        = errors.New("this account is already linked to another user")
+	ErrProviderAlreadyLinked = errors.New("a different account of this provider is already linked")
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrLastLoginMethod       = errors.New("cannot unlink the last login method")
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+// 授权请求的上下文，回调时校验
+type oauthState struct {
+	Provider    string `json:"provider"`
+	RedirectURI string `json:"redirect_uri"`
+	LinkUserID  uint   `json:"link_user_id,omitempty"` // 非 0 表示已登录用户绑定第三方账号
+}
+
+func buildOAuthStateKey(state string) string {
+	return fmt.Sprintf("oauth_state:%s", state)
+}
+
+func getOAuthProvider(name string) (OAuthProvider, error) {
+	p, ok := oauthProviders[name]
+	if !ok {
+		return nil, ErrOAuthProviderNotFound
+	}
+	return p, nil
+}
+
+// 生成第三方授权地址，linkUserID 为 0 表示登录，否则为绑定
+func OAuthAuthorize(providerName, redirectURI string, linkUserID uint) (map[string]interface{}, error) {
+	provider, err := getOAuthProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	state := utils.RandomString(32)
+	data, err := json.Marshal(&oauthState{
+		Provider:    providerName,
+		RedirectURI: redirectURI,
+		LinkUserID:  linkUserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := model.GetRds().Set(model.Ctx, buildOAuthStateKey(state), data, oauthStateTTL).Err(); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"url":   provider.AuthURL(state, redirectURI),
+		"state": state,
+	}, nil
+}
+
+// 校验 state 并用授权码换取第三方用户信息，state 只能使用一次
+func exchangeOAuthCode(providerName, code, state string, linkUserID uint) (*OAuthProfile, error) {
+	provider, err := getOAuthProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := model.GetRds().GetDel(model.Ctx, buildOAuthStateKey(state)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrOAuthStateInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s oauthState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Provider != providerName || s.LinkUserID != linkUserID {
+		return nil, ErrOAuthStateInvalid
+	}
+	return provider.Exchange(code, s.RedirectURI)
+}
+
+func newIdentity(providerName string, profile *OAuthProfile) *model.UserIdentity {
+	return &model.UserIdentity{
+		Provider: providerName,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+		Phone:    profile.Phone,
+		Nickname: profile.Nickname,
+		Avatar:   profile.Avatar,
+	}
+}
+
+// 第三方登录，账号合并规则：
+//  1. 第三方账号已绑定用户时直接登录该用户
+//  2. 第三方确认过的手机号已注册时，绑定到该用户
+//  3. 否则创建新用户，并分配云信 accid
+func OAuthLogin(providerName, code, state string, client ClientInfo) (map[string]interface{}, error) {
+	profile, err := exchangeOAuthCode(providerName, code, state, 0)
+	if err != nil {
+		return nil, err
+	}
+	account := providerName + ":" + profile.Subject
+
+	user, created, err := resolveOAuthUser(providerName, profile)
+	if err != nil {
+		recordLoginEvent(nil, account, model.LoginMethodOAuth, model.LoginResultFailed, err.Error(), client)
+		return nil, err
+	}
+	res, err := createSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	res["created"] = created
+	recordLoginEvent(user, account, model.LoginMethodOAuth, model.LoginResultSuccess, "", client)
+	return res, nil
+}
+
+func resolveOAuthUser(providerName string, profile *OAuthProfile) (*model.User, bool, error) {
+	identity, err := model.GetIdentity(providerName, profile.Subject)
+	if err == nil {
+		user, err := model.GetUserById(identity.UserID)
+		return user, false, err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	if profile.Phone != "" && profile.PhoneVerified {
+		user, err := model.GetUserByName(profile.Phone)
+		if err == nil {
+			identity := newIdentity(providerName, profile)
+			identity.UserID = user.ID
+			if err := model.CreateIdentity(identity); err != nil {
+				return nil, false, err
+			}
+			return user, false, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, err
+		}
+	}
+
+	accId := utils.GenerateAccId()
+	user := &model.User{
+		AccId:    accId,
+		Name:     accId,
+		Nickname: profile.Nickname,
+		Avatar:   profile.Avatar,
+		Role:     model.RoleUser,
+	}
+	if profile.Phone != "" && profile.PhoneVerified {
+		user.Name = profile.Phone
+	}
+	err = withEvents(func(tx *gorm.DB) ([]Event, error) {
+		if err := model.CreateUserWithIdentity(tx, user, newIdentity(providerName, profile)); err != nil {
+			return nil, err
+		}
+		return []Event{&UserRegistered{UserID: user.ID, Method: providerName}}, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+// 已登录用户绑定第三方账号，每个平台只能绑定一个账号
+func LinkOAuthIdentity(uid uint, providerName, code, state string) (*model.UserIdentity, error) {
+	profile, err := exchangeOAuthCode(providerName, code, state, uid)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := model.GetIdentity(providerName, profile.Subject)
+	if err == nil {
+		if existing.UserID != uid {
+			return nil, ErrIdentityLinked
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	identities, err := model.GetUserIdentities(uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range identities {
+		if i.Provider == providerName {
+			return nil, ErrProviderAlreadyLinked
+		}
+	}
+	identity := newIdentity(providerName, profile)
+	identity.UserID = uid
+	if err := model.CreateIdentity(identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func GetUserIdentities(uid uint) ([]model.UserIdentity, error) {
+	return model.GetUserIdentities(uid)
+}
+
+// 解绑第三方账号，没有密码时至少保留一个第三方账号
+func UnlinkOAuthIdentity(uid uint, providerName string) error {
+	user, err := model.GetUserById(uid)
+	if err != nil {
+		return err
+	}
+	identities, err := model.GetUserIdentities(uid)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, i := range identities {
+		if i.Provider == providerName {
+			found = true
+		}
+	}
+	if !found {
+		return ErrIdentityNotFound
+	}
+	if user.Password == "" && len(identities) <= 1 {
+		return ErrLastLoginMethod
+	}
+	return model.DeleteIdentity(uid, providerName)
+}
@@ -13,6 +13,7 @@ import (
 	// 导入
 	"github.com/shopspring/decimal"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // OrderService 定义订单业务逻辑接口
@@ -86,8 +87,19 @@ func (s *orderService) CreateOrder(req models.CreateOrderRequest) (*models.Order
 		// 其他时间字段默认为 NULL
 	}
 
-	// --- 5. 存储订单 ---
-	err = s.orderRepo.Create(order)
+	// --- 5. 存储订单，通知服务者由 OrderCreated 的处理器完成 ---
+	err = withEvents(func(tx *gorm.DB) ([]Event, error) {
+		if err := models.NewOrderRepository(tx).Create(order); err != nil {
+			return nil, err
+		}
+		return []Event{&OrderCreated{
+			OrderID:        order.ID,
+			OrderNo:        order.OrderNo,
+			UserID:         order.UserID,
+			ProviderUserID: provider.UserId,
+			Status:         order.Status,
+		}}, nil
+	})
 	if err != nil {
 		log.Printf("Error creating order: %v\n", err)
 		return nil, errors.New("failed to create order")
@@ -174,7 +186,22 @@ func (s *orderService) CancelOrder(orderNo string, userID uint, req models.Cance
 		"cancellation_reason": req.Reason,
 	}
 
-	err = s.orderRepo.UpdateFields(orderNo, updateData)
+	var providerUserID uint
+	if provider, err := models.GetProviderById(order.ProviderID); err == nil && provider != nil {
+		providerUserID = provider.UserId
+	}
+	err = withEvents(func(tx *gorm.DB) ([]Event, error) {
+		if err := models.NewOrderRepository(tx).UpdateFields(orderNo, updateData); err != nil {
+			return nil, err
+		}
+		return []Event{&OrderCancelled{
+			OrderID:        order.ID,
+			OrderNo:        order.OrderNo,
+			UserID:         userID,
+			ProviderUserID: providerUserID,
+			Reason:         json.RawMessage(req.Reason),
+		}}, nil
+	})
 	if err != nil {
 		log.Printf("Error updating order status for cancellation %s: %v\n", orderNo, err)
 		return errors.New("failed to cancel order")
@@ -224,9 +251,21 @@ func (s *orderService) OrderReview(orderNo string, UserId, Score uint, Tags []st
 		log.Printf("Error updating order status for review %s: %v\n", orderNo, err)
 		return errors.New("failed to review order")
 	}
+	order.Status = models.StatusReviewed
+	notifyOrderProvider(UserId, order, OrderEventReviewed)
 	return nil
 }
 
+// 通知订单的服务者
+func notifyOrderProvider(actorID uint, order *models.Order, event string) {
+	provider, err := models.GetProviderById(order.ProviderID)
+	if err != nil || provider == nil {
+		log.Printf("Error finding provider %d for order %s notification: %v\n", order.ProviderID, order.OrderNo, err)
+		return
+	}
+	NotifyOrderEvent(actorID, provider.UserId, order, event)
+}
+
 // MapOrderToResponse 将 GORM 模型转换为响应 DTO
 func MapOrderToResponse(order *models.Order) *models.OrderResponse {
 	if order == nil {
@@ -0,0 +1,6 @@
+package service
+
+// 分页参数：page 从 1 开始，size 不大于 0 时使用默认值，且不超过上限
+func pageOr(page, size, def, limit int) (int, int) {
+	return max(page, 1), min(intOr(size, def), limit)
+}
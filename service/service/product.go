@@ -4,21 +4,34 @@ import (
 	"worldCity/model"
 )
 
+// ProductView 商品及服务者的公开信息
+type ProductView struct {
+	*model.Product
+	User *UserBrief `json:"user"`
+}
+
+func toProductView(p *model.Product) *ProductView {
+	return &ProductView{Product: p, User: ToUserBrief(&p.User)}
+}
+
 type ProviderInfo struct {
-	// User          *model.User `json:"user"`
-	Provider      *model.Product `json:"provider"`
-	Orders        uint           `json:"orders"`
-	Comments      uint           `json:"comments"`
-	Score         uint           `json:"score"`
-	AvailableTime string         `json:"available_time"`
-	Status        bool           `json:"status"`
+	Provider      *ProductView `json:"provider"`
+	Orders        uint         `json:"orders"`
+	Comments      uint         `json:"comments"`
+	Score         uint         `json:"score"`
+	AvailableTime string       `json:"available_time"`
+	Status        bool         `json:"status"`
 }
 
-// 响应信息需要携带用户信息
-func GetProvidersByCategoryID(CategoryID uint) ([]ProviderInfo, error) {
+// 响应信息需要携带用户信息，不包含与查看者存在拉黑关系的服务者
+func GetProvidersByCategoryID(viewerId, CategoryID uint) ([]ProviderInfo, error) {
+	blocked, err := model.GetBlockRelatedUserIDs(viewerId)
+	if err != nil {
+		return nil, err
+	}
 	// 先查出该分类下所有服务记录
 	var providers []model.Product
-	providers, err := model.GetProvidersByCategory(CategoryID)
+	providers, err = model.GetProvidersByCategory(CategoryID, blocked)
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +39,7 @@ func GetProvidersByCategoryID(CategoryID uint) ([]ProviderInfo, error) {
 	infos := []ProviderInfo{}
 	for _, provider := range providers {
 		infos = append(infos, ProviderInfo{
-			Provider:      &provider,
+			Provider:      toProductView(&provider),
 			Orders:        10,
 			Score:         4,
 			Status:        provider.IsActive,
@@ -41,12 +54,23 @@ func GetProviderServices(UserId uint) (map[string]interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	views := make([]*ProductView, 0, len(providers))
+	for i := range providers {
+		views = append(views, toProductView(&providers[i]))
+	}
 	return map[string]interface{}{
-		"count":     len(providers),
-		"providers": providers,
+		"count":     len(views),
+		"providers": views,
 	}, nil
 }
 
-func GetProviderById(ProviderId uint) (*model.Product, error) {
-	return model.GetProviderById(ProviderId)
+func GetProviderById(viewerId, ProviderId uint) (*ProductView, error) {
+	provider, err := model.GetProviderById(ProviderId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotBlocked(viewerId, provider.UserId); err != nil {
+		return nil, err
+	}
+	return toProductView(provider), nil
 }
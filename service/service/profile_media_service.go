@@ -0,0 +1,336 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"worldCity/model"
+	"worldCity/utils"
+)
+
+var (
+	ErrMediaNotFound       = errors.New("photo or video not found")
+	ErrMediaLimit          = errors.New("too many photos or videos")
+	ErrInvalidMediaFile    = errors.New("file is not a usable photo or video")
+	ErrInvalidMediaOrder   = errors.New("order must list every item exactly once")
+	ErrInvalidVideoAccess  = errors.New("invalid video access")
+	ErrVideoNotPaid        = errors.New("video does not require unlocking")
+	ErrCannotFollowSelf    = errors.New("cannot follow yourself")
+	ErrInsufficientCoins   = model.ErrInsufficientCoins
+	ErrCannotUnlockOwnItem = errors.New("cannot unlock your own video")
+)
+
+const (
+	maxProfilePhotos = 9
+	maxProfileVideos = 9
+	maxVideoPrice    = 100000
+)
+
+// ProfileVideo 返回给其他用户的视频，无权观看时不返回地址
+type ProfileVideo struct {
+	model.VideoInfo
+	Locked bool `json:"locked"`
+}
+
+// 检查自己上传的文件可以作为照片或视频使用
+func getMediaFile(uid, fileID uint, typePrefix string) (*model.StoredFile, error) {
+	file, err := model.GetStoredFileById(fileID)
+	if err != nil {
+		return nil, ErrInvalidMediaFile
+	}
+	owned, err := model.IsFileOwner(uid, file)
+	if err != nil {
+		return nil, err
+	}
+	if !owned || file.Status == model.FileStatusRejected || !strings.HasPrefix(file.MimeType, typePrefix) {
+		return nil, ErrInvalidMediaFile
+	}
+	return file, nil
+}
+
+func checkVideoAccess(access, price uint) error {
+	switch access {
+	case model.VideoAccessPublic, model.VideoAccessFollowers:
+		return nil
+	case model.VideoAccessPaid:
+		if price == 0 || price > maxVideoPrice {
+			return ErrInvalidVideoAccess
+		}
+		return nil
+	}
+	return ErrInvalidVideoAccess
+}
+
+// 历史数据中的视频没有 ID，修改时补上
+func ensureVideoIDs(user *model.User) {
+	for i := range user.Videos {
+		if user.Videos[i].ID == "" {
+			user.Videos[i].ID = utils.RandomString(12)
+		}
+	}
+}
+
+func findVideo(videos []model.VideoInfo, videoID string) int {
+	return slices.IndexFunc(videos, func(v model.VideoInfo) bool { return v.ID == videoID })
+}
+
+// order 必须恰好包含 items 中的每一项
+func isPermutation(items, order []string) bool {
+	if len(items) != len(order) {
+		return false
+	}
+	a, b := slices.Clone(items), slices.Clone(order)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b) && len(slices.Compact(b)) == len(order)
+}
+
+func AddProfilePhoto(uid, fileID uint) ([]string, error) {
+	file, err := getMediaFile(uid, fileID, "image/")
+	if err != nil {
+		return nil, err
+	}
+	user, err := model.UpdateUserMedia(uid, func(user *model.User) error {
+		if len(user.Photos) >= maxProfilePhotos {
+			return ErrMediaLimit
+		}
+		if !slices.Contains(user.Photos, file.URL) {
+			user.Photos = append(user.Photos, file.URL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user.Photos, nil
+}
+
+func ReorderProfilePhotos(uid uint, urls []string) ([]string, error) {
+	user, err := model.UpdateUserMedia(uid, func(user *model.User) error {
+		if !isPermutation(user.Photos, urls) {
+			return ErrInvalidMediaOrder
+		}
+		user.Photos = urls
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user.Photos, nil
+}
+
+func RemoveProfilePhoto(uid uint, url string) ([]string, error) {
+	user, err := model.UpdateUserMedia(uid, func(user *model.User) error {
+		i := slices.Index(user.Photos, url)
+		if i < 0 {
+			return ErrMediaNotFound
+		}
+		user.Photos = slices.Delete(user.Photos, i, i+1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user.Photos, nil
+}
+
+type ProfileVideoInfo struct {
+	FileID      uint
+	CoverFileID uint
+	Title       string
+	Access      uint
+	Price       uint
+}
+
+func AddProfileVideo(uid uint, info *ProfileVideoInfo) ([]model.VideoInfo, error) {
+	if err := checkVideoAccess(info.Access, info.Price); err != nil {
+		return nil, err
+	}
+	file, err := getMediaFile(uid, info.FileID, "video/")
+	if err != nil {
+		return nil, err
+	}
+	video := model.VideoInfo{
+		ID:     utils.RandomString(12),
+		Title:  info.Title,
+		Access: info.Access,
+		Price:  info.Price,
+		Uri:    file.URL,
+	}
+	if info.CoverFileID != 0 {
+		cover, err := getMediaFile(uid, info.CoverFileID, "image/")
+		if err != nil {
+			return nil, err
+		}
+		video.Cover = cover.URL
+	}
+	if video.Access != model.VideoAccessPaid {
+		video.Price = 0
+	}
+	user, err := model.UpdateUserMedia(uid, func(user *model.User) error {
+		if len(user.Videos) >= maxProfileVideos {
+			return ErrMediaLimit
+		}
+		ensureVideoIDs(user)
+		user.Videos = append(user.Videos, video)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user.Videos, nil
+}
+
+// 修改视频标题和访问权限
+func UpdateProfileVideo(uid uint, videoID, title string, access, price uint) ([]model.VideoInfo, error) {
+	if err := checkVideoAccess(access, price); err != nil {
+		return nil, err
+	}
+	if access != model.VideoAccessPaid {
+		price = 0
+	}
+	user, err := model.UpdateUserMedia(uid, func(user *model.User) error {
+		i := findVideo(user.Videos, videoID)
+		if i < 0 {
+			return ErrMediaNotFound
+		}
+		user.Videos[i].Title = title
+		user.Videos[i].Access = access
+		user.Videos[i].Price = price
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user.Videos, nil
+}
+
+func ReorderProfileVideos(uid uint, ids []string) ([]model.VideoInfo, error) {
+	user, err := model.UpdateUserMedia(uid, func(user *model.User) error {
+		ensureVideoIDs(user)
+		current := make([]string, len(user.Videos))
+		for i, v := range user.Videos {
+			current[i] = v.ID
+		}
+		if !isPermutation(current, ids) {
+			return ErrInvalidMediaOrder
+		}
+		videos := make([]model.VideoInfo, 0, len(ids))
+		for _, id := range ids {
+			videos = append(videos, user.Videos[findVideo(user.Videos, id)])
+		}
+		user.Videos = videos
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user.Videos, nil
+}
+
+func RemoveProfileVideo(uid uint, videoID string) ([]model.VideoInfo, error) {
+	user, err := model.UpdateUserMedia(uid, func(user *model.User) error {
+		i := findVideo(user.Videos, videoID)
+		if i < 0 {
+			return ErrMediaNotFound
+		}
+		user.Videos = slices.Delete(user.Videos, i, i+1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user.Videos, nil
+}
+
+// 付费解锁其他用户的视频，返回带地址的视频
+func UnlockProfileVideo(viewerID, ownerID uint, videoID string) (*ProfileVideo, error) {
+	if viewerID == ownerID {
+		return nil, ErrCannotUnlockOwnItem
+	}
+	if err := checkNotBlocked(viewerID, ownerID); err != nil {
+		return nil, err
+	}
+	owner, err := model.GetUserById(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	i := findVideo(owner.Videos, videoID)
+	if i < 0 {
+		return nil, ErrMediaNotFound
+	}
+	video := owner.Videos[i]
+	if video.Access != model.VideoAccessPaid {
+		return nil, ErrVideoNotPaid
+	}
+	if err := model.UnlockVideo(viewerID, ownerID, videoID, video.Price); err != nil {
+		return nil, err
+	}
+	return &ProfileVideo{VideoInfo: video}, nil
+}
+
+// 按观看者的权限过滤视频，无权观看的视频去掉地址并标记为锁定
+func visibleVideos(viewerID uint, owner *model.User) ([]ProfileVideo, error) {
+	videos := make([]ProfileVideo, 0, len(owner.Videos))
+	self := viewerID == owner.ID
+	var following *bool
+	var unlocked []string
+	unlockedLoaded := false
+	for _, v := range owner.Videos {
+		pv := ProfileVideo{VideoInfo: v}
+		switch {
+		case self || v.Access == model.VideoAccessPublic:
+		case v.Access == model.VideoAccessFollowers:
+			if following == nil {
+				ok, err := model.IsFollowing(viewerID, owner.ID)
+				if err != nil {
+					return nil, err
+				}
+				following = &ok
+			}
+			pv.Locked = !*following
+		default:
+			if !unlockedLoaded {
+				ids, err := model.GetUnlockedVideoIDs(viewerID, owner.ID)
+				if err != nil {
+					return nil, err
+				}
+				unlocked, unlockedLoaded = ids, true
+			}
+			pv.Locked = v.ID == "" || !slices.Contains(unlocked, v.ID)
+		}
+		if pv.Locked {
+			pv.Uri = ""
+		}
+		videos = append(videos, pv)
+	}
+	return videos, nil
+}
+
+func FollowUser(followerID, followeeID uint) error {
+	if followerID == followeeID {
+		return ErrCannotFollowSelf
+	}
+	if _, err := model.GetUserById(followeeID); err != nil {
+		return err
+	}
+	if err := checkNotBlocked(followerID, followeeID); err != nil {
+		return err
+	}
+	following, err := model.IsFollowing(followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if following {
+		return nil
+	}
+	if err := model.CreateFollow(followerID, followeeID); err != nil {
+		return err
+	}
+	NotifyFollowed(followerID, followeeID)
+	return nil
+}
+
+func UnfollowUser(followerID, followeeID uint) error {
+	return model.DeleteFollow(followerID, followeeID)
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/utils"
+)
+
+var (
+	ErrInvalidProfile       = errors.New("invalid profile")
+	ErrNicknameTooFrequent  = errors.New("nickname was changed too recently")
+	ErrProfileNothingToSave = errors.New("no profile fields to update")
+)
+
+const (
+	maxNicknameLen = 20
+	maxDescLen     = 500
+	minHeight      = 100 // 单位厘米
+	maxHeight      = 250
+	minWeight      = 30 // 单位千克
+	maxWeight      = 300
+	birthdayLayout = "2006-01-02"
+)
+
+// 清空生日时写回的默认值，与数据库默认值一致
+var emptyBirthday = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ProfilePatch 部分更新个人资料，未提供的字段保持不变，null 表示清空
+type ProfilePatch struct {
+	Nickname utils.Optional[string]   `json:"nickname"`
+	Avatar   utils.Optional[string]   `json:"avatar"`
+	Gender   utils.Optional[uint]     `json:"gender"` // 0:未知 1:男 2:女
+	Birthday utils.Optional[string]   `json:"birthday"`
+	Height   utils.Optional[uint]     `json:"height"`
+	Weight   utils.Optional[uint]     `json:"weight"`
+	Desc     utils.Optional[string]   `json:"desc"`
+	Photos   utils.Optional[[]string] `json:"photos"`
+}
+
+func invalidProfile(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidProfile, fmt.Sprintf(format, args...))
+}
+
+func bannedWord(text string) string {
+	lower := strings.ToLower(text)
+	for _, w := range config.GetConf().Profile.BannedWords {
+		if w != "" && strings.Contains(lower, strings.ToLower(w)) {
+			return w
+		}
+	}
+	return ""
+}
+
+func ageRange() (int, int) {
+	conf := config.GetConf().Profile
+	return intOr(conf.MinAge, 18), intOr(conf.MaxAge, 100)
+}
+
+// 周岁
+func ageAt(birthday, now time.Time) int {
+	age := now.Year() - birthday.Year()
+	if now.Month() < birthday.Month() || (now.Month() == birthday.Month() && now.Day() < birthday.Day()) {
+		age--
+	}
+	return age
+}
+
+func buildNicknameLockKey(uid uint) string {
+	return fmt.Sprintf("nickname_change:%d", uid)
+}
+
+// 校验并转换为需要更新的列
+func (p *ProfilePatch) apply(user *model.User) ([]string, error) {
+	var cols []string
+	if p.Nickname.Set {
+		nickname := strings.TrimSpace(p.Nickname.Value)
+		if !p.Nickname.Null {
+			if n := utf8.RuneCountInString(nickname); n == 0 || n > maxNicknameLen {
+				return nil, invalidProfile("nickname must be 1-%d characters", maxNicknameLen)
+			}
+			if w := bannedWord(nickname); w != "" {
+				return nil, invalidProfile("nickname contains banned word %q", w)
+			}
+			if _, err := ModerateText(user.ID, model.ModerationSceneNickname, nickname); err != nil {
+				return nil, err
+			}
+		}
+		user.Nickname = nickname
+		cols = append(cols, "nickname")
+	}
+	if p.Avatar.Set {
+		avatar := strings.TrimSpace(p.Avatar.Value)
+		// 头像和照片一样必须是上传过且未被拒绝的图片，为空表示清除
+		if avatar != "" {
+			if err := checkProfilePhotos(user.ID, []string{avatar}); err != nil {
+				return nil, err
+			}
+		}
+		user.Avatar = avatar
+		cols = append(cols, "avatar")
+	}
+	if p.Gender.Set {
+		if p.Gender.Value > 2 {
+			return nil, invalidProfile("gender must be 0, 1 or 2")
+		}
+		user.Gender = p.Gender.Value
+		cols = append(cols, "gender")
+	}
+	if p.Birthday.Set {
+		user.Birthday = emptyBirthday
+		if !p.Birthday.Null {
+			birthday, err := time.Parse(birthdayLayout, p.Birthday.Value)
+			if err != nil {
+				return nil, invalidProfile("birthday must be formatted as %s", birthdayLayout)
+			}
+			minAge, maxAge := ageRange()
+			if age := ageAt(birthday, time.Now()); age < minAge || age > maxAge {
+				return nil, invalidProfile("age must be between %d and %d", minAge, maxAge)
+			}
+			user.Birthday = birthday
+		}
+		cols = append(cols, "birthday")
+	}
+	if p.Height.Set {
+		if !p.Height.Null && (p.Height.Value < minHeight || p.Height.Value > maxHeight) {
+			return nil, invalidProfile("height must be between %d and %d cm", minHeight, maxHeight)
+		}
+		user.Height = p.Height.Value
+		cols = append(cols, "height")
+	}
+	if p.Weight.Set {
+		if !p.Weight.Null && (p.Weight.Value < minWeight || p.Weight.Value > maxWeight) {
+			return nil, invalidProfile("weight must be between %d and %d kg", minWeight, maxWeight)
+		}
+		user.Weight = p.Weight.Value
+		cols = append(cols, "weight")
+	}
+	if p.Desc.Set {
+		desc := strings.TrimSpace(p.Desc.Value)
+		if utf8.RuneCountInString(desc) > maxDescLen {
+			return nil, invalidProfile("desc must be at most %d characters", maxDescLen)
+		}
+		if w := bannedWord(desc); w != "" {
+			return nil, invalidProfile("desc contains banned word %q", w)
+		}
+		mod, err := ModerateText(user.ID, model.ModerationSceneDesc, desc)
+		if err != nil {
+			return nil, err
+		}
+		user.Desc = mod.Text
+		cols = append(cols, "desc")
+	}
+	if p.Photos.Set {
+		photos := p.Photos.Value
+		if photos == nil {
+			photos = []string{}
+		}
+		if err := checkProfilePhotos(user.ID, photos); err != nil {
+			return nil, err
+		}
+		user.Photos = photos
+		cols = append(cols, "photos")
+	}
+	return cols, nil
+}
+
+// 照片必须是自己通过上传服务存储的图片
+func checkProfilePhotos(uid uint, photos []string) error {
+	if len(photos) > maxProfilePhotos {
+		return ErrMediaLimit
+	}
+	if len(photos) == 0 {
+		return nil
+	}
+	files, err := model.GetStoredFilesByURLs(photos)
+	if err != nil {
+		return err
+	}
+	owned, err := model.GetOwnedFileIDs(uid, files)
+	if err != nil {
+		return err
+	}
+	usable := map[string]bool{}
+	for _, f := range files {
+		usable[f.URL] = owned[f.ID] && f.Status != model.FileStatusRejected && strings.HasPrefix(f.MimeType, "image/")
+	}
+	seen := map[string]bool{}
+	for _, url := range photos {
+		if !usable[url] || seen[url] {
+			return ErrInvalidMediaFile
+		}
+		seen[url] = true
+	}
+	return nil
+}
+
+// 部分更新个人资料，返回更新后的资料
+func PatchProfile(uid uint, patch *ProfilePatch) (interface{}, error) {
+	current, err := model.GetUserById(uid)
+	if err != nil {
+		return nil, err
+	}
+	user := &model.User{ID: uid}
+	cols, err := patch.apply(user)
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, ErrProfileNothingToSave
+	}
+
+	// 昵称修改限频，未变化时不计入
+	nicknameChanged := patch.Nickname.Set && user.Nickname != current.Nickname
+	if nicknameChanged {
+		interval := secondsOr(config.GetConf().Profile.NicknameInterval, 7*24*time.Hour)
+		ok, err := model.GetRds().SetNX(model.Ctx, buildNicknameLockKey(uid), 1, interval).Result()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNicknameTooFrequent
+		}
+	}
+	if err := model.UpdateUserColumns(user, cols); err != nil {
+		if nicknameChanged {
+			model.GetRds().Del(model.Ctx, buildNicknameLockKey(uid))
+		}
+		return nil, err
+	}
+	return GetUserProfile(uid, "", uid)
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"worldCity/config"
+)
+
+// PushMessage 发往一台设备的推送
+type PushMessage struct {
+	To    string                 `json:"to"`
+	Title string                 `json:"title,omitempty"`
+	Body  string                 `json:"body"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+	Badge int                    `json:"badge,omitempty"`
+	Sound string                 `json:"sound,omitempty"`
+}
+
+// PushResult 单条推送的发送结果，与请求中的消息一一对应
+type PushResult struct {
+	Token        string
+	InvalidToken bool // 令牌已失效，需要从登记表中删除
+	Err          error
+}
+
+// PushDispatcher 推送通道，接入推送服务商时实现该接口
+// Send 返回的 error 表示整批发送失败，单条失败放在 PushResult 中
+type PushDispatcher interface {
+	Send(ctx context.Context, msgs []PushMessage) ([]PushResult, error)
+}
+
+var pushDispatcher PushDispatcher
+
+// SetPushDispatcher 替换推送通道，为 nil 时不发送推送
+func SetPushDispatcher(d PushDispatcher) {
+	pushDispatcher = d
+}
+
+// InitPushDispatcher 根据配置选择推送通道
+func InitPushDispatcher() {
+	pushConf := config.GetConf().Push
+	switch pushConf.Driver {
+	case "expo":
+		SetPushDispatcher(&expoPushDispatcher{url: pushConf.ExpoURL, accessToken: pushConf.AccessToken})
+	case "fake":
+		SetPushDispatcher(NewFakePushDispatcher())
+	}
+}
+
+// Expo 一次请求最多 100 条
+const expoMaxBatch = 100
+
+// Expo 推送服务，https://docs.expo.dev/push-notifications/sending-notifications/
+type expoPushDispatcher struct {
+	url         string
+	accessToken string
+}
+
+var pushHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+type expoTicket struct {
+	Status  string `json:"status"`
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	Details struct {
+		Error string `json:"error"`
+	} `json:"details"`
+}
+
+func (e *expoPushDispatcher) Send(ctx context.Context, msgs []PushMessage) ([]PushResult, error) {
+	results := make([]PushResult, 0, len(msgs))
+	for start := 0; start < len(msgs); start += expoMaxBatch {
+		chunk := msgs[start:min(start+expoMaxBatch, len(msgs))]
+		tickets, err := e.send(ctx, chunk)
+		if err != nil {
+			return results, err
+		}
+		for i, m := range chunk {
+			res := PushResult{Token: m.To}
+			if i >= len(tickets) {
+				res.Err = errors.New("expo push: missing ticket")
+			} else if t := tickets[i]; t.Status != "ok" {
+				res.Err = fmt.Errorf("expo push: %s", t.Message)
+				res.InvalidToken = t.Details.Error == "DeviceNotRegistered"
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+func (e *expoPushDispatcher) send(ctx context.Context, msgs []PushMessage) ([]expoTicket, error) {
+	body, err := json.Marshal(msgs)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if e.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+e.accessToken)
+	}
+	resp, err := pushHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("expo push: status %d: %s", resp.StatusCode, data)
+	}
+	var out struct {
+		Data   []expoTicket `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Errors) > 0 {
+		return nil, fmt.Errorf("expo push: %s", out.Errors[0].Message)
+	}
+	return out.Data, nil
+}
+
+// 模拟通道最多保留的推送条数
+const fakePushKeep = 1000
+
+// FakePushDispatcher 本地模拟的推送通道，只记录和打印，以 invalid 开头的令牌视为已失效
+type FakePushDispatcher struct {
+	mu   sync.Mutex
+	sent []PushMessage
+}
+
+func NewFakePushDispatcher() *FakePushDispatcher {
+	return &FakePushDispatcher{}
+}
+
+func (f *FakePushDispatcher) Send(ctx context.Context, msgs []PushMessage) ([]PushResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	results := make([]PushResult, 0, len(msgs))
+	for _, m := range msgs {
+		res := PushResult{Token: m.To}
+		if strings.HasPrefix(m.To, "invalid") {
+			res.InvalidToken = true
+			res.Err = errors.New("fake push: device not registered")
+		} else {
+			f.sent = append(f.sent, m)
+			if len(f.sent) > fakePushKeep {
+				f.sent = f.sent[len(f.sent)-fakePushKeep:]
+			}
+			log.Printf("[push] to %s: %s", m.To, m.Body)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// Sent 返回已发送的推送
+func (f *FakePushDispatcher) Sent() []PushMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]PushMessage(nil), f.sent...)
+}
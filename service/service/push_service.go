@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+)
+
+var (
+	ErrInvalidPushPlatform = errors.New("invalid push platform")
+	ErrInvalidPushToken    = errors.New("invalid push token")
+	ErrInvalidQuietHours   = errors.New("invalid quiet hours")
+	ErrDeviceTokenNotFound = errors.New("device token not found")
+)
+
+const (
+	maxPushTokenLen  = 255
+	maxPushDeviceLen = 64
+	pushSendTimeout  = 30 * time.Second
+)
+
+var pushPlatforms = []string{model.PushPlatformIOS, model.PushPlatformAndroid, model.PushPlatformWeb}
+
+// 待推送的通知，队列满时丢弃，通知本身已经保存在通知中心
+var pushJobs = make(chan model.Notification, 1024)
+
+// 登记推送令牌，同一台设备重新安装后令牌会变化，旧令牌在推送失败时清理
+func RegisterDeviceToken(uid uint, platform, token, deviceID string) (*model.DeviceToken, error) {
+	if !slices.Contains(pushPlatforms, platform) {
+		return nil, ErrInvalidPushPlatform
+	}
+	if token == "" || len(token) > maxPushTokenLen || len(deviceID) > maxPushDeviceLen {
+		return nil, ErrInvalidPushToken
+	}
+	t := &model.DeviceToken{UserID: uid, Platform: platform, Token: token, DeviceID: deviceID}
+	if err := model.SaveDeviceToken(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func UnregisterDeviceToken(uid uint, token string) error {
+	n, err := model.DeleteDeviceToken(uid, token)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDeviceTokenNotFound
+	}
+	return nil
+}
+
+func GetDeviceTokens(uid uint) ([]model.DeviceToken, error) {
+	return model.GetDeviceTokens(uid)
+}
+
+func GetPushSetting(uid uint) (*model.PushSetting, error) {
+	settings, err := model.GetPushSettings([]uint{uid})
+	if err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return &model.PushSetting{UserID: uid}, nil
+	}
+	return &settings[0], nil
+}
+
+// 设置免打扰时段，开始和结束都为空时关闭
+func UpdatePushSetting(uid uint, quietStart, quietEnd, timezone string) (*model.PushSetting, error) {
+	if (quietStart == "") != (quietEnd == "") {
+		return nil, ErrInvalidQuietHours
+	}
+	if quietStart != "" {
+		start, err1 := parseClock(quietStart)
+		end, err2 := parseClock(quietEnd)
+		if err1 != nil || err2 != nil || start == end {
+			return nil, ErrInvalidQuietHours
+		}
+	}
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, ErrInvalidQuietHours
+		}
+	}
+	s := &model.PushSetting{UserID: uid, QuietStart: quietStart, QuietEnd: quietEnd, Timezone: timezone}
+	if err := model.SavePushSetting(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// HH:MM 转为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// 是否处于免打扰时段，时段可以跨过零点，例如 22:00 到 08:00
+func inQuietHours(s *model.PushSetting, now time.Time) bool {
+	if s == nil || s.QuietStart == "" || s.QuietEnd == "" {
+		return false
+	}
+	start, err1 := parseClock(s.QuietStart)
+	end, err2 := parseClock(s.QuietEnd)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			now = now.In(loc)
+		}
+	}
+	cur := now.Hour()*60 + now.Minute()
+	if start < end {
+		return cur >= start && cur < end
+	}
+	return cur >= start || cur < end
+}
+
+// 新通知加入推送队列，通知设置已在创建通知时检查
+func enqueuePush(n *model.Notification) {
+	if pushDispatcher == nil {
+		return
+	}
+	select {
+	case pushJobs <- *n:
+	default:
+		log.Printf("push queue full, drop notification %d", n.ID)
+	}
+}
+
+func pushBatchSize() int {
+	return min(intOr(config.GetConf().Push.BatchSize, expoMaxBatch), 1000)
+}
+
+func PushBatchInterval() time.Duration {
+	return secondsOr(config.GetConf().Push.BatchInterval, 2*time.Second)
+}
+
+// RunPushWorker 攒批发送推送，攒够 batch_size 条或等待 interval 后发送
+func RunPushWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var batch []model.Notification
+	for {
+		select {
+		case n := <-pushJobs:
+			batch = append(batch, n)
+			if len(batch) < pushBatchSize() {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := sendPushBatch(batch, time.Now()); err != nil {
+			log.Println("push batch error:", err)
+		}
+		batch = nil
+	}
+}
+
+// 过滤免打扰的用户，把通知发到用户登记的所有设备，并清理失效的令牌
+func sendPushBatch(batch []model.Notification, now time.Time) error {
+	dispatcher := pushDispatcher
+	if dispatcher == nil {
+		return nil
+	}
+	var userIDs []uint
+	for _, n := range batch {
+		if !slices.Contains(userIDs, n.UserID) {
+			userIDs = append(userIDs, n.UserID)
+		}
+	}
+	settings, err := model.GetPushSettings(userIDs)
+	if err != nil {
+		return err
+	}
+	quiet := make(map[uint]bool, len(settings))
+	for i := range settings {
+		quiet[settings[i].UserID] = inQuietHours(&settings[i], now)
+	}
+	tokens, err := model.GetDeviceTokensByUsers(userIDs)
+	if err != nil {
+		return err
+	}
+	devices := make(map[uint][]string, len(userIDs))
+	for _, t := range tokens {
+		devices[t.UserID] = append(devices[t.UserID], t.Token)
+	}
+	views, err := toNotificationViews(batch)
+	if err != nil {
+		return err
+	}
+
+	var msgs []PushMessage
+	for _, v := range views {
+		if quiet[v.UserID] {
+			continue
+		}
+		for _, token := range devices[v.UserID] {
+			msgs = append(msgs, PushMessage{
+				To:    token,
+				Body:  v.Text,
+				Sound: "default",
+				Data: map[string]interface{}{
+					"notification_id": v.ID,
+					"type":            v.Type,
+					"target_type":     v.TargetType,
+					"target_id":       v.TargetID,
+				},
+			})
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushSendTimeout)
+	defer cancel()
+	results, err := dispatcher.Send(ctx, msgs)
+	var invalid []string
+	failed := 0
+	for _, r := range results {
+		if r.InvalidToken && !slices.Contains(invalid, r.Token) {
+			invalid = append(invalid, r.Token)
+		} else if r.Err != nil {
+			failed++
+		}
+	}
+	if len(invalid) > 0 {
+		if derr := model.DeleteDeviceTokens(invalid); derr != nil {
+			log.Println("delete invalid push tokens error:", derr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pushes failed", failed, len(msgs))
+	}
+	return nil
+}
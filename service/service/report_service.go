@@ -0,0 +1,255 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"worldCity/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidReport      = errors.New("invalid report target or reason")
+	ErrReportDuplicate    = errors.New("you have already reported this content")
+	ErrReportSelf         = errors.New("cannot report yourself")
+	ErrReportNotFound     = errors.New("report not found")
+	ErrReportHandled      = errors.New("report has already been handled")
+	ErrInvalidReportAct   = errors.New("invalid report action")
+	ErrReportTargetGone   = errors.New("reported content no longer exists")
+	ErrInvalidBanDuration = errors.New("ban days must be between 1 and 365")
+)
+
+const (
+	defaultReportPageSize = 20
+	maxReportPageSize     = 100
+	maxTempBanDays        = 365
+)
+
+var reportReasons = []string{"spam", "harassment", "sexual", "violence", "fraud", "illegal", "other"}
+
+// 被举报用户的资料快照，只包含公开资料
+func userEvidence(user *model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"nickname": user.Nickname,
+		"avatar":   user.Avatar,
+		"desc":     user.Desc,
+		"photos":   user.Photos,
+	}
+}
+
+// 举报时保存被举报内容的快照，返回内容作者
+func reportEvidence(reporterID uint, targetType string, targetID uint) (uint, map[string]interface{}, error) {
+	switch targetType {
+	case model.ReportTargetUser:
+		user, err := model.GetUserById(targetID)
+		if err != nil {
+			return 0, nil, ErrReportTargetGone
+		}
+		return user.ID, userEvidence(user), nil
+	case model.ReportTargetMoment:
+		moment, err := model.GetMomentById(targetID)
+		if err != nil {
+			return 0, nil, ErrReportTargetGone
+		}
+		return moment.UserID, map[string]interface{}{
+			"content": moment.Content,
+			"images":  moment.Images,
+		}, nil
+	case model.ReportTargetComment:
+		comment, err := model.GetMomentCommentById(targetID)
+		if err != nil {
+			return 0, nil, ErrReportTargetGone
+		}
+		return comment.UserID, map[string]interface{}{
+			"moment_id": comment.MomentID,
+			"content":   comment.Content,
+		}, nil
+	case model.ReportTargetMessage:
+		msg, err := model.GetMessageById(targetID)
+		if err != nil || msg.Recalled {
+			return 0, nil, ErrReportTargetGone
+		}
+		// 只能举报自己参与的会话中对方发送的消息
+		if msg.ReceiverID != reporterID {
+			return 0, nil, ErrNotMessageMember
+		}
+		return msg.SenderID, map[string]interface{}{
+			"content_type": msg.ContentType,
+			"content":      msg.Content,
+			"timestamp":    msg.Timestamp,
+		}, nil
+	}
+	return 0, nil, ErrInvalidReport
+}
+
+// 提交举报，同一对象在处理前不能重复举报
+func CreateReport(reporterID uint, targetType string, targetID uint, reason, detail string) (*model.Report, error) {
+	if targetID == 0 || !slices.Contains(reportReasons, reason) {
+		return nil, ErrInvalidReport
+	}
+	targetUserID, evidence, err := reportEvidence(reporterID, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if targetUserID == reporterID {
+		return nil, ErrReportSelf
+	}
+	exists, err := model.HasPendingReport(reporterID, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrReportDuplicate
+	}
+	report := &model.Report{
+		ReporterID:   reporterID,
+		TargetType:   targetType,
+		TargetID:     targetID,
+		TargetUserID: targetUserID,
+		Reason:       reason,
+		Detail:       detail,
+		Evidence:     evidence,
+		Status:       model.ReportStatusPending,
+	}
+	if err := model.CreateReport(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// 审核队列，status 为空时返回全部
+func ListReports(status string, page, size int) (map[string]interface{}, error) {
+	page, size = pageOr(page, size, defaultReportPageSize, maxReportPageSize)
+	reports, total, err := model.GetReports(status, (page-1)*size, size)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total":     total,
+		"page":      page,
+		"page_size": size,
+		"reports":   reports,
+	}, nil
+}
+
+// 下架被举报的内容，用户资料不能下架
+func takedownReportTarget(operatorID uint, report *model.Report) error {
+	switch report.TargetType {
+	case model.ReportTargetMoment:
+		return model.TakedownMoment(report.TargetID, operatorID, "report: "+report.Reason)
+	case model.ReportTargetComment:
+		return model.TakedownMomentComment(report.TargetID)
+	case model.ReportTargetMessage:
+		msg, err := model.GetMessageById(report.TargetID)
+		if err != nil {
+			return err
+		}
+		if msg.Recalled {
+			return nil
+		}
+		err = model.ReviseMessage(&model.Message{}, &model.MessageRevision{
+			Scope:       model.MsgScopeP2P,
+			MessageID:   msg.ID,
+			Action:      model.MsgActionTakedown,
+			OperatorID:  operatorID,
+			ContentType: msg.ContentType,
+			Content:     msg.Content,
+		}, map[string]interface{}{"recalled": true, "content": ""})
+		if err != nil {
+			return err
+		}
+		notifyAsync(operatorID, []uint{msg.SenderID, msg.ReceiverID}, &ChatEvent{
+			Event:      ChatEventRecalled,
+			Scope:      model.MsgScopeP2P,
+			MessageID:  msg.ID,
+			OperatorID: operatorID,
+		})
+		return nil
+	}
+	return ErrInvalidReportAct
+}
+
+// 处理举报：驳回、警告、下架内容、临时或永久封禁作者
+func ResolveReport(operatorID, reportID uint, action, note string, banDays int) (*model.Report, error) {
+	report, err := model.GetReportById(reportID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if report.Status != model.ReportStatusPending {
+		return nil, ErrReportHandled
+	}
+
+	status := model.ReportStatusResolved
+	var sanction *model.UserSanction
+	switch action {
+	case model.ReportActionDismiss:
+		status = model.ReportStatusDismissed
+	case model.ReportActionTakedown:
+		if err := takedownReportTarget(operatorID, report); err != nil {
+			return nil, err
+		}
+	case model.ReportActionWarn, model.ReportActionPermBan:
+		sanction = &model.UserSanction{}
+	case model.ReportActionTempBan:
+		if banDays < 1 || banDays > maxTempBanDays {
+			return nil, ErrInvalidBanDuration
+		}
+		expires := time.Now().AddDate(0, 0, banDays)
+		sanction = &model.UserSanction{ExpiresAt: &expires}
+	default:
+		return nil, ErrInvalidReportAct
+	}
+	if sanction != nil {
+		sanction.UserID = report.TargetUserID
+		sanction.Action = action
+		sanction.Reason = note
+		sanction.ReportID = report.ID
+		sanction.OperatorID = operatorID
+	}
+
+	if err := model.ResolveReports(report, status, action, operatorID, note, sanction); err != nil {
+		return nil, err
+	}
+	// 封禁后注销所有会话，立即下线并停止推送
+	if action == model.ReportActionTempBan || action == model.ReportActionPermBan {
+		if err := endUserSessions(report.TargetUserID); err != nil {
+			return nil, err
+		}
+	}
+	notifyReportResolved(report, action, sanction)
+	return model.GetReportById(reportID)
+}
+
+func GetUserSanctions(userID uint) ([]model.UserSanction, error) {
+	return model.GetUserSanctions(userID)
+}
+
+// 通知举报人处理结果，被处罚的用户收到处罚通知
+func notifyReportResolved(report *model.Report, action string, sanction *model.UserSanction) {
+	data := map[string]interface{}{"report_id": report.ID, "action": action}
+	if err := SendSystemNotification(report.ReporterID, "Your report has been reviewed. Thank you for helping keep the community safe.", data); err != nil {
+		log.Printf("notify reporter of report %d failed: %v", report.ID, err)
+	}
+	if sanction == nil {
+		return
+	}
+	content := "Your account received a warning for violating community guidelines."
+	switch action {
+	case model.ReportActionTempBan:
+		content = fmt.Sprintf("Your account is suspended until %s for violating community guidelines.", sanction.ExpiresAt.Format(time.DateOnly))
+	case model.ReportActionPermBan:
+		content = "Your account is permanently suspended for violating community guidelines."
+	}
+	if sanction.Reason != "" {
+		content += " Reason: " + sanction.Reason
+	}
+	if err := SendSystemNotification(sanction.UserID, content, data); err != nil {
+		log.Printf("notify sanction of report %d failed: %v", report.ID, err)
+	}
+}
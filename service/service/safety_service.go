@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"worldCity/model"
+)
+
+var (
+	ErrBlocked         = errors.New("you cannot interact with this user")
+	ErrCannotBlockSelf = errors.New("cannot block or mute yourself")
+	ErrMomentNotFound  = errors.New("moment not found")
+	ErrAccountBanned   = errors.New("account is banned")
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrUserNotFound    = errors.New("user not found")
+)
+
+// 双方存在拉黑关系时返回 ErrBlocked
+func checkNotBlocked(uid1, uid2 uint) error {
+	if uid1 == 0 || uid2 == 0 || uid1 == uid2 {
+		return nil
+	}
+	blocked, err := model.IsBlockedBetween(uid1, uid2)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
+
+func BlockUser(uid, blockedID uint) error {
+	if uid == blockedID {
+		return ErrCannotBlockSelf
+	}
+	if _, err := model.GetUserById(blockedID); err != nil {
+		return ErrUserNotFound
+	}
+	return model.CreateBlock(uid, blockedID)
+}
+
+func UnblockUser(uid, blockedID uint) error {
+	return model.DeleteBlock(uid, blockedID)
+}
+
+func GetBlockedUsers(uid uint) ([]model.UserBlock, error) {
+	return model.GetBlocks(uid)
+}
+
+func MuteUser(uid, mutedID uint) error {
+	if uid == mutedID {
+		return ErrCannotBlockSelf
+	}
+	if _, err := model.GetUserById(mutedID); err != nil {
+		return ErrUserNotFound
+	}
+	return model.CreateMute(uid, mutedID)
+}
+
+func UnmuteUser(uid, mutedID uint) error {
+	return model.DeleteMute(uid, mutedID)
+}
+
+func GetMutedUsers(uid uint) ([]model.UserMute, error) {
+	return model.GetMutes(uid)
+}
+
+// 动态流中不展示的用户：存在拉黑关系或被自己屏蔽
+func hiddenFeedUserIDs(uid uint) ([]uint, error) {
+	blocked, err := model.GetBlockRelatedUserIDs(uid)
+	if err != nil {
+		return nil, err
+	}
+	muted, err := model.GetMutedUserIDs(uid)
+	if err != nil {
+		return nil, err
+	}
+	return append(blocked, muted...), nil
+}
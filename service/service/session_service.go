@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/utils"
+)
+
+var ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+func accessTokenTTL() time.Duration {
+	return secondsOr(config.GetConf().JWT.AccessTTL, defaultAccessTokenTTL)
+}
+
+func refreshTokenTTL() time.Duration {
+	return secondsOr(config.GetConf().JWT.RefreshTTL, defaultRefreshTokenTTL)
+}
+
+// 刷新令牌格式为 会话ID.随机串，服务端只保存随机串的哈希
+func buildRefreshToken(sid string) (token, hash string) {
+	secret := utils.RandomString(40)
+	return sid + "." + secret, utils.Sha1(secret)
+}
+
+func parseRefreshToken(token string) (sid, hash string, ok bool) {
+	sid, secret, ok := strings.Cut(token, ".")
+	if !ok || sid == "" || secret == "" {
+		return "", "", false
+	}
+	return sid, utils.Sha1(secret), true
+}
+
+func buildTokenResp(user *model.User, sid, refreshToken string) (map[string]interface{}, error) {
+	ttl := accessTokenTTL()
+	token, err := utils.GenerateToken(uint64(user.ID), user.AccId, user.Role, sid, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"token":         token,
+		"expires_in":    int(ttl.Seconds()),
+		"refresh_token": refreshToken,
+		"session_id":    sid,
+	}, nil
+}
+
+// 登录成功后创建会话，同一设备重复登录会替换之前的会话
+func createSession(user *model.User, client ClientInfo) (map[string]interface{}, error) {
+	if user.IsBanned() {
+		return nil, ErrAccountBanned
+	}
+	sid := utils.RandomString(24)
+	refreshToken, hash := buildRefreshToken(sid)
+	deviceID := client.DeviceID
+	if deviceID == "" {
+		deviceID = sid
+	}
+	now := time.Now()
+	session := &model.Session{
+		ID:           sid,
+		UserID:       user.ID,
+		DeviceID:     deviceID,
+		DeviceName:   client.DeviceName,
+		IP:           client.IP,
+		UserAgent:    client.UserAgent,
+		RefreshHash:  hash,
+		CreatedAt:    now,
+		LastActiveAt: now,
+	}
+	if err := model.SaveSession(session, refreshTokenTTL()); err != nil {
+		return nil, err
+	}
+	return buildTokenResp(user, sid, refreshToken)
+}
+
+// 用刷新令牌换取新的访问令牌，刷新令牌同时轮换
+// 旧的刷新令牌被再次使用时视为泄露，直接注销该会话
+func RefreshToken(refreshToken string) (map[string]interface{}, error) {
+	sid, hash, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return nil, ErrRefreshTokenInvalid
+	}
+	newToken, newHash := buildRefreshToken(sid)
+	session, err := model.RotateSessionRefresh(sid, hash, newHash, refreshTokenTTL())
+	if errors.Is(err, model.ErrSessionNotFound) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if errors.Is(err, model.ErrRefreshTokenReused) {
+		if s, err := model.GetSession(sid); err == nil {
+			endSession(s)
+		}
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := model.GetUserById(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsBanned() {
+		endSession(session)
+		return nil, ErrAccountBanned
+	}
+	return buildTokenResp(user, sid, newToken)
+}
+
+// 注销当前会话
+func Logout(uid uint, sid string) error {
+	session, err := model.GetSession(sid)
+	if errors.Is(err, model.ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if session.UserID != uid {
+		return nil
+	}
+	return endSession(session)
+}
+
+// 注销所有设备
+func LogoutAll(uid uint) error {
+	return endUserSessions(uid)
+}
+
+// 删除会话和该设备的推送令牌，注销后不再向设备推送
+func endSession(s *model.Session) error {
+	if err := model.DeleteSession(s); err != nil {
+		return err
+	}
+	return model.DeleteDeviceTokensByDevice(s.UserID, s.DeviceID)
+}
+
+// 删除用户的全部会话和推送令牌
+func endUserSessions(uid uint) error {
+	if err := model.DeleteUserSessions(uid); err != nil {
+		return err
+	}
+	return model.DeleteUserDeviceTokens(uid)
+}
+
+func GetSessions(uid uint, currentSid string) ([]map[string]interface{}, error) {
+	sessions, err := model.GetUserSessions(uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]map[string]interface{}, 0, len(sessions))
+	for i := range sessions {
+		res = append(res, map[string]interface{}{
+			"session": sessions[i],
+			"current": sessions[i].ID == currentSid,
+		})
+	}
+	return res, nil
+}
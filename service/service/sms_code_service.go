@@ -0,0 +1,266 @@
+package service
+
+import (
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrCodeTooFrequent  = errors.New("verification code requested too frequently")
+	ErrCodeLimitReached = errors.New("verification code limit reached, try again later")
+	ErrCodeInvalid      = errors.New("verification code is invalid or expired")
+	ErrCodeLocked       = errors.New("too many wrong attempts, try again later")
+	ErrTicketInvalid    = errors.New("verification ticket is invalid or expired")
+	ErrInvalidSmsScene  = errors.New("invalid verification scene")
+)
+
+// 验证码使用场景，不同场景的验证码互不通用
+const (
+	SmsSceneRegister = "register"
+	SmsSceneLogin    = "login"
+)
+
+// 未配置时使用的默认值
+const (
+	defaultCodeTTL         = 5 * time.Minute
+	defaultCodeCooldown    = 60 * time.Second
+	defaultCodeMaxAttempts = 5
+	defaultPhoneDailyLimit = 10
+	defaultIPHourlyLimit   = 20
+	codeLockDuration       = 15 * time.Minute
+	registerTicketTTL      = 10 * time.Minute
+)
+
+func buildCodeKey(scene, phone string) string {
+	return fmt.Sprintf("verify:%s:%s", scene, phone)
+}
+
+func buildCodeCooldownKey(phone string) string {
+	return fmt.Sprintf("verify_cooldown:%s", phone)
+}
+
+func buildCodeDailyKey(phone string) string {
+	return fmt.Sprintf("verify_daily:%s", phone)
+}
+
+func buildCodeIPKey(ip string) string {
+	return fmt.Sprintf("verify_ip:%s", ip)
+}
+
+func buildCodeAttemptsKey(scene, phone string) string {
+	return fmt.Sprintf("verify_attempts:%s:%s", scene, phone)
+}
+
+func buildCodeLockKey(phone string) string {
+	return fmt.Sprintf("verify_lock:%s", phone)
+}
+
+func buildRegisterTicketKey(ticket string) string {
+	return fmt.Sprintf("register_ticket:%s", ticket)
+}
+
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return def
+}
+
+func intOr(n, def int) int {
+	if n > 0 {
+		return n
+	}
+	return def
+}
+
+// 生成 6 位数字验证码
+func generateCode() (string, error) {
+	n, err := crand.Int(crand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// 计数器加一，第一次计数时设置过期时间，返回加一后的值
+func incrWindow(rds *redis.Client, key string, window time.Duration) (int64, error) {
+	n, err := rds.Incr(model.Ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		rds.Expire(model.Ctx, key, window)
+	}
+	return n, nil
+}
+
+// 发送验证码，注册要求手机号未注册，登录要求已注册
+// 同一手机号有冷却时间和每日上限，同一 IP 有每小时上限
+func SendCode(scene, phone, ip string) error {
+	if scene == "" {
+		scene = SmsSceneRegister
+	}
+	if scene != SmsSceneRegister && scene != SmsSceneLogin {
+		return ErrInvalidSmsScene
+	}
+	ok, err := model.IsUserExisted(phone)
+	if err != nil {
+		return err
+	}
+	if scene == SmsSceneRegister && ok {
+		return ErrUserExists
+	}
+	if scene == SmsSceneLogin && !ok {
+		return ErrUserNotRegistered
+	}
+	return sendVerifyCode(scene, phone, ip)
+}
+
+func sendVerifyCode(scene, phone, ip string) error {
+	smsConf := config.GetConf().SMS
+	rds := model.GetRds()
+
+	locked, err := rds.Exists(model.Ctx, buildCodeLockKey(phone)).Result()
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		return ErrCodeLocked
+	}
+	// 冷却期内不允许重复发送
+	ok, err := rds.SetNX(model.Ctx, buildCodeCooldownKey(phone), 1, secondsOr(smsConf.Cooldown, defaultCodeCooldown)).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCodeTooFrequent
+	}
+	if ip != "" {
+		n, err := incrWindow(rds, buildCodeIPKey(ip), time.Hour)
+		if err != nil {
+			return err
+		}
+		if n > int64(intOr(smsConf.IPHourlyLimit, defaultIPHourlyLimit)) {
+			// 没有发送验证码，不占用冷却时间
+			rds.Del(model.Ctx, buildCodeCooldownKey(phone))
+			return ErrCodeLimitReached
+		}
+	}
+	n, err := incrWindow(rds, buildCodeDailyKey(phone), 24*time.Hour)
+	if err != nil {
+		return err
+	}
+	if n > int64(intOr(smsConf.PhoneDailyLimit, defaultPhoneDailyLimit)) {
+		rds.Del(model.Ctx, buildCodeCooldownKey(phone))
+		return ErrCodeLimitReached
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return err
+	}
+	// 新验证码重置错误次数
+	_, err = rds.TxPipelined(model.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(model.Ctx, buildCodeKey(scene, phone), code, secondsOr(smsConf.CodeTTL, defaultCodeTTL))
+		pipe.Del(model.Ctx, buildCodeAttemptsKey(scene, phone))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := smsSender.SendCode(phone, code); err != nil {
+		// 发送失败时允许立即重试
+		rds.Del(model.Ctx, buildCodeKey(scene, phone), buildCodeCooldownKey(phone))
+		return err
+	}
+	return nil
+}
+
+// 校验验证码，验证码只能使用一次，错误次数过多时锁定一段时间
+func checkVerifyCode(scene, phone, code string) error {
+	maxAttempts := int64(intOr(config.GetConf().SMS.MaxAttempts, defaultCodeMaxAttempts))
+	rds := model.GetRds()
+
+	locked, err := rds.Exists(model.Ctx, buildCodeLockKey(phone)).Result()
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		return ErrCodeLocked
+	}
+	expected, err := rds.Get(model.Ctx, buildCodeKey(scene, phone)).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrCodeInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if expected != code {
+		n, err := incrWindow(rds, buildCodeAttemptsKey(scene, phone), codeLockDuration)
+		if err != nil {
+			return err
+		}
+		if n >= maxAttempts {
+			rds.Set(model.Ctx, buildCodeLockKey(phone), 1, codeLockDuration)
+			rds.Del(model.Ctx, buildCodeKey(scene, phone), buildCodeAttemptsKey(scene, phone))
+			return ErrCodeLocked
+		}
+		return ErrCodeInvalid
+	}
+	// 删除成功的请求才算消费了验证码，避免并发重复使用
+	n, err := rds.Del(model.Ctx, buildCodeKey(scene, phone)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCodeInvalid
+	}
+	rds.Del(model.Ctx, buildCodeAttemptsKey(scene, phone))
+	return nil
+}
+
+// 注册验证码校验通过后返回短期有效的注册凭证
+func VerifyCode(phone, code string) (map[string]interface{}, error) {
+	if err := checkVerifyCode(SmsSceneRegister, phone, code); err != nil {
+		return nil, err
+	}
+	ticket := utils.RandomString(32)
+	if err := model.GetRds().Set(model.Ctx, buildRegisterTicketKey(ticket), phone, registerTicketTTL).Err(); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"ticket":     ticket,
+		"expires_in": int(registerTicketTTL.Seconds()),
+	}, nil
+}
+
+// 注册凭证必须和注册的手机号一致
+func checkRegisterTicket(phone, ticket string) error {
+	owner, err := model.GetRds().Get(model.Ctx, buildRegisterTicketKey(ticket)).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrTicketInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if owner != phone {
+		return ErrTicketInvalid
+	}
+	return nil
+}
+
+// 注册成功后才消费凭证，注册失败时可以用同一凭证重试
+func consumeRegisterTicket(ticket string) {
+	if err := model.GetRds().Del(model.Ctx, buildRegisterTicketKey(ticket)).Err(); err != nil {
+		log.Printf("consume register ticket error: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package service
+
+import "log"
+
+// SmsSender 短信发送通道，接入短信服务商时实现该接口
+type SmsSender interface {
+	SendCode(phone, code string) error
+}
+
+// 默认只打印到日志，方便本地开发和测试
+type consoleSmsSender struct{}
+
+func (consoleSmsSender) SendCode(phone, code string) error {
+	log.Printf("[sms] send code %s to %s", code, phone)
+	return nil
+}
+
+var smsSender SmsSender = consoleSmsSender{}
+
+// SetSmsSender 替换短信发送通道
+func SetSmsSender(s SmsSender) {
+	smsSender = s
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+	"worldCity/config"
+	"worldCity/model"
+	"worldCity/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTopicNotFound = errors.New("topic not found")
+	ErrInvalidTopic  = errors.New("invalid topic")
+)
+
+// 各种互动计入话题热度的权重
+const (
+	topicWeightPost    = 3
+	topicWeightComment = 2
+	topicWeightLike    = 1
+
+	defaultFeedPageSize = 20
+	maxFeedPageSize     = 50
+	maxTrendingTopics   = 50
+)
+
+func topicMaxPerMoment() int {
+	return intOr(config.GetConf().Topic.MaxPerMoment, 10)
+}
+
+func topicTrendingWindow() time.Duration {
+	return time.Duration(intOr(config.GetConf().Topic.TrendingWindow, 48)) * time.Hour
+}
+
+func topicTrendingHalfLife() time.Duration {
+	return time.Duration(intOr(config.GetConf().Topic.TrendingHalfLife, 6)) * time.Hour
+}
+
+// 从动态内容中提取话题并关联，返回关联的话题
+func saveMomentTopics(moment *model.Moment) ([]uint, error) {
+	tags := utils.ExtractHashtags(moment.Content)
+	if len(tags) > topicMaxPerMoment() {
+		tags = tags[:topicMaxPerMoment()]
+	}
+	names := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		names[utils.HashtagKey(tag)] = tag
+	}
+	topics, err := model.GetOrCreateTopics(names)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(topics))
+	for _, t := range topics {
+		ids = append(ids, t.ID)
+	}
+	if err := model.SetMomentTopics(moment.ID, ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// 动态的互动计入所关联话题的热度，未通过审核的动态不计入
+// 只有所有人可见的动态计入热门话题
+func countsForTrending(moment *model.Moment) bool {
+	return moment.ReviewStatus == model.ReviewStatusApproved && moment.Visibility == model.MomentVisibilityPublic
+}
+
+func recordTopicEngagement(moment *model.Moment, weight float64) error {
+	if !countsForTrending(moment) {
+		return nil
+	}
+	ids, err := model.GetMomentTopicIDs(moment.ID)
+	if err != nil {
+		return err
+	}
+	return model.IncrTopicEngagement(ids, weight, time.Now(), topicTrendingWindow())
+}
+
+func recordTopicEngagementAsync(moment *model.Moment, weight float64) {
+	go func() {
+		if err := recordTopicEngagement(moment, weight); err != nil {
+			log.Printf("record topic engagement for moment %d error: %v", moment.ID, err)
+		}
+	}()
+}
+
+// 发布或修改动态后更新话题，失败只打日志不影响发布
+func updateMomentTopics(moment *model.Moment, isNew bool) {
+	ids, err := saveMomentTopics(moment)
+	if err != nil {
+		log.Printf("save topics for moment %d error: %v", moment.ID, err)
+		return
+	}
+	if isNew && len(ids) > 0 && countsForTrending(moment) {
+		if err := model.IncrTopicEngagement(ids, topicWeightPost, time.Now(), topicTrendingWindow()); err != nil {
+			log.Printf("record topic engagement for moment %d error: %v", moment.ID, err)
+		}
+	}
+}
+
+func loadTopic(name string) (*model.Topic, error) {
+	if name == "" {
+		return nil, ErrInvalidTopic
+	}
+	topic, err := model.GetTopicByKey(utils.HashtagKey(name))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTopicNotFound
+	}
+	return topic, err
+}
+
+// TopicView 话题详情
+type TopicView struct {
+	*model.Topic
+	MomentCount   int64 `json:"moment_count"`
+	FollowerCount int64 `json:"follower_count"`
+	Following     bool  `json:"following"`
+}
+
+func GetTopic(viewerID uint, name string) (*TopicView, error) {
+	topic, err := loadTopic(name)
+	if err != nil {
+		return nil, err
+	}
+	view := &TopicView{Topic: topic}
+	if view.MomentCount, err = model.CountTopicMoments(topic.ID); err != nil {
+		return nil, err
+	}
+	if view.FollowerCount, err = model.CountTopicFollowers(topic.ID); err != nil {
+		return nil, err
+	}
+	if view.Following, err = model.IsFollowingTopic(viewerID, topic.ID); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// 话题下的动态，不展示与查看者存在拉黑关系以及被屏蔽的用户
+func GetTopicMoments(viewerID uint, name string, page, size int) ([]MomentSummy, error) {
+	topic, err := loadTopic(name)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := hiddenFeedUserIDs(viewerID)
+	if err != nil {
+		return nil, err
+	}
+	page, size = pageOr(page, size, defaultFeedPageSize, maxFeedPageSize)
+	moments, err := model.GetFeedMoments(model.MomentFeedFilter{
+		ViewerID:       viewerID,
+		TopicIDs:       []uint{topic.ID},
+		ExcludeUserIDs: hidden,
+		Offset:         (page - 1) * size,
+		Limit:          size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildMomentSummaries(viewerID, moments)
+}
+
+// 时间线：自己和关注的用户发布的动态，以及关注的话题下的动态
+func GetTimeline(uid uint, page, size int) ([]MomentSummy, error) {
+	followees, err := model.GetFolloweeIDs(uid)
+	if err != nil {
+		return nil, err
+	}
+	topicIDs, err := model.GetFollowedTopicIDs(uid)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := hiddenFeedUserIDs(uid)
+	if err != nil {
+		return nil, err
+	}
+	page, size = pageOr(page, size, defaultFeedPageSize, maxFeedPageSize)
+	moments, err := model.GetFeedMoments(model.MomentFeedFilter{
+		ViewerID:       uid,
+		UserIDs:        append(followees, uid),
+		TopicIDs:       topicIDs,
+		ExcludeUserIDs: hidden,
+		Offset:         (page - 1) * size,
+		Limit:          size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildMomentSummaries(uid, moments)
+}
+
+func FollowTopic(uid uint, name string) error {
+	topic, err := loadTopic(name)
+	if err != nil {
+		return err
+	}
+	return model.FollowTopic(uid, topic.ID)
+}
+
+func UnfollowTopic(uid uint, name string) error {
+	topic, err := loadTopic(name)
+	if err != nil {
+		return err
+	}
+	return model.UnfollowTopic(uid, topic.ID)
+}
+
+// 关注的话题，最近关注的在前
+func GetFollowedTopics(uid uint) ([]model.Topic, error) {
+	ids, err := model.GetFollowedTopicIDs(uid)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := model.GetTopicsByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.Topic, len(topics))
+	for _, t := range topics {
+		byID[t.ID] = t
+	}
+	res := make([]model.Topic, 0, len(ids))
+	for _, id := range ids {
+		if t, ok := byID[id]; ok {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+// TrendingTopic 热门话题，Score 为时间衰减后的互动分数
+type TrendingTopic struct {
+	model.Topic
+	Score float64 `json:"score"`
+}
+
+func GetTrendingTopics(limit int) ([]TrendingTopic, error) {
+	if limit <= 0 || limit > maxTrendingTopics {
+		limit = maxTrendingTopics
+	}
+	scores, err := model.GetTrendingTopicScores(time.Now(), topicTrendingWindow(), topicTrendingHalfLife(), limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(scores))
+	for _, s := range scores {
+		ids = append(ids, s.TopicID)
+	}
+	topics, err := model.GetTopicsByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.Topic, len(topics))
+	for _, t := range topics {
+		byID[t.ID] = t
+	}
+	res := make([]TrendingTopic, 0, len(scores))
+	for _, s := range scores {
+		if t, ok := byID[s.TopicID]; ok {
+			res = append(res, TrendingTopic{Topic: t, Score: s.Score})
+		}
+	}
+	return res, nil
+}
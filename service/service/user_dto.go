@@ -0,0 +1,115 @@
+package service
+
+import (
+	"time"
+	"worldCity/model"
+)
+
+// 用户信息统一通过下面的结构返回，不直接序列化 model.User，避免泄露密码哈希等字段
+
+// UserBrief 嵌在动态、服务等数据中的用户信息
+type UserBrief struct {
+	ID       uint   `json:"id"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	Gender   uint   `json:"gender"`
+}
+
+// PublicProfile 其他用户可见的资料
+type PublicProfile struct {
+	ID        uint           `json:"id"`
+	Nickname  string         `json:"nickname"`
+	Avatar    string         `json:"avatar"`
+	Gender    uint           `json:"gender"`
+	Age       int            `json:"age,omitempty"`
+	Height    uint           `json:"height"`
+	Weight    uint           `json:"weight"`
+	Desc      string         `json:"desc"`
+	Photos    []string       `json:"photos"`
+	Videos    []ProfileVideo `json:"videos"`
+	CallPrice uint           `json:"call_price"`
+	Tags      []model.Tags   `json:"tags,omitempty"`
+}
+
+// SelfProfile 用户查看自己的资料
+type SelfProfile struct {
+	PublicProfile
+	Name       string    `json:"name"`
+	AccId      string    `json:"acc_id"`
+	Birthday   time.Time `json:"birthday"`
+	Coins      uint      `json:"coins"`
+	Role       string    `json:"role"`
+	MerchantId uint      `json:"merchant_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AdminProfile 管理员查看的资料
+type AdminProfile struct {
+	SelfProfile
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func ToUserBrief(u *model.User) *UserBrief {
+	return &UserBrief{ID: u.ID, Nickname: u.Nickname, Avatar: u.Avatar, Gender: u.Gender}
+}
+
+func toPublicProfile(u *model.User, videos []ProfileVideo) PublicProfile {
+	p := PublicProfile{
+		ID:        u.ID,
+		Nickname:  u.Nickname,
+		Avatar:    u.Avatar,
+		Gender:    u.Gender,
+		Height:    u.Height,
+		Weight:    u.Weight,
+		Desc:      u.Desc,
+		Photos:    u.Photos,
+		Videos:    videos,
+		CallPrice: u.CallPrice,
+	}
+	if !u.Birthday.IsZero() && !u.Birthday.Equal(emptyBirthday) {
+		p.Age = ageAt(u.Birthday, time.Now())
+	}
+	if p.Photos == nil {
+		p.Photos = []string{}
+	}
+	return p
+}
+
+func toSelfProfile(u *model.User, videos []ProfileVideo) SelfProfile {
+	return SelfProfile{
+		PublicProfile: toPublicProfile(u, videos),
+		Name:          u.Name,
+		AccId:         u.AccId,
+		Birthday:      u.Birthday,
+		Coins:         u.Coins,
+		Role:          u.Role,
+		MerchantId:    u.MerchantId,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
+// ProfileFor 按查看者和被查看用户的关系选择返回的资料：
+// 本人返回 SelfProfile，有用户管理权限的返回 AdminProfile，其他返回 PublicProfile
+func ProfileFor(viewerID uint, viewerRole string, u *model.User, tags []model.Tags) (interface{}, error) {
+	if model.HasPermission(viewerRole, model.PermManageUsers) {
+		videos := make([]ProfileVideo, len(u.Videos))
+		for i, v := range u.Videos {
+			videos[i] = ProfileVideo{VideoInfo: v}
+		}
+		p := &AdminProfile{SelfProfile: toSelfProfile(u, videos), UpdatedAt: u.UpdatedAt}
+		p.Tags = tags
+		return p, nil
+	}
+	videos, err := visibleVideos(viewerID, u)
+	if err != nil {
+		return nil, err
+	}
+	if viewerID == u.ID {
+		p := toSelfProfile(u, videos)
+		p.Tags = tags
+		return &p, nil
+	}
+	p := toPublicProfile(u, videos)
+	p.Tags = tags
+	return &p, nil
+}
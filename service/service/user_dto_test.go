@@ -0,0 +1,112 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"worldCity/model"
+)
+
+// 任何返回给客户端的结构都不能包含这些字段
+var sensitiveKeys = []string{"password", "banned_until", "deleted_at", "hash"}
+
+func sensitiveUser() *model.User {
+	banned := time.Now().Add(time.Hour)
+	return &model.User{
+		ID:          7,
+		Name:        "alice",
+		Nickname:    "Alice",
+		Password:    "$2a$10$abcdefghijklmnopqrstuv",
+		Avatar:      "https://cdn.example.com/a.jpg",
+		AccId:       "acc7",
+		Photos:      []string{"https://cdn.example.com/p.jpg"},
+		Videos:      []model.VideoInfo{{ID: "v1", Title: "t", Access: model.VideoAccessPublic, Uri: "https://cdn.example.com/v.mp4"}},
+		Desc:        "hi",
+		Birthday:    time.Date(1995, 1, 1, 0, 0, 0, 0, time.UTC),
+		Coins:       10,
+		Role:        model.RoleUser,
+		BannedUntil: &banned,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		DeletedAt:   time.Now(),
+	}
+}
+
+// 递归检查 JSON 中所有对象的 key
+func findSensitiveKeys(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: marshal: %v", name, err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("%s: unmarshal: %v", name, err)
+	}
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				for _, key := range sensitiveKeys {
+					if k == key {
+						t.Errorf("%s: key %q found at %s", name, k, path)
+					}
+				}
+				walk(path+"."+k, child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(path+"[]", child)
+			}
+		}
+	}
+	walk(name, decoded)
+}
+
+func TestUserDTOsOmitSensitiveFields(t *testing.T) {
+	u := sensitiveUser()
+	tags := []model.Tags{{ID: 1, UserId: u.ID, Tag: "music"}}
+
+	self, err := ProfileFor(u.ID, model.RoleUser, u, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := ProfileFor(u.ID+1, model.RoleUser, u, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := ProfileFor(u.ID+1, model.RoleSuperAdmin, u, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := self.(*SelfProfile); !ok {
+		t.Fatalf("self profile is %T", self)
+	}
+	if _, ok := public.(*PublicProfile); !ok {
+		t.Fatalf("public profile is %T", public)
+	}
+	if _, ok := admin.(*AdminProfile); !ok {
+		t.Fatalf("admin profile is %T", admin)
+	}
+
+	p := toPublicProfile(u, nil)
+	product := &model.Product{ID: 3, UserId: u.ID, User: *u, IsActive: true}
+	cases := map[string]interface{}{
+		"public_profile": public,
+		"self_profile":   self,
+		"admin_profile":  admin,
+		"user_brief":     ToUserBrief(u),
+		"discover_user":  DiscoverUser{PublicProfile: &p, Online: true},
+		"notification": &NotificationView{
+			Notification: &model.Notification{ID: 1, UserID: 8, Type: "like", ActorID: u.ID},
+			Actor:        ToUserBrief(u),
+		},
+		"product":         toProductView(product),
+		"provider_info":   ProviderInfo{Provider: toProductView(product)},
+		"report_evidence": &model.Report{ID: 1, ReporterID: 8, TargetUserID: u.ID, Evidence: userEvidence(u)},
+	}
+	for name, v := range cases {
+		findSensitiveKeys(t, name, v)
+	}
+}
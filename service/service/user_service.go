@@ -1,68 +1,53 @@
 package service
 
-import "worldCity/model"
+import (
+	"errors"
+	"worldCity/model"
+)
 
-func GetUserProfile(UserId uint) (map[string]interface{}, error) {
+// 获取用户信息，按查看者身份返回不同的资料
+func GetUserProfile(viewerId uint, viewerRole string, UserId uint) (interface{}, error) {
 	user, err := model.GetUserById(UserId)
 	if err != nil {
 		return nil, err
 	}
+	// 管理员查看不受拉黑限制
+	if !model.HasPermission(viewerRole, model.PermManageUsers) {
+		if err := checkNotBlocked(viewerId, UserId); err != nil {
+			return nil, err
+		}
+	}
 	tags, err := model.GetTagsByUserId(UserId)
 	if err != nil {
 		return nil, err
 	}
-
-	return map[string]interface{}{
-		"id":       user.ID,
-		"nickname": user.Nickname,
-		"gender":   user.Gender,
-		"avatar":   user.Avatar,
-		"height":   user.Height,
-		"weight":   user.Weight,
-		"photos":   user.Photos,
-		"birthday": user.Birthday,
-		"coins":    user.Coins,
-		"tags":     tags,
-		"desc":     user.Desc,
-		"videos":   user.Videos,
-	}, nil
+	return ProfileFor(viewerId, viewerRole, user, tags)
 }
 
-func UpdateProfile(uid uint, avatar, nickname string, gender uint) (map[string]interface{}, error) {
-	user := &model.User{
-		Nickname: nickname,
-		Gender:   gender,
-		Avatar:   avatar,
+// 和 GetUserProfile 一致，管理员以外不返回存在拉黑关系的用户
+func GetUserList(viewerId uint, viewerRole string, PageNum, PageSize uint) (map[string]interface{}, error) {
+	var exclude []uint
+	if !model.HasPermission(viewerRole, model.PermManageUsers) {
+		var err error
+		if exclude, err = model.GetBlockRelatedUserIDs(viewerId); err != nil {
+			return nil, err
+		}
 	}
-	user.ID = uid
-	user, err := model.UpdateUserInfo(user)
+	users, err := model.GetUserList(PageNum, PageSize, exclude)
 	if err != nil {
 		return nil, err
 	}
-
-	return map[string]interface{}{
-		"id":       user.ID,
-		"nickname": user.Nickname,
-		"gender":   user.Gender,
-		"avatar":   user.Avatar,
-		"height":   user.Height,
-		"weight":   user.Weight,
-		"photos":   user.Photos,
-		"birthday": user.Birthday,
-		"coins":    user.Coins,
-		"desc":     user.Desc,
-		"videos":   user.Videos,
-	}, nil
-}
-
-func GetUserList(PageNum, PageSize uint) (map[string]interface{}, error) {
-	users, err := model.GetUserList(PageNum, PageSize)
-	if err != nil {
-		return nil, err
+	profiles := make([]interface{}, 0, len(users))
+	for i := range users {
+		p, err := ProfileFor(viewerId, viewerRole, &users[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
 	}
 	return map[string]interface{}{
-		"count": len(users),
-		"users": users,
+		"count": len(profiles),
+		"users": profiles,
 	}, nil
 }
 
@@ -76,13 +61,32 @@ func GetTags(UserId uint) ([]model.Tags, error) {
 }
 
 func CreateTag(UserId uint, Tag string) (*model.Tags, error) {
+	if _, err := ModerateText(UserId, model.ModerationSceneTag, Tag); err != nil {
+		return nil, err
+	}
 	return model.CreateTag(UserId, Tag)
 }
 
-func DeleteTag(TagId uint) error {
-	return model.DeleteTag(TagId)
+func DeleteTag(UserId, TagId uint) error {
+	return model.DeleteTag(UserId, TagId)
 }
 
 func UserRecharge(UserId, Coins uint) error {
 	return model.UserRecharge(UserId, Coins)
 }
+
+var ErrInvalidRole = errors.New("invalid role")
+
+// 调整用户角色，注销该用户所有会话使新角色立即生效
+func SetUserRole(UserId uint, role string) error {
+	if !model.IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	if _, err := model.GetUserById(UserId); err != nil {
+		return err
+	}
+	if err := model.UpdateUserRole(UserId, role); err != nil {
+		return err
+	}
+	return model.DeleteUserSessions(UserId)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidKey       = errors.New("invalid storage key")
+	ErrInvalidSignature = errors.New("invalid or expired upload signature")
+)
+
+// Local 存储在本地磁盘，通过 API 服务的静态目录访问
+type Local struct {
+	Dir     string
+	BaseURL string // 例如 http://localhost:15151/uploads
+	signKey []byte
+}
+
+// 直传地址由 API 服务接收，路径固定
+const LocalDirectUploadPath = "/api/file/direct"
+
+func NewLocal(dir, baseURL, signKey string) *Local {
+	if dir == "" {
+		dir = "data/uploads"
+	}
+	if baseURL == "" {
+		baseURL = "/uploads"
+	}
+	key := []byte(signKey)
+	if len(key) == 0 {
+		// 未配置时使用随机密钥，重启后未使用的直传地址失效
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &Local{Dir: dir, BaseURL: strings.TrimRight(baseURL, "/"), signKey: key}
+}
+
+// 防止 key 中的 .. 访问到存储目录之外
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) URL(key string) string {
+	return l.BaseURL + "/" + strings.TrimLeft(key, "/")
+}
+
+func (l *Local) sign(key, contentType string, expires int64) string {
+	mac := hmac.New(sha256.New, l.signKey)
+	fmt.Fprintf(mac, "%s\n%s\n%d", key, contentType, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (l *Local) PresignPut(key, contentType string, expires time.Duration) (string, map[string]string, error) {
+	if _, err := l.path(key); err != nil {
+		return "", nil, err
+	}
+	exp := time.Now().Add(expires).Unix()
+	q := url.Values{}
+	q.Set("key", key)
+	q.Set("expires", strconv.FormatInt(exp, 10))
+	q.Set("signature", l.sign(key, contentType, exp))
+	return LocalDirectUploadPath + "?" + q.Encode(), map[string]string{"Content-Type": contentType}, nil
+}
+
+// VerifyPresigned 校验直传地址的签名
+func (l *Local) VerifyPresigned(key, contentType, expires, signature string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(l.sign(key, contentType, exp))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3 兼容 AWS S3 和 MinIO 的存储，使用 Signature V4 签名
+type S3 struct {
+	Endpoint  string // 例如 https://s3.us-east-1.amazonaws.com 或 http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool   // MinIO 通常使用 path style
+	PublicURL string // 对外访问地址，为空时使用 Endpoint
+}
+
+var storageHTTPClient = &http.Client{Timeout: 5 * time.Minute}
+
+func (s *S3) region() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+// 对象地址，path style 为 endpoint/bucket/key，否则为 bucket.endpoint/key
+func (s *S3) objectURL(base, key string) *url.URL {
+	u, _ := url.Parse(strings.TrimRight(base, "/"))
+	escaped := escapePath(key)
+	if s.PathStyle {
+		u.Path = "/" + s.Bucket + "/" + key
+		u.RawPath = "/" + s.Bucket + "/" + escaped
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + key
+		u.RawPath = "/" + escaped
+	}
+	return u
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(s.Endpoint, key).String(), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	return s.do(req)
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(s.Endpoint, key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.signHeader(req, time.Now().UTC())
+	resp, err := storageHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("s3 GET %s failed: %d %s", req.URL.Path, resp.StatusCode, body)
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(s.Endpoint, key).String(), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req)
+}
+
+func (s *S3) do(req *http.Request) error {
+	s.signHeader(req, time.Now().UTC())
+	resp, err := storageHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && !(req.Method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("s3 %s %s failed: %d %s", req.Method, req.URL.Path, resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (s *S3) URL(key string) string {
+	base := s.PublicURL
+	if base == "" {
+		return s.objectURL(s.Endpoint, key).String()
+	}
+	return strings.TrimRight(base, "/") + "/" + escapePath(key)
+}
+
+func (s *S3) PresignPut(key, contentType string, expires time.Duration) (string, map[string]string, error) {
+	now := time.Now().UTC()
+	u := s.objectURL(s.Endpoint, key)
+	scope := s.scope(now)
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", now.Format(amzDateFormat))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "content-type;host")
+
+	headers := map[string]string{"content-type": contentType, "host": u.Host}
+	canonical := canonicalRequest(http.MethodPut, u, q, headers, unsignedPayload)
+	q.Set("X-Amz-Signature", s.signature(now, canonical))
+	u.RawQuery = canonicalQuery(q)
+	return u.String(), map[string]string{"Content-Type": contentType}, nil
+}
+
+func (s *S3) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region() + "/s3/aws4_request"
+}
+
+func (s *S3) signHeader(req *http.Request, now time.Time) {
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-date":           now.Format(amzDateFormat),
+		"x-amz-content-sha256": unsignedPayload,
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		headers["content-type"] = ct
+	}
+	canonical := canonicalRequest(req.Method, req.URL, req.URL.Query(), headers, unsignedPayload)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, s.scope(now), signedHeaders(headers), s.signature(now, canonical)))
+}
+
+func (s *S3) signature(t time.Time, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format(amzDateFormat) + "\n" + s.scope(t) + "\n" + hex.EncodeToString(sum[:])
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalRequest(method string, u *url.URL, q url.Values, headers map[string]string, payloadHash string) string {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	return strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		canonicalQuery(q),
+		b.String(),
+		signedHeaders(headers),
+		payloadHash,
+	}, "\n")
+}
+
+func signedHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ";")
+}
+
+// 签名要求的查询串编码，空格编码为 %20
+func canonicalQuery(q url.Values) string {
+	return strings.ReplaceAll(q.Encode(), "+", "%20")
+}
+
+// 按 RFC 3986 编码路径，保留 /
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(url.PathEscape(part), "+", "%2B")
+	}
+	return strings.Join(parts, "/")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
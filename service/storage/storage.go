@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"worldCity/config"
+)
+
+// Storage 文件存储后端
+type Storage interface {
+	// 上传文件，size 为 -1 表示未知
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// 读取文件内容，调用方负责关闭
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// 删除文件，文件不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// 文件的访问地址
+	URL(key string) string
+	// 生成客户端直传的预签名地址，客户端使用 PUT 上传并带上返回的请求头
+	PresignPut(key, contentType string, expires time.Duration) (string, map[string]string, error)
+}
+
+// 待审核的图片和未校验的直传文件存放在这个前缀下，不对外提供访问
+// 使用 S3、OSS 时需要在存储桶策略中禁止公开读取这个前缀
+const PrivatePrefix = "private/"
+
+// PublicFS 本地存储对外访问的目录，不包括 PrivatePrefix 下的文件
+func PublicFS(fs http.FileSystem) http.FileSystem {
+	return publicFS{fs}
+}
+
+type publicFS struct {
+	http.FileSystem
+}
+
+func (fs publicFS) Open(name string) (http.File, error) {
+	if strings.HasPrefix(path.Clean("/"+name), "/"+PrivatePrefix) {
+		return nil, os.ErrNotExist
+	}
+	return fs.FileSystem.Open(name)
+}
+
+var store Storage
+
+// Init 根据配置初始化存储后端
+func Init() error {
+	conf := config.GetConf()
+	switch conf.Storage.Driver {
+	case "", "local":
+		store = NewLocal(conf.Storage.Local.Dir, conf.Storage.Local.BaseURL, conf.Storage.Local.SignKey)
+	case "s3":
+		s3Conf := conf.Storage.S3
+		store = &S3{
+			Endpoint:  s3Conf.Endpoint,
+			Region:    s3Conf.Region,
+			Bucket:    s3Conf.Bucket,
+			AccessKey: s3Conf.AccessKey,
+			SecretKey: s3Conf.SecretKey,
+			PathStyle: s3Conf.PathStyle,
+			PublicURL: s3Conf.PublicURL,
+		}
+	case "oss":
+		ossConf := conf.OSS
+		store = &OSS{
+			Endpoint:  ossConf.Endpoint,
+			Bucket:    ossConf.Bucket,
+			AccessKey: ossConf.AccessKey,
+			SecretKey: ossConf.SecretKey,
+			Domain:    ossConf.Domain,
+		}
+	default:
+		return fmt.Errorf("unknown storage driver %q", conf.Storage.Driver)
+	}
+	return nil
+}
+
+// Get 当前使用的存储后端
+func Get() Storage {
+	return store
+}
+
+// Set 替换存储后端
+func Set(s Storage) {
+	store = s
+}
@@ -0,0 +1,108 @@
+package utils
+
+import "unicode"
+
+// ACMatcher Aho–Corasick 多模式匹配，按 rune 匹配，忽略大小写和全角半角的差异
+type ACMatcher struct {
+	nodes    []acNode
+	patterns []string
+	lens     []int // 模式的 rune 长度
+}
+
+type acNode struct {
+	next map[rune]int32
+	fail int32
+	out  []int32 // 以该节点结尾的模式，包括通过失败指针可达的
+}
+
+// ACMatch 一次命中，Start 和 End 是 rune 下标，区间左闭右开
+type ACMatch struct {
+	Pattern int
+	Start   int
+	End     int
+}
+
+// 统一大小写和全角字符，不改变 rune 数量，命中位置可以直接对应原文
+func foldRune(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		r -= 0xFEE0
+	} else if r == 0x3000 {
+		r = ' '
+	}
+	return unicode.ToLower(r)
+}
+
+func NewACMatcher(patterns []string) *ACMatcher {
+	m := &ACMatcher{nodes: []acNode{{}}, patterns: patterns, lens: make([]int, len(patterns))}
+	for i, p := range patterns {
+		cur := int32(0)
+		for _, r := range p {
+			m.lens[i]++
+			r = foldRune(r)
+			next, ok := m.nodes[cur].next[r]
+			if !ok {
+				if m.nodes[cur].next == nil {
+					m.nodes[cur].next = map[rune]int32{}
+				}
+				next = int32(len(m.nodes))
+				m.nodes = append(m.nodes, acNode{})
+				m.nodes[cur].next[r] = next
+			}
+			cur = next
+		}
+		if cur != 0 {
+			m.nodes[cur].out = append(m.nodes[cur].out, int32(i))
+		}
+	}
+
+	// 按层构建失败指针
+	queue := make([]int32, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			f := m.nodes[cur].fail
+			for f != 0 && !m.hasEdge(f, r) {
+				f = m.nodes[f].fail
+			}
+			if next, ok := m.nodes[f].next[r]; ok && next != child {
+				m.nodes[child].fail = next
+			}
+			m.nodes[child].out = append(m.nodes[child].out, m.nodes[m.nodes[child].fail].out...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+func (m *ACMatcher) hasEdge(node int32, r rune) bool {
+	_, ok := m.nodes[node].next[r]
+	return ok
+}
+
+// FindAll 返回所有命中，包括相互重叠的
+func (m *ACMatcher) FindAll(text []rune) []ACMatch {
+	var matches []ACMatch
+	cur := int32(0)
+	for i, r := range text {
+		r = foldRune(r)
+		for cur != 0 && !m.hasEdge(cur, r) {
+			cur = m.nodes[cur].fail
+		}
+		if next, ok := m.nodes[cur].next[r]; ok {
+			cur = next
+		}
+		for _, p := range m.nodes[cur].out {
+			matches = append(matches, ACMatch{Pattern: int(p), Start: i + 1 - m.lens[p], End: i + 1})
+		}
+	}
+	return matches
+}
+
+// Pattern 返回第 i 个模式
+func (m *ACMatcher) Pattern(i int) string {
+	return m.patterns[i]
+}
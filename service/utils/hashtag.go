@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 话题由 # 开头，包含文字、数字和下划线，遇到空格或标点结束
+var hashtagRe = regexp.MustCompile(`[#＃]([\p{L}\p{N}_]+)`)
+
+const MaxHashtagLen = 32
+
+// HashtagKey 话题的统一写法，忽略大小写
+func HashtagKey(tag string) string {
+	return strings.ToLower(tag)
+}
+
+// ExtractHashtags 按出现顺序返回文本中的话题，忽略大小写去重，过长的话题忽略
+func ExtractHashtags(text string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, m := range hashtagRe.FindAllStringSubmatch(text, -1) {
+		tag := m[1]
+		key := HashtagKey(tag)
+		if utf8.RuneCountInString(tag) > MaxHashtagLen || seen[key] {
+			continue
+		}
+		seen[key] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
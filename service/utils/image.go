@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+)
+
+var ErrInvalidImage = errors.New("invalid image data")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// StripJPEGMetadata 去掉 JPEG 中的 EXIF、XMP 和 IPTC 等元数据（其中可能包含 GPS 位置），
+// 只保留方向信息，避免竖拍的照片显示成横向
+func StripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrInvalidImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := uint16(0)
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, ErrInvalidImage
+		}
+		// 标记前可以有多个 0xFF 填充
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, ErrInvalidImage
+		}
+		marker := data[pos]
+		pos++
+		// 没有长度字段的标记
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD9) {
+			out.Write([]byte{0xFF, marker})
+			continue
+		}
+		if pos+2 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		if length < 2 || pos+length > len(data) {
+			return nil, ErrInvalidImage
+		}
+		segment := data[pos+2 : pos+length]
+		switch marker {
+		case 0xE1: // APP1：EXIF 或 XMP
+			if o := exifOrientation(segment); o > 1 {
+				orientation = o
+			}
+		case 0xED: // APP13：Photoshop/IPTC
+		case 0xDA: // SOS 之后是图像数据，原样保留
+			if orientation > 1 {
+				writeOrientationSegment(out, orientation)
+			}
+			out.Write([]byte{0xFF, marker})
+			out.Write(data[pos:])
+			return out.Bytes(), nil
+		default:
+			out.Write([]byte{0xFF, marker})
+			out.Write(data[pos : pos+length])
+		}
+		pos += length
+	}
+	return nil, ErrInvalidImage
+}
+
+// 读取 EXIF 中 IFD0 的 Orientation，没有时返回 0
+func exifOrientation(segment []byte) uint16 {
+	if len(segment) < 14 || string(segment[:6]) != "Exif\x00\x00" {
+		return 0
+	}
+	tiff := segment[6:]
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := order.Uint16(tiff[entry+8:])
+			if o > 8 {
+				return 0
+			}
+			return o
+		}
+	}
+	return 0
+}
+
+// 写入只包含 Orientation 的最小 EXIF 段
+func writeOrientationSegment(out *bytes.Buffer, orientation uint16) {
+	payload := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08")
+	payload = binary.BigEndian.AppendUint16(payload, 1)      // 一个条目
+	payload = binary.BigEndian.AppendUint16(payload, 0x0112) // Orientation
+	payload = binary.BigEndian.AppendUint16(payload, 3)      // SHORT
+	payload = binary.BigEndian.AppendUint32(payload, 1)
+	payload = binary.BigEndian.AppendUint16(payload, orientation)
+	payload = binary.BigEndian.AppendUint16(payload, 0)
+	payload = binary.BigEndian.AppendUint32(payload, 0) // 没有下一个 IFD
+	out.Write([]byte{0xFF, 0xE1})
+	out.Write(binary.BigEndian.AppendUint16(nil, uint16(len(payload)+2)))
+	out.Write(payload)
+}
+
+// StripPNGMetadata 去掉 PNG 中的 eXIf 和文本块
+func StripPNGMetadata(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrInvalidImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length // 长度、类型、数据、CRC
+		if length < 0 || end > len(data) {
+			return nil, ErrInvalidImage
+		}
+		switch string(data[pos+4 : pos+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt":
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+	return out.Bytes(), nil
+}
+
+// Thumbnail 按最长边等比缩小，使用区域平均采样；原图不大于 maxEdge 时返回 nil
+func Thumbnail(src image.Image, maxEdge int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if maxEdge <= 0 || (sw <= maxEdge && sh <= maxEdge) {
+		return nil
+	}
+	dw, dh := maxEdge, sh*maxEdge/sw
+	if sh > sw {
+		dw, dh = sw*maxEdge/sh, maxEdge
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	rgba, ok := src.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, sw, sh))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+		b = rgba.Bounds()
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, max((dy+1)*sh/dh, dy*sh/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, max((dx+1)*sw/dw, dx*sw/dw+1)
+			var r, g, bl, a, n uint32
+			for y := y0; y < y1; y++ {
+				row := rgba.PixOffset(b.Min.X+x0, b.Min.Y+y)
+				for x := x0; x < x1; x++ {
+					p := rgba.Pix[row : row+4 : row+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					bl += uint32(p[2])
+					a += uint32(p[3])
+					n++
+					row += 4
+				}
+			}
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// IsOpaque 判断图片是否不含透明像素
+func IsOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// JPEGOrientation 读取 JPEG 的 EXIF 方向，没有时返回 0
+func JPEGOrientation(data []byte) uint16 {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0
+	}
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		// SOS 之后是图像数据，方向信息只会出现在前面
+		if marker == 0xDA || marker == 0xD9 {
+			return 0
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 0
+		}
+		if marker == 0xE1 {
+			if o := exifOrientation(data[pos+4 : pos+2+length]); o > 0 {
+				return o
+			}
+		}
+		pos += 2 + length
+	}
+	return 0
+}
+
+// ApplyOrientation 按 EXIF 方向旋转或翻转图片，得到正常显示的方向
+func ApplyOrientation(src image.Image, orientation uint16) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-x, y
+			case 3: // 旋转 180 度
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90 度
+				sx, sy = y, h-1-x
+			case 7: // 沿副对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针旋转 90 度
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, src.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -1,37 +1,80 @@
 package utils
 
 import (
+	"errors"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var jwtKey = []byte("your_secret_key")
+// 签名密钥，kid -> secret，轮换时新旧密钥同时保留，新签发的 token 使用 activeKid
+var (
+	jwtKeys   = map[string][]byte{}
+	activeKid string
+)
+
+var ErrUnknownKid = errors.New("unknown token key id")
 
 type Claims struct {
-	UserID uint64
-	Accid  string
+	UserID    uint64
+	Accid     string
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint64, accid string) (string, error) {
-	expirationTime := time.Now().Add(7 * 24 * time.Hour)
+// InitJWTKeys 设置签名密钥，未配置时生成随机密钥，重启后已签发的 token 全部失效
+func InitJWTKeys(kid string, keys map[string]string) {
+	jwtKeys = make(map[string][]byte, len(keys))
+	for k, secret := range keys {
+		if secret != "" {
+			jwtKeys[k] = []byte(secret)
+		}
+	}
+	if _, ok := jwtKeys[kid]; !ok {
+		if len(jwtKeys) > 0 {
+			log.Fatalf("jwt active kid %q not found in configured keys", kid)
+		}
+		log.Println("⚠️ jwt keys not configured, using a random key")
+		kid = "random"
+		jwtKeys[kid] = []byte(RandomString(48))
+	}
+	activeKid = kid
+}
+
+func GenerateToken(userID uint64, accid, role, sessionID string, ttl time.Duration) (string, error) {
+	if len(jwtKeys) == 0 {
+		InitJWTKeys("", nil)
+	}
 	claims := &Claims{
-		UserID: userID,
-		Accid:  accid,
+		UserID:    userID,
+		Accid:     accid,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	token.Header["kid"] = activeKid
+	return token.SignedString(jwtKeys[activeKid])
 }
 
 func ParseToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	_, err := jwt.ParseWithClaims(tokenString, claims,
 		func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			kid, _ := token.Header["kid"].(string)
+			key, ok := jwtKeys[kid]
+			if !ok {
+				return nil, ErrUnknownKid
+			}
+			return key, nil
 		})
 
 	if err != nil {
@@ -0,0 +1,24 @@
+package utils
+
+import "encoding/json"
+
+// Optional 用于部分更新的 JSON 字段，区分未提供、显式 null 和有值三种情况
+type Optional[T any] struct {
+	Set   bool // 请求中包含该字段
+	Null  bool // 显式传入 null，表示清空
+	Value T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// NewOptional 构造一个有值的字段
+func NewOptional[T any](v T) Optional[T] {
+	return Optional[T]{Set: true, Value: v}
+}
@@ -7,13 +7,39 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// 密码哈希的 bcrypt cost，调整后旧密码会在下次登录时重新哈希
+var passwordCost = bcrypt.DefaultCost
+
+// 设置 bcrypt cost，0 或超出范围时使用默认值
+func InitPasswordCost(cost int) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	passwordCost = cost
+}
+
 func Sha1(s string) string {
 	h := sha1.New()
 	h.Write([]byte(s))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-func HashPassword(password string) string {
-	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(hash)
+// bcrypt 只接受不超过 72 字节的密码，调用方需要先检查长度
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// 校验密码，第三方登录创建的账号没有密码，总是失败
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// cost 与当前配置不一致时需要重新哈希
+func PasswordNeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost != passwordCost
 }
@@ -7,3 +7,13 @@ import (
 func GetUnreadKey(userID uint) string {
 	return fmt.Sprintf("unread:%d", userID)
 }
+
+// 群未读数，hash 结构，field 为群ID
+func GetGroupUnreadKey(userID uint) string {
+	return fmt.Sprintf("group_unread:%d", userID)
+}
+
+// 群内被@的次数，hash 结构，field 为群ID
+func GetGroupMentionKey(userID uint) string {
+	return fmt.Sprintf("group_mention:%d", userID)
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	crand "crypto/rand"
 	"fmt"
+	"math/big"
 	"math/rand"
 	"net/http"
 	"strconv"
@@ -35,3 +37,35 @@ func GenerateOrderNo(shopID uint) string {
 	return orderID
 
 }
+
+const randomChars = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz23456789"
+
+// 生成随机字符串，用于邀请码等需要不可预测的场景
+func RandomString(n int) string {
+	return randomFrom(randomChars, n)
+}
+
+func randomFrom(chars string, n int) string {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(chars)))
+	for i := range b {
+		idx, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = chars[idx.Int64()]
+	}
+	return string(b)
+}
+
+// 生成群ID：10位数字，首位不为0
+func GenerateGroupID() string {
+	return fmt.Sprintf("%d%09d", rand.Intn(9)+1, rand.Intn(1000000000))
+}
+
+const accIdChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// 生成云信 accid，只包含小写字母和数字
+func GenerateAccId() string {
+	return "wc" + randomFrom(accIdChars, 16)
+}
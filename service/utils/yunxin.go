@@ -3,23 +3,120 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
+	"worldCity/model"
 	"worldCity/yunxin"
 )
 
-// url := "https://api.netease.im/nimserver/msg/sendMsg.action"
-func SendYunxinMsg(fromID, toID uint, msgType, content string) error {
+// 云信消息类型
+const (
+	YunxinMsgText     = 0
+	YunxinMsgImage    = 1
+	YunxinMsgAudio    = 2
+	YunxinMsgVideo    = 3
+	YunxinMsgLocation = 4
+	YunxinMsgCustom   = 100
+)
+
+// 云信 ope: 0 点对点, 1 群消息
+const (
+	YunxinOpeP2P  = 0
+	YunxinOpeTeam = 1
+)
+
+// 将消息内容转换为云信的消息类型和 body，云信没有的类型（卡片、礼物）使用自定义消息
+func BuildYunxinMsgBody(payload model.MessagePayload) (int, string, error) {
+	var msgType int
+	var body interface{}
 
-	reqBody := map[string]interface{}{
-		"from": fmt.Sprintf("%d", fromID),
-		"ope":  0,
-		"to":   fmt.Sprintf("%d", toID),
-		"type": 0, // 文本消息
-		"body": fmt.Sprintf(`{"msg":"%s"}`, content),
+	switch p := payload.(type) {
+	case *model.TextPayload:
+		msgType = YunxinMsgText
+		body = map[string]interface{}{"msg": p.Text}
+	case *model.ImagePayload:
+		msgType = YunxinMsgImage
+		body = map[string]interface{}{
+			"url": p.URL, "ext": p.Ext, "w": p.Width, "h": p.Height, "size": p.Size, "md5": p.Md5,
+		}
+	case *model.AudioPayload:
+		msgType = YunxinMsgAudio
+		body = map[string]interface{}{
+			"url": p.URL, "ext": p.Ext, "dur": p.Duration, "size": p.Size, "md5": p.Md5,
+		}
+	case *model.VideoPayload:
+		msgType = YunxinMsgVideo
+		body = map[string]interface{}{
+			"url": p.URL, "ext": p.Ext, "dur": p.Duration, "w": p.Width, "h": p.Height, "size": p.Size, "md5": p.Md5,
+		}
+	case *model.LocationPayload:
+		msgType = YunxinMsgLocation
+		body = map[string]interface{}{"title": p.Title, "lat": p.Lat, "lng": p.Lng}
+	default:
+		msgType = YunxinMsgCustom
+		body = map[string]interface{}{"type": payload.Type(), "data": payload}
 	}
 
-	data, err := json.Marshal(reqBody)
-	if err == nil {
-		_, err = yunxin.DoYunXinPost("/msg/sendMsg.action", data)
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, "", err
 	}
+	return msgType, string(data), nil
+}
+
+// url := "https://api.netease.im/nimserver/msg/sendMsg.action"
+func SendYunxinMsg(fromAccId, toAccId string, payload model.MessagePayload) error {
+	msgType, body, err := BuildYunxinMsgBody(payload)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("from", fromAccId)
+	form.Set("ope", fmt.Sprintf("%d", YunxinOpeP2P))
+	form.Set("to", toAccId)
+	form.Set("type", fmt.Sprintf("%d", msgType))
+	form.Set("body", body)
+
+	_, err = yunxin.DoYunXinPost("/msg/sendMsg.action", []byte(form.Encode()))
 	return err
 }
+
+// 发送自定义系统通知，用于撤回、编辑等事件同步，不会计入会话消息
+// url := "https://api.netease.im/nimserver/msg/sendAttachMsg.action"
+func SendYunxinAttachMsg(fromAccId, toAccId string, attach string) error {
+	form := url.Values{}
+	form.Set("from", fromAccId)
+	form.Set("msgtype", fmt.Sprintf("%d", YunxinOpeP2P))
+	form.Set("to", toAccId)
+	form.Set("attach", attach)
+	form.Set("save", "2") // 离线也保存
+
+	_, err := yunxin.DoYunXinPost("/msg/sendAttachMsg.action", []byte(form.Encode()))
+	return err
+}
+
+// 创建云信账号，账号已存在时视为成功，注册失败重试时不会报错
+// url := "https://api.netease.im/nimserver/user/create.action"
+func CreateYunxinUser(accId, name string) error {
+	form := url.Values{}
+	form.Set("accid", accId)
+	if name != "" {
+		form.Set("name", name)
+	}
+	body, err := yunxin.DoYunXinPost("/user/create.action", []byte(form.Encode()))
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Code int    `json:"code"`
+		Desc string `json:"desc"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid yunxin response: %v", err)
+	}
+	if resp.Code == 200 || (resp.Code == 414 && strings.Contains(resp.Desc, "already register")) {
+		return nil
+	}
+	return fmt.Errorf("yunxin create user %s failed: %d %s", accId, resp.Code, resp.Desc)
+}
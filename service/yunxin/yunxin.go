@@ -9,12 +9,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"time"
-)
-
-const (
-	YunXinAppKey    = "你的AppKey"
-	YunXinAppSecret = "你的AppSecret"
-	YunXinAPIBase   = "https://api.netease.im/nimserver"
+	"worldCity/config"
 )
 
 func generateNonce() string {
@@ -23,21 +18,22 @@ func generateNonce() string {
 	return hex.EncodeToString(b)
 }
 
-func generateCheckSum(nonce, curTime string) string {
-	data := YunXinAppSecret + nonce + curTime
+func generateCheckSum(appSecret, nonce, curTime string) string {
+	data := appSecret + nonce + curTime
 	sum := md5.Sum([]byte(data))
 	return hex.EncodeToString(sum[:])
 }
 
 func DoYunXinPost(path string, body []byte) ([]byte, error) {
+	conf := config.GetConf().Yunxin
 	nonce := generateNonce()
 	curTime := fmt.Sprintf("%d", time.Now().Unix())
-	checkSum := generateCheckSum(nonce, curTime)
+	checkSum := generateCheckSum(conf.AppSecret, nonce, curTime)
 
 	client := &http.Client{}
-	req, _ := http.NewRequest("POST", YunXinAPIBase+path, bytes.NewReader(body))
+	req, _ := http.NewRequest("POST", conf.APIBase+path, bytes.NewReader(body))
 
-	req.Header.Set("AppKey", YunXinAppKey)
+	req.Header.Set("AppKey", conf.AppKey)
 	req.Header.Set("Nonce", nonce)
 	req.Header.Set("CurTime", curTime)
 	req.Header.Set("CheckSum", checkSum)